@@ -0,0 +1,53 @@
+// storage-migrate copia os uploads existentes de um diretório local para o
+// backend configurado em STORAGE_DRIVER (ex: de ./uploads para um bucket S3).
+//
+//	go run ./cmd/storage-migrate -src ./uploads
+//	go run ./cmd/storage-migrate -src ./uploads -prefix points/ -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/joho/godotenv"
+
+	"github.com/Rafhael-Viana/m/storage"
+)
+
+func main() {
+	srcDir := flag.String("src", "./uploads", "diretório local de origem")
+	prefix := flag.String("prefix", "", "copia só as chaves com este prefixo")
+	overwrite := flag.Bool("overwrite", false, "sobrescreve objetos que já existem no destino")
+	dryRun := flag.Bool("dry-run", false, "só lista o que seria copiado")
+	flag.Parse()
+
+	godotenv.Load()
+
+	src, err := storage.NewLocal(*srcDir)
+	if err != nil {
+		log.Fatalf("Error opening source dir: %v", err)
+	}
+
+	dst, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring destination storage: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stats, err := storage.Copy(ctx, src, dst, *prefix, *overwrite, *dryRun, func(key string, err error) {
+		log.Printf("Falha ao copiar %s: %v", key, err)
+	})
+	if err != nil {
+		log.Fatalf("Error listing source: %v", err)
+	}
+
+	log.Printf("Copiados: %d (%d bytes), pulados: %d, falhas: %d", stats.Copied, stats.Bytes, stats.Skipped, stats.Failed)
+	if stats.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -49,7 +49,7 @@ func Cors(allowedOrigins []string, allowCredentials bool) func(http.Handler) htt
 			}
 
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Kiosk-Token")
 			// evita preflight repetido por 10 min:
 			w.Header().Set("Access-Control-Max-Age", "600")
 
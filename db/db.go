@@ -50,6 +50,9 @@ func NewPool() (*Database, error) {
 	cfg.MinConns = 1
 	cfg.MaxConnIdleTime = 5 * time.Minute
 
+	// empresa da requisição (ver tenant.go)
+	cfg.PrepareConn = prepareTenant
+
 	fmt.Println("Banco conectado")
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
@@ -0,0 +1,17 @@
+-- Fuso horário por setor e por usuário (o do usuário tem prioridade).
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS timezone TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT;
+
+-- Batidas passam a ser armazenadas como instantes absolutos. Registros antigos
+-- foram gravados com o horário local do servidor (America/Sao_Paulo).
+DO $$
+BEGIN
+	IF (
+		SELECT data_type FROM information_schema.columns
+		WHERE table_name = 'points' AND column_name = 'clock_in'
+	) = 'timestamp without time zone' THEN
+		ALTER TABLE points
+			ALTER COLUMN clock_in TYPE TIMESTAMPTZ USING clock_in AT TIME ZONE 'America/Sao_Paulo',
+			ALTER COLUMN clock_out TYPE TIMESTAMPTZ USING clock_out AT TIME ZONE 'America/Sao_Paulo';
+	END IF;
+END $$;
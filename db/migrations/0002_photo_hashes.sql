@@ -0,0 +1,22 @@
+-- Hashes das fotos de batida para detectar selfies reaproveitadas.
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_in_sha256 TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_in_phash BIGINT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_out_sha256 TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_out_phash BIGINT;
+
+CREATE INDEX IF NOT EXISTS points_photo_in_sha256_idx ON points (user_id, photo_in_sha256);
+CREATE INDEX IF NOT EXISTS points_photo_out_sha256_idx ON points (user_id, photo_out_sha256);
+
+-- Uma linha por foto suspeita: qual foto anterior ela repete e o quão parecida é.
+CREATE TABLE IF NOT EXISTS point_photo_flags (
+	id               SERIAL PRIMARY KEY,
+	point_id         INTEGER NOT NULL REFERENCES points (id) ON DELETE CASCADE,
+	photo            TEXT NOT NULL CHECK (photo IN ('in', 'out')),
+	reason           TEXT NOT NULL CHECK (reason IN ('exact', 'similar')),
+	matched_point_id INTEGER NOT NULL REFERENCES points (id) ON DELETE CASCADE,
+	matched_photo    TEXT NOT NULL CHECK (matched_photo IN ('in', 'out')),
+	distance         INTEGER NOT NULL,
+	created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS point_photo_flags_point_idx ON point_photo_flags (point_id);
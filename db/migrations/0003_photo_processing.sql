@@ -0,0 +1,6 @@
+-- Miniaturas e data de captura (EXIF) das fotos de batida. O EXIF em si é
+-- descartado no re-encode; só a data de captura é preservada aqui.
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_in_thumb TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_in_taken_at TIMESTAMPTZ;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_out_thumb TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photo_out_taken_at TIMESTAMPTZ;
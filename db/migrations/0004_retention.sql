@@ -0,0 +1,39 @@
+-- LGPD: políticas de retenção por categoria de dado pessoal.
+CREATE TABLE IF NOT EXISTS retention_policies (
+	category         TEXT PRIMARY KEY,
+	retention_months INTEGER NOT NULL CHECK (retention_months > 0),
+	enabled          BOOLEAN NOT NULL DEFAULT true,
+	updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Padrões: selfies e localizações por 12 meses. Horários de batida não
+-- entram aqui, pois precisam ser mantidos pelo prazo legal.
+INSERT INTO retention_policies (category, retention_months, enabled) VALUES
+	('point_photos', 12, false),
+	('point_locations', 12, false)
+ON CONFLICT (category) DO NOTHING;
+
+ALTER TABLE points ADD COLUMN IF NOT EXISTS photos_purged_at TIMESTAMPTZ;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS locations_purged_at TIMESTAMPTZ;
+
+-- Cada execução do job gera um relatório do que foi apagado.
+CREATE TABLE IF NOT EXISTS retention_runs (
+	id          BIGSERIAL PRIMARY KEY,
+	started_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	finished_at TIMESTAMPTZ,
+	dry_run     BOOLEAN NOT NULL DEFAULT false,
+	triggered_by TEXT,
+	status      TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'done', 'failed')),
+	summary     JSONB
+);
+
+CREATE TABLE IF NOT EXISTS retention_run_items (
+	id         BIGSERIAL PRIMARY KEY,
+	run_id     BIGINT NOT NULL REFERENCES retention_runs (id) ON DELETE CASCADE,
+	category   TEXT NOT NULL,
+	point_id   INTEGER,
+	object_key TEXT,
+	purged_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS retention_run_items_run_idx ON retention_run_items (run_id);
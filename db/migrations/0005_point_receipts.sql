@@ -0,0 +1,21 @@
+-- Comprovante de registro de ponto (Portaria 671): um por marcação, com NSR sequencial.
+CREATE SEQUENCE IF NOT EXISTS point_nsr_seq;
+
+CREATE TABLE IF NOT EXISTS point_receipts (
+	id              BIGSERIAL PRIMARY KEY,
+	code            UUID NOT NULL UNIQUE,
+	nsr             BIGINT NOT NULL UNIQUE DEFAULT nextval('point_nsr_seq'),
+	point_id        INTEGER NOT NULL REFERENCES points (id),
+	kind            TEXT NOT NULL CHECK (kind IN ('in', 'out')),
+	user_id         TEXT NOT NULL,
+	employee_name   TEXT NOT NULL,
+	company_name    TEXT NOT NULL,
+	company_cnpj    TEXT NOT NULL,
+	company_address TEXT NOT NULL,
+	punched_at      TIMESTAMPTZ NOT NULL,
+	timezone        TEXT NOT NULL,
+	hash            TEXT NOT NULL,
+	signature       TEXT NOT NULL,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (point_id, kind)
+);
@@ -0,0 +1,7 @@
+-- Faltas já anunciadas no painel ao vivo (evita evento duplicado entre instâncias).
+CREATE TABLE IF NOT EXISTS attendance_absences (
+	user_id     TEXT NOT NULL,
+	day         DATE NOT NULL,
+	notified_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, day)
+);
@@ -0,0 +1,51 @@
+-- Assinaturas de webhooks de saída
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id          UUID PRIMARY KEY,
+	url         TEXT NOT NULL,
+	secret      TEXT NOT NULL,
+	events      TEXT[] NOT NULL,
+	description TEXT,
+	active      BOOLEAN NOT NULL DEFAULT true,
+	created_by  TEXT,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Uma linha por evento x assinatura (outbox). O dispatcher pega as pendentes
+-- com next_attempt_at vencido.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id              BIGSERIAL PRIMARY KEY,
+	subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+	event           TEXT NOT NULL,
+	event_id        UUID NOT NULL,
+	payload         JSONB NOT NULL,
+	status          TEXT NOT NULL DEFAULT 'pending', -- pending|succeeded|failed
+	attempts        INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_attempt_at TIMESTAMPTZ,
+	response_status INT,
+	last_error      TEXT,
+	redelivery_of   BIGINT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx
+	ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx
+	ON webhook_deliveries (subscription_id, id DESC);
+
+-- Log de cada tentativa de entrega
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+	id              BIGSERIAL PRIMARY KEY,
+	delivery_id     BIGINT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+	attempt         INT NOT NULL,
+	attempted_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+	response_status INT,
+	response_body   TEXT,
+	error           TEXT,
+	duration_ms     INT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_attempts_delivery_idx
+	ON webhook_delivery_attempts (delivery_id, attempt);
@@ -0,0 +1,26 @@
+-- Quiosques: tablets compartilhados, autenticados por token próprio
+CREATE TABLE IF NOT EXISTS kiosks (
+	id              UUID PRIMARY KEY,
+	name            TEXT NOT NULL,
+	token_hash      TEXT NOT NULL UNIQUE,
+	setores         TEXT[] NOT NULL DEFAULT '{}',
+	active          BOOLEAN NOT NULL DEFAULT true,
+	failed_attempts INT NOT NULL DEFAULT 0,
+	locked_until    TIMESTAMPTZ,
+	last_seen_at    TIMESTAMPTZ,
+	created_by      TEXT,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Identificação no quiosque. PIN e QR guardam só o HMAC/hash; o crachá é o
+-- número impresso, então fica em claro.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pin_hash TEXT UNIQUE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS badge TEXT UNIQUE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS qr_token_hash TEXT UNIQUE;
+
+-- Origem de cada batida
+ALTER TABLE points ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'app';
+ALTER TABLE points ADD COLUMN IF NOT EXISTS kiosk_id UUID;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS source_out TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS kiosk_out_id UUID;
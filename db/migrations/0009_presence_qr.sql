@@ -0,0 +1,11 @@
+-- Setores que exigem o QR rotativo do local na batida pelo app
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS require_presence_qr BOOLEAN NOT NULL DEFAULT false;
+
+-- Cada código (setor + janela) vale uma vez por funcionário
+CREATE TABLE IF NOT EXISTS presence_qr_uses (
+	setor_id TEXT NOT NULL,
+	window_n BIGINT NOT NULL,
+	user_id  TEXT NOT NULL,
+	used_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (setor_id, window_n, user_id)
+);
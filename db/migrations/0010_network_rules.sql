@@ -0,0 +1,32 @@
+-- Política de rede por setor: off (não checa), flag (aceita e sinaliza) ou
+-- reject (recusa a batida fora da rede permitida)
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS network_policy TEXT NOT NULL DEFAULT 'off';
+
+-- Faixas de IP (CIDR) e BSSIDs de Wi-Fi permitidos por setor
+CREATE TABLE IF NOT EXISTS setor_network_rules (
+	id          BIGSERIAL PRIMARY KEY,
+	setor_id    TEXT NOT NULL,
+	kind        TEXT NOT NULL, -- ip|bssid
+	value       TEXT NOT NULL,
+	description TEXT,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (setor_id, kind, value)
+);
+
+-- Rede de origem de cada batida
+ALTER TABLE points ADD COLUMN IF NOT EXISTS ip_in TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS bssid_in TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS ip_out TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS bssid_out TEXT;
+
+-- Batidas aceitas fora da rede permitida (política flag)
+CREATE TABLE IF NOT EXISTS point_network_flags (
+	id         BIGSERIAL PRIMARY KEY,
+	point_id   INT NOT NULL,
+	punch      TEXT NOT NULL, -- in|out
+	ip         TEXT,
+	bssid      TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS point_network_flags_point_idx ON point_network_flags (point_id);
@@ -0,0 +1,39 @@
+-- Aparelhos confiáveis: o app gera um par de chaves no aparelho, registra a
+-- pública e assina cada batida com a privada
+CREATE TABLE IF NOT EXISTS user_devices (
+	id             UUID PRIMARY KEY,
+	user_id        TEXT NOT NULL,
+	name           TEXT NOT NULL,
+	platform       TEXT,
+	key_alg        TEXT NOT NULL, -- ed25519|es256
+	public_key     TEXT NOT NULL, -- base64 (ed25519 cru ou PKIX DER para es256)
+	status         TEXT NOT NULL DEFAULT 'pending', -- pending|approved|rejected|revoked
+	last_signed_ms BIGINT NOT NULL DEFAULT 0,
+	last_used_at   TIMESTAMPTZ,
+	registered_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	decided_by     TEXT,
+	decided_at     TIMESTAMPTZ,
+	revoked_by     TEXT,
+	revoked_at     TIMESTAMPTZ,
+	UNIQUE (public_key)
+);
+
+CREATE INDEX IF NOT EXISTS user_devices_user_idx ON user_devices (user_id);
+
+-- off (não exige), flag (aceita e sinaliza) ou reject
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS device_policy TEXT NOT NULL DEFAULT 'off';
+
+ALTER TABLE points ADD COLUMN IF NOT EXISTS device_in UUID;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS device_out UUID;
+
+-- Batidas aceitas de aparelho não aprovado ou sem assinatura válida
+CREATE TABLE IF NOT EXISTS point_device_flags (
+	id         BIGSERIAL PRIMARY KEY,
+	point_id   INT NOT NULL,
+	punch      TEXT NOT NULL, -- in|out
+	device_id  UUID,
+	reason     TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS point_device_flags_point_idx ON point_device_flags (point_id);
@@ -0,0 +1,27 @@
+-- Exclusão lógica: usuários, setores e batidas vão para a lixeira em vez de
+-- serem apagados. A remoção definitiva só acontece após o prazo da política
+-- 'trash'.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS delete_reason TEXT;
+
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS delete_reason TEXT;
+
+ALTER TABLE points ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS deleted_by TEXT;
+ALTER TABLE points ADD COLUMN IF NOT EXISTS delete_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS users_deleted_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS setores_deleted_idx ON setores (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS points_deleted_idx ON points (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Itens da lixeira removidos pelo job não têm point_id nem arquivo
+ALTER TABLE retention_run_items ADD COLUMN IF NOT EXISTS ref TEXT;
+
+-- Prazo mínimo na lixeira antes da remoção definitiva (5 anos, como os
+-- registros de ponto). Desligado por padrão: o job não apaga nada sozinho.
+INSERT INTO retention_policies (category, retention_months, enabled) VALUES
+	('trash', 60, false)
+ON CONFLICT (category) DO NOTHING;
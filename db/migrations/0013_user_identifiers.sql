@@ -0,0 +1,10 @@
+-- Identificadores trabalhistas usados em AFD, eSocial e folha. CPF e PIS são
+-- gravados só com dígitos.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cpf TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pis TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS matricula TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS admissao DATE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_cpf_key ON users (cpf) WHERE cpf IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_pis_key ON users (pis) WHERE pis IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_matricula_key ON users (matricula) WHERE matricula IS NOT NULL;
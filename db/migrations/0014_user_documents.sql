@@ -0,0 +1,23 @@
+-- Documentos do funcionário enviados por POST /api/users/{id}/upload.
+-- O arquivo continua no storage ({id}/{images|audios|docs}/...); aqui fica o
+-- registro para listar, baixar, trocar e avisar do vencimento.
+CREATE TABLE IF NOT EXISTS user_documents (
+	id                BIGSERIAL PRIMARY KEY,
+	user_id           TEXT NOT NULL,
+	category          TEXT CHECK (category IN ('contrato', 'atestado', 'aso', 'identidade')),
+	filename          TEXT,
+	object_key        TEXT NOT NULL,
+	thumb_key         TEXT,
+	content_type      TEXT NOT NULL,
+	size_bytes        BIGINT NOT NULL,
+	sha256            TEXT NOT NULL,
+	expires_on        DATE,
+	uploaded_by       TEXT,
+	uploaded_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+	replaced_at       TIMESTAMPTZ,
+	expiry_alerted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS user_documents_user_idx ON user_documents (user_id);
+CREATE INDEX IF NOT EXISTS user_documents_expiry_idx ON user_documents (expires_on)
+	WHERE expires_on IS NOT NULL;
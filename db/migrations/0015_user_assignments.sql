@@ -0,0 +1,28 @@
+-- Histórico de vínculo do funcionário (setor, cargo e status) com vigência.
+-- valid_to é exclusivo; NULL marca o período atual. Os relatórios usam o
+-- período em vigor no dia de cada batida.
+CREATE TABLE IF NOT EXISTS user_assignments (
+	id         BIGSERIAL PRIMARY KEY,
+	user_id    TEXT NOT NULL,
+	setor_id   TEXT,
+	cargo      TEXT,
+	status     TEXT NOT NULL,
+	valid_from DATE NOT NULL,
+	valid_to   DATE,
+	changed_by TEXT,
+	reason     TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	CHECK (valid_to IS NULL OR valid_to > valid_from)
+);
+
+CREATE INDEX IF NOT EXISTS user_assignments_user_idx ON user_assignments (user_id, valid_from);
+CREATE UNIQUE INDEX IF NOT EXISTS user_assignments_current_key ON user_assignments (user_id)
+	WHERE valid_to IS NULL;
+
+-- Ponto de partida: o cadastro atual vale desde a admissão ou a primeira
+-- batida (o que vier antes), para cobrir o que já foi registrado.
+INSERT INTO user_assignments (user_id, setor_id, cargo, status, valid_from)
+SELECT u.user_id, u.setor_id, u.cargo, COALESCE(u.status, 'active'),
+	LEAST(u.admissao, (SELECT MIN(p.clock_in)::date FROM points p WHERE p.user_id = u.user_id), current_date)
+FROM users u
+WHERE NOT EXISTS (SELECT 1 FROM user_assignments a WHERE a.user_id = u.user_id);
@@ -0,0 +1,33 @@
+-- Tipos de contrato e as regras de jornada de cada um. daily_minutes é a
+-- jornada normal do dia; max_daily_minutes o teto com horas extras (igual à
+-- jornada quando o contrato não admite extra).
+CREATE TABLE IF NOT EXISTS contract_types (
+	code              TEXT PRIMARY KEY,
+	name              TEXT NOT NULL,
+	daily_minutes     INT NOT NULL CHECK (daily_minutes > 0),
+	max_daily_minutes INT NOT NULL,
+	overtime_allowed  BOOLEAN NOT NULL DEFAULT false,
+	hour_bank         BOOLEAN NOT NULL DEFAULT false, -- excedente entra no banco de horas
+	created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	CHECK (max_daily_minutes >= daily_minutes),
+	CHECK (overtime_allowed OR max_daily_minutes = daily_minutes)
+);
+
+-- CLT: 8h + até 2h extras (art. 59); estágio: 6h (Lei 11.788, art. 10);
+-- aprendiz: 6h sem prorrogação (art. 432); tempo parcial: 6h + 1h extra.
+INSERT INTO contract_types (code, name, daily_minutes, max_daily_minutes, overtime_allowed, hour_bank) VALUES
+	('clt', 'CLT', 480, 600, true, true),
+	('estagio', 'Estágio', 360, 360, false, false),
+	('aprendiz', 'Aprendiz', 360, 360, false, false),
+	('parcial', 'Tempo parcial', 360, 420, true, false)
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS contract_type TEXT NOT NULL DEFAULT 'clt'
+	REFERENCES contract_types (code);
+
+-- o contrato também tem vigência: entra no histórico de vínculo
+ALTER TABLE user_assignments ADD COLUMN IF NOT EXISTS contract_type TEXT;
+UPDATE user_assignments a SET contract_type = u.contract_type
+FROM users u
+WHERE u.user_id = a.user_id AND a.contract_type IS NULL;
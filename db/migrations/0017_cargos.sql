@@ -0,0 +1,20 @@
+-- Catálogo de cargos. users.cargo continua guardando o nome (como users.setor)
+-- para quem já lê o campo; o vínculo de verdade é users.cargo_id.
+CREATE TABLE IF NOT EXISTS cargos (
+	cargo_id              TEXT PRIMARY KEY,
+	nome                  TEXT NOT NULL,
+	cbo                   TEXT CHECK (cbo ~ '^[0-9]{6}$'), -- Classificação Brasileira de Ocupações
+	schedule_start        TIME,                            -- horário padrão de entrada
+	schedule_end          TIME,                            -- e de saída
+	default_contract_type TEXT REFERENCES contract_types (code),
+	created_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+	CHECK ((schedule_start IS NULL) = (schedule_end IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS cargos_nome_key ON cargos (lower(nome));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cargo_id TEXT REFERENCES cargos (cargo_id);
+CREATE INDEX IF NOT EXISTS users_cargo_idx ON users (cargo_id);
+
+ALTER TABLE user_assignments ADD COLUMN IF NOT EXISTS cargo_id TEXT;
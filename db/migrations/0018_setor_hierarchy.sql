@@ -0,0 +1,8 @@
+-- Hierarquia de setores (diretoria > departamento > time). setor_id não tem
+-- constraint de unicidade garantida nas bases antigas, então parent_id fica
+-- sem FK; ciclos e pais inexistentes são recusados pela API.
+ALTER TABLE setores ADD COLUMN IF NOT EXISTS parent_id TEXT;
+ALTER TABLE setores DROP CONSTRAINT IF EXISTS setores_parent_check;
+ALTER TABLE setores ADD CONSTRAINT setores_parent_check CHECK (parent_id <> setor_id);
+
+CREATE INDEX IF NOT EXISTS setores_parent_idx ON setores (parent_id);
@@ -0,0 +1,109 @@
+-- Empresas clientes (multiempresa). Cada linha de dados pertence a uma
+-- empresa e o isolamento é feito pelo próprio banco com row level security:
+-- a API grava a empresa do JWT em app.tenant_id antes de cada query e as
+-- políticas escondem/recusam as linhas das outras empresas. '*' libera todas
+-- (jobs, login e rotinas da plataforma).
+--
+-- O usuário do banco usado pela API NÃO pode ser superusuário nem ter
+-- BYPASSRLS, senão as políticas são ignoradas.
+CREATE TABLE IF NOT EXISTS tenants (
+	tenant_id   TEXT PRIMARY KEY,
+	slug        TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	cnpj        TEXT CHECK (cnpj ~ '^[0-9]{14}$'),
+	address     TEXT,
+	timezone    TEXT,
+	status      TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended')),
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tenants_slug_key ON tenants (slug);
+CREATE UNIQUE INDEX IF NOT EXISTS tenants_cnpj_key ON tenants (cnpj) WHERE cnpj IS NOT NULL;
+
+-- Os dados que já existem ficam na empresa padrão
+INSERT INTO tenants (tenant_id, slug, name) VALUES ('default', 'default', 'Empresa padrão')
+ON CONFLICT (tenant_id) DO NOTHING;
+
+SELECT set_config('app.tenant_id', 'default', true);
+
+-- Ficam fora do isolamento as tabelas da plataforma: tenants,
+-- schema_migrations, contract_types (catálogo legal, igual para todas as
+-- empresas) e a retenção LGPD (retention_*), que roda para todas de uma vez.
+DO $$
+DECLARE
+	t TEXT;
+BEGIN
+	FOREACH t IN ARRAY ARRAY[
+		'users', 'setores', 'points', 'setor_funcionarios', 'attendance_absences',
+		'cargos', 'kiosks', 'point_device_flags', 'point_network_flags',
+		'point_photo_flags', 'point_receipts', 'presence_qr_uses',
+		'setor_network_rules', 'user_assignments', 'user_devices',
+		'user_documents', 'webhook_subscriptions', 'webhook_deliveries',
+		'webhook_delivery_attempts'
+	] LOOP
+		EXECUTE format(
+			'ALTER TABLE %I ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL
+				DEFAULT current_setting(''app.tenant_id'', true) REFERENCES tenants (tenant_id)', t);
+		EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I (tenant_id)', t || '_tenant_idx', t);
+
+		EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+		EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+		EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %I', t);
+		EXECUTE format(
+			'CREATE POLICY tenant_isolation ON %I
+				USING (tenant_id = current_setting(''app.tenant_id'', true) OR current_setting(''app.tenant_id'', true) = ''*'')
+				WITH CHECK (tenant_id = current_setting(''app.tenant_id'', true) OR current_setting(''app.tenant_id'', true) = ''*'')', t);
+	END LOOP;
+END $$;
+
+-- Login, e-mail, documentos e crachá passam a ser únicos por empresa. Os
+-- índices novos mantêm os nomes antigos (a API usa o nome para apontar o
+-- campo em conflito). username e email só ganham índice se já eram únicos.
+DO $$
+DECLARE
+	c RECORD;
+	unique_cols TEXT[] := '{}';
+BEGIN
+	FOR c IN
+		SELECT con.conname, a.attname
+		FROM pg_constraint con
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = con.conkey[1]
+		WHERE con.conrelid = 'users'::regclass AND con.contype = 'u'
+		  AND array_length(con.conkey, 1) = 1
+		  AND a.attname IN ('username', 'email', 'cpf', 'pis', 'matricula', 'badge')
+	LOOP
+		EXECUTE format('ALTER TABLE users DROP CONSTRAINT %I', c.conname);
+		unique_cols := unique_cols || c.attname::text;
+	END LOOP;
+
+	FOR c IN
+		SELECT i.indexrelid::regclass::text AS name, a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+		WHERE i.indrelid = 'users'::regclass AND i.indisunique AND NOT i.indisprimary
+		  AND i.indnatts = 1
+		  AND a.attname IN ('username', 'email', 'cpf', 'pis', 'matricula', 'badge')
+	LOOP
+		EXECUTE format('DROP INDEX %s', c.name);
+		unique_cols := unique_cols || c.attname::text;
+	END LOOP;
+
+	IF 'username' = ANY (unique_cols) THEN
+		CREATE UNIQUE INDEX users_username_key ON users (tenant_id, username);
+	END IF;
+	IF 'email' = ANY (unique_cols) THEN
+		CREATE UNIQUE INDEX users_email_key ON users (tenant_id, email);
+	END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_cpf_key ON users (tenant_id, cpf) WHERE cpf IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_pis_key ON users (tenant_id, pis) WHERE pis IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_matricula_key ON users (tenant_id, matricula) WHERE matricula IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_badge_key ON users (tenant_id, badge) WHERE badge IS NOT NULL;
+
+DROP INDEX IF EXISTS cargos_nome_key;
+CREATE UNIQUE INDEX IF NOT EXISTS cargos_nome_key ON cargos (tenant_id, lower(nome));
+
+-- O super-admin da plataforma é promovido direto no banco, por exemplo:
+--   UPDATE users SET role = 'superadmin' WHERE username = '...';
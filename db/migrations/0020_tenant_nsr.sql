@@ -0,0 +1,14 @@
+-- O NSR do comprovante passa a ser sequencial por empresa (cada empregador
+-- tem a sua numeração). O último número emitido fica na própria empresa e é
+-- incrementado com UPDATE ... RETURNING, na mesma transação da marcação.
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS last_nsr BIGINT NOT NULL DEFAULT 0;
+
+-- continua a partir do maior NSR já emitido pela empresa
+UPDATE tenants t
+SET last_nsr = COALESCE((SELECT MAX(r.nsr) FROM point_receipts r WHERE r.tenant_id = t.tenant_id), 0);
+
+ALTER TABLE point_receipts ALTER COLUMN nsr DROP DEFAULT;
+ALTER TABLE point_receipts DROP CONSTRAINT IF EXISTS point_receipts_nsr_key;
+CREATE UNIQUE INDEX IF NOT EXISTS point_receipts_nsr_key ON point_receipts (tenant_id, nsr);
+
+DROP SEQUENCE IF EXISTS point_nsr_seq;
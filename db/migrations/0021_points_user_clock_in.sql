@@ -0,0 +1,3 @@
+-- Batidas do funcionário por data: a busca de selfies parecidas olha só a
+-- janela recente (PHOTO_MATCH_WINDOW_DAYS).
+CREATE INDEX IF NOT EXISTS points_user_clock_in_idx ON points (user_id, clock_in);
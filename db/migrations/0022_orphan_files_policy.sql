@@ -0,0 +1,6 @@
+-- Remoção de arquivos órfãos do storage (fotos e documentos sem linha no
+-- banco). Desligada por padrão: o job só apaga órfãos depois que alguém liga a
+-- política, e nunca arquivos mais novos que retention_months.
+INSERT INTO retention_policies (category, retention_months, enabled) VALUES
+	('orphan_files', 1, false)
+ON CONFLICT (category) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Data de captura (EXIF) das imagens enviadas como documento
+ALTER TABLE user_documents ADD COLUMN IF NOT EXISTS taken_at TIMESTAMPTZ;
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// As tabelas com dados de empresa têm row level security: cada conexão só
+// enxerga as linhas do tenant gravado em app.tenant_id, e as inserções
+// recebem esse tenant por padrão. O tenant vem do contexto da query.
+
+type tenantKey struct{}
+
+// allTenants libera todas as empresas (jobs e rotinas da plataforma).
+const allTenants = "*"
+
+// WithTenant restringe as queries feitas com ctx à empresa tenantID.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// AllTenants libera as queries feitas com ctx para todas as empresas. Só para
+// jobs, login e consultas públicas que ainda não sabem de qual empresa são.
+func AllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, allTenants)
+}
+
+// TenantFromContext devolve a empresa do contexto ("" sem empresa ou com
+// AllTenants).
+func TenantFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	if id == allTenants {
+		return ""
+	}
+	return id
+}
+
+// prepareTenant grava o tenant do contexto na conexão antes de cada uso.
+// Sem tenant no contexto a conexão não enxerga nada.
+func prepareTenant(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	if _, err := conn.Exec(ctx, `SELECT set_config('app.tenant_id', $1, false)`, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ForEachTenant roda fn uma vez por empresa ativa, com o contexto já
+// restrito a ela. Erros de uma empresa não interrompem as outras.
+func (d *Database) ForEachTenant(ctx context.Context, fn func(ctx context.Context, tenantID string) error) error {
+	rows, err := d.pool.Query(AllTenants(ctx), `SELECT tenant_id FROM tenants WHERE status = 'active' ORDER BY tenant_id`)
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := fn(WithTenant(ctx, id), id); err != nil {
+			log.Printf("tenant %s: %v", id, err)
+		}
+	}
+	return ctx.Err()
+}
+
+// CheckRowSecurity avisa se o usuário do banco ignora row level security
+// (superusuário ou BYPASSRLS): nesse caso o isolamento entre empresas não
+// vale.
+func (d *Database) CheckRowSecurity(ctx context.Context) {
+	var bypass bool
+	err := d.pool.QueryRow(AllTenants(ctx), `
+		SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user
+	`).Scan(&bypass)
+	if err != nil {
+		log.Println("Could not check database role:", err)
+		return
+	}
+	if bypass {
+		log.Println("WARNING: database user bypasses row level security; tenants are NOT isolated. Use a role without SUPERUSER/BYPASSRLS.")
+	}
+}
@@ -0,0 +1,165 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// exifInfo guarda só o que precisamos do EXIF antes de descartá-lo.
+type exifInfo struct {
+	TakenAt     *time.Time
+	Orientation int
+}
+
+const (
+	tagOrientation       = 0x0112
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagDateTimeOriginal  = 0x9003
+	tagOffsetTimeOrig    = 0x9011
+	exifDateLayout       = "2006:01:02 15:04:05"
+	exifDateOffsetLayout = "2006:01:02 15:04:05-07:00"
+)
+
+// readExif extrai data de captura e orientação de um JPEG. Falhas de parse são
+// ignoradas: EXIF ausente ou corrompido não invalida a foto.
+func readExif(data []byte, loc *time.Location) exifInfo {
+	info := exifInfo{Orientation: 1}
+
+	tiff := findExifSegment(data)
+	if tiff == nil || len(tiff) < 8 {
+		return info
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return info
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+
+	if v, ok := ifd0[tagOrientation]; ok {
+		info.Orientation = int(v.short(order))
+	}
+
+	taken := ifd0[tagDateTime].ascii(tiff, order)
+	offset := ""
+	if ptr, ok := ifd0[tagExifIFD]; ok {
+		sub := readIFD(tiff, order, ptr.long(order))
+		if v := sub[tagDateTimeOriginal].ascii(tiff, order); v != "" {
+			taken = v
+		}
+		offset = sub[tagOffsetTimeOrig].ascii(tiff, order)
+	}
+
+	if taken != "" {
+		var t time.Time
+		var err error
+		if offset != "" {
+			t, err = time.Parse(exifDateOffsetLayout, taken+offset)
+		} else {
+			t, err = time.ParseInLocation(exifDateLayout, taken, loc)
+		}
+		if err == nil {
+			info.TakenAt = &t
+		}
+	}
+
+	return info
+}
+
+// findExifSegment percorre os marcadores do JPEG até o APP1 "Exif".
+func findExifSegment(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		// SOS: a partir daqui só há dados de imagem
+		if marker == 0xDA {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return nil
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:]
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // 4 bytes: valor inline ou offset
+}
+
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16]ifdEntry {
+	entries := map[uint16]ifdEntry{}
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+
+	n := int(order.Uint16(tiff[offset:]))
+	pos := int(offset) + 2
+	for k := 0; k < n && pos+12 <= len(tiff); k++ {
+		e := tiff[pos : pos+12]
+		entries[order.Uint16(e[0:2])] = ifdEntry{
+			typ:   order.Uint16(e[2:4]),
+			count: order.Uint32(e[4:8]),
+			value: e[8:12],
+		}
+		pos += 12
+	}
+	return entries
+}
+
+func (e ifdEntry) short(order binary.ByteOrder) uint16 {
+	if e.value == nil {
+		return 0
+	}
+	return order.Uint16(e.value)
+}
+
+func (e ifdEntry) long(order binary.ByteOrder) uint32 {
+	if e.value == nil {
+		return 0
+	}
+	return order.Uint32(e.value)
+}
+
+func (e ifdEntry) ascii(tiff []byte, order binary.ByteOrder) string {
+	// tipo 2 = ASCII
+	if e.value == nil || e.typ != 2 || e.count == 0 {
+		return ""
+	}
+
+	var raw []byte
+	if e.count <= 4 {
+		raw = e.value[:e.count]
+	} else {
+		off := order.Uint32(e.value)
+		end := uint64(off) + uint64(e.count)
+		if end > uint64(len(tiff)) {
+			return ""
+		}
+		raw = tiff[off:end]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+}
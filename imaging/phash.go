@@ -0,0 +1,67 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"math/bits"
+)
+
+// SHA256 devolve o hash criptográfico (hex) dos bytes do arquivo.
+func SHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DHash calcula o "difference hash" de 64 bits da imagem: reduz para 9x8 em
+// tons de cinza e compara cada pixel com o vizinho da direita. Fotos
+// re-encodadas, redimensionadas ou levemente editadas geram hashes próximos.
+func DHash(img image.Image) uint64 {
+	const w, h = 9, 8
+
+	b := img.Bounds()
+	var gray [h][w]float64
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			// média da região (box filter)
+			var sum float64
+			var n int
+			for yy := y0; yy < y1; yy++ {
+				for xx := x0; xx < x1; xx++ {
+					r, g, bl, _ := img.At(xx, yy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			gray[y][x] = sum / float64(n)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance é a distância de Hamming entre dois hashes perceptuais.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
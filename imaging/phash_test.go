@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// mesmo limite padrão de PHOTO_SIMILARITY_THRESHOLD
+const similarityThreshold = 6
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF, 0},
+		{0, 0xFFFFFFFFFFFFFFFF, 64},
+		{0b1011, 0b0001, 2},
+		{1 << 63, 1, 2},
+	}
+
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Distance(tt.b, tt.a); got != tt.want {
+			t.Errorf("Distance(%#x, %#x) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// gradient pinta uma imagem w x h com o nível de cinza dado por f(x, y).
+func gradient(w, h int, f func(x, y int) uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: f(x, y)})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		want uint64
+	}{
+		{"uniform", gradient(90, 80, func(x, y int) uint8 { return 128 }), 0},
+		{"brighter to the right", gradient(90, 80, func(x, y int) uint8 { return uint8(x * 2) }), 0},
+		{"darker to the right", gradient(90, 80, func(x, y int) uint8 { return uint8(255 - x*2) }), 0xFFFFFFFFFFFFFFFF},
+		// só a metade de cima escurece para a direita
+		{"top half", gradient(90, 80, func(x, y int) uint8 {
+			if y < 40 {
+				return uint8(255 - x*2)
+			}
+			return 128
+		}), 0xFFFFFFFF00000000},
+		// menor que a grade 9x8: cada pixel cobre três colunas da grade
+		{"tiny", gradient(3, 2, func(x, y int) uint8 { return uint8(255 - x*100) }), 0x2424242424242424},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DHash(tt.img); got != tt.want {
+				t.Errorf("DHash = %#016x, want %#016x", got, tt.want)
+			}
+		})
+	}
+}
+
+// A mesma cena em outra resolução fica perto; uma cena diferente, longe.
+func TestDHashSimilarity(t *testing.T) {
+	scene := func(w, h int) image.Image {
+		return gradient(w, h, func(x, y int) uint8 {
+			fx, fy := x*100/w, y*100/h
+			return uint8((fx*fx + fy*3) % 256)
+		})
+	}
+	other := gradient(640, 480, func(x, y int) uint8 { return uint8((y * 7 / 3) % 256) })
+
+	base := DHash(scene(640, 480))
+	if d := Distance(base, DHash(scene(320, 240))); d > similarityThreshold {
+		t.Errorf("resized scene distance = %d, want <= %d", d, similarityThreshold)
+	}
+	if d := Distance(base, DHash(other)); d <= similarityThreshold {
+		t.Errorf("different scene distance = %d, want > %d", d, similarityThreshold)
+	}
+}
@@ -0,0 +1,225 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrNotImage        = errors.New("file is not a valid image")
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooLarge        = errors.New("image dimensions too large")
+)
+
+// MaxPixels limita largura x altura antes de decodificar: um PNG pequeno pode
+// declarar dimensões que ocupariam gigabytes em memória.
+const MaxPixels = 40_000_000
+
+type Options struct {
+	MaxSize   int            // maior lado da imagem final (0 = não redimensiona)
+	ThumbSize int            // maior lado da miniatura (0 = sem miniatura)
+	Location  *time.Location // fuso para datas EXIF sem offset
+}
+
+var DefaultOptions = Options{
+	MaxSize:   1600,
+	ThumbSize: 320,
+	Location:  time.UTC,
+}
+
+type Result struct {
+	ContentType string
+	Ext         string
+	Data        []byte
+	Thumb       []byte
+	Width       int
+	Height      int
+	TakenAt     *time.Time // data de captura do EXIF, se houver
+}
+
+// DetectType identifica o tipo real do arquivo pelos bytes, ignorando o
+// Content-Type enviado pelo cliente.
+func DetectType(data []byte) string {
+	ct := http.DetectContentType(data)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	return strings.TrimSpace(ct)
+}
+
+// IsImage diz se o tipo detectado é uma imagem que o pipeline processa.
+func IsImage(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png"
+}
+
+// Decoded é uma imagem já decodificada, para quem precisa dos pixels (hash)
+// e do pipeline sem decodificar duas vezes.
+type Decoded struct {
+	Image       image.Image
+	ContentType string
+	data        []byte
+}
+
+// Decode confere o tipo pelos bytes e as dimensões declaradas (MaxPixels)
+// antes de decodificar.
+func Decode(data []byte) (*Decoded, error) {
+	ct := DetectType(data)
+	if !IsImage(ct) {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrNotImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrNotImage
+	}
+	return &Decoded{Image: img, ContentType: ct, data: data}, nil
+}
+
+// Process decodifica a imagem e passa pelo pipeline (ver Decoded.Process).
+func Process(data []byte, opts Options) (*Result, error) {
+	d, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return d.Process(opts)
+}
+
+// Process aplica a orientação do EXIF, redimensiona e re-encoda no mesmo
+// formato. O re-encode descarta todo o metadado (EXIF/GPS); a data de captura
+// é devolvida em Result.TakenAt para ser salva no banco.
+func (d *Decoded) Process(opts Options) (*Result, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	img, ct := d.Image, d.ContentType
+	res := &Result{ContentType: ct}
+
+	if ct == "image/jpeg" {
+		info := readExif(d.data, opts.Location)
+		res.TakenAt = info.TakenAt
+		img = applyOrientation(img, info.Orientation)
+	}
+
+	var err error
+
+	img = fit(img, opts.MaxSize)
+	b := img.Bounds()
+	res.Width, res.Height = b.Dx(), b.Dy()
+
+	if res.Data, err = encode(img, ct); err != nil {
+		return nil, err
+	}
+
+	if opts.ThumbSize > 0 {
+		if res.Thumb, err = encode(fit(img, opts.ThumbSize), ct); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Ext = ".jpg"
+	if ct == "image/png" {
+		res.Ext = ".png"
+	}
+
+	return res, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	return buf.Bytes(), err
+}
+
+// fit reduz a imagem para que o maior lado tenha no máximo max pixels.
+func fit(img image.Image, max int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if max <= 0 || (w <= max && h <= max) {
+		return img
+	}
+
+	if w >= h {
+		h = h * max / w
+		w = max
+	} else {
+		w = w * max / h
+		h = max
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// applyOrientation gira/espelha conforme a tag Orientation do EXIF (1..8),
+// já que ela se perde no re-encode.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// 5..8 trocam largura e altura
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,280 @@
+// Package live distribui eventos de presença (batidas, correções e faltas)
+// para os painéis conectados via WebSocket. Os eventos passam pelo
+// LISTEN/NOTIFY do Postgres, então qualquer instância que publica alcança os
+// clientes de todas as instâncias.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Rafhael-Viana/m/db"
+)
+
+const channel = "attendance_events"
+
+const (
+	EventPunch      = "punch"
+	EventCorrection = "correction"
+	EventAbsence    = "absence"
+)
+
+// Situação de um funcionário no painel.
+const (
+	StatusClockedIn = "clocked_in"
+	StatusOnBreak   = "on_break"
+	StatusMissing   = "missing"
+)
+
+type Event struct {
+	TenantID string    `json:"tenant_id"` // preenchido pelo Publish
+	Type     string    `json:"type"`
+	Action   string    `json:"action,omitempty"` // clock_in|clock_out|updated|deleted
+	UserID   string    `json:"user_id"`
+	UserName string    `json:"user_name,omitempty"`
+	SetorID  *string   `json:"setor_id"`
+	PointID  int       `json:"point_id,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Publish manda o evento para todas as instâncias via NOTIFY. O evento é da
+// empresa do contexto.
+func Publish(ctx context.Context, database *db.Database, ev Event) error {
+	ev.TenantID = db.TenantFromContext(ctx)
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = database.Pool().Exec(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload))
+	return err
+}
+
+// Scope define o que um cliente pode ver.
+type Scope struct {
+	Tenant  string          // só eventos desta empresa
+	All     bool            // admin/RH
+	Setores map[string]bool // setores que lidera
+	UserID  string          // sempre vê a si mesmo
+}
+
+func (s Scope) Allows(ev Event) bool {
+	if ev.TenantID != s.Tenant {
+		return false
+	}
+	if s.All || ev.UserID == s.UserID {
+		return true
+	}
+	return ev.SetorID != nil && s.Setores[*ev.SetorID]
+}
+
+type Hub struct {
+	database *db.Database
+
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+}
+
+func NewHub(database *db.Database) *Hub {
+	return &Hub{database: database, clients: map[*client]struct{}{}}
+}
+
+// Listen fica escutando o canal do Postgres e repassa aos clientes locais.
+// Reconecta sozinho se a conexão cair.
+func (h *Hub) Listen(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := h.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Live: LISTEN interrompido (%v), reconectando em %s", err, backoff)
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (h *Hub) listenOnce(ctx context.Context) error {
+	conn, err := h.database.Pool().Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// a conexão fica em estado LISTEN; não devolve para o pool
+	pg := conn.Hijack()
+	defer pg.Close(context.Background())
+
+	if _, err := pg.Exec(ctx, "LISTEN "+channel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := pg.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var ev Event
+		if err := json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+			log.Println("Live: payload inválido:", err)
+			continue
+		}
+		h.broadcast(ev, []byte(n.Payload))
+	}
+}
+
+func (h *Hub) broadcast(ev Event, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients {
+		if !c.scope.Allows(ev) {
+			continue
+		}
+		c.enqueue(payload)
+	}
+}
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+
+	// eventos guardados enquanto o snapshot do cliente é montado
+	backlogSize = 256
+)
+
+type client struct {
+	conn  *websocket.Conn
+	scope Scope
+	send  chan []byte
+
+	// até o snapshot sair, os eventos ficam em backlog (ready = false)
+	mu      sync.Mutex
+	ready   bool
+	backlog [][]byte
+}
+
+// enqueue manda o evento ao cliente, ou guarda no backlog se o snapshot ainda
+// não foi enviado. Cliente lento é derrubado em vez de travar o hub.
+func (c *client) enqueue(payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.ready {
+		if len(c.backlog) >= backlogSize {
+			go c.conn.Close()
+			return
+		}
+		c.backlog = append(c.backlog, payload)
+		return
+	}
+
+	select {
+	case c.send <- payload:
+	default:
+		go c.conn.Close()
+	}
+}
+
+// flush envia o backlog depois do snapshot e passa a entregar direto.
+func (c *client) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, payload := range c.backlog {
+		select {
+		case c.send <- payload:
+		default:
+			go c.conn.Close()
+			return
+		}
+	}
+	c.backlog = nil
+	c.ready = true
+}
+
+// Serve registra a conexão, monta e manda o snapshot inicial e bloqueia até o
+// cliente desconectar. O cliente é registrado antes do snapshot: os eventos
+// que chegam enquanto ele é montado vão depois dele, então nenhum se perde
+// (um evento já refletido no snapshot pode chegar de novo, o que o painel
+// tolera).
+func (h *Hub) Serve(conn *websocket.Conn, scope Scope, snapshot func() (any, error)) {
+	c := &client{conn: conn, scope: scope, send: make(chan []byte, 64)}
+
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		// remove antes de fechar o canal: broadcast só envia com o lock
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+		close(c.send)
+		conn.Close()
+	}()
+
+	items, err := snapshot()
+	if err != nil {
+		log.Println("Live: erro montando snapshot:", err)
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "database error"))
+		return
+	}
+
+	go c.writePump()
+
+	first, _ := json.Marshal(map[string]any{"type": "snapshot", "items": items})
+	c.send <- first
+	c.flush()
+
+	c.readPump()
+}
+
+// readPump só consome frames de controle; o painel não manda comandos.
+func (c *client) readPump() {
+	c.conn.SetReadLimit(1024)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package live
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// O evento publicado enquanto o snapshot é montado chega depois dele.
+func TestServeSnapshotThenBufferedEvents(t *testing.T) {
+	hub := NewHub(nil)
+	scope := Scope{Tenant: "t1", All: true}
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Serve(conn, scope, func() (any, error) {
+			ev := Event{TenantID: "t1", Type: EventPunch, UserID: "u1"}
+			payload, _ := json.Marshal(ev)
+			hub.broadcast(ev, payload)
+			return []string{"row"}, nil
+		})
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var first struct {
+		Type  string   `json:"type"`
+		Items []string `json:"items"`
+	}
+	if err := conn.ReadJSON(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Type != "snapshot" || len(first.Items) != 1 {
+		t.Fatalf("first frame = %+v, want the snapshot", first)
+	}
+
+	var ev Event
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != EventPunch || ev.UserID != "u1" {
+		t.Errorf("second frame = %+v, want the buffered punch", ev)
+	}
+}
+
+func TestClientBacklog(t *testing.T) {
+	c := &client{send: make(chan []byte, 8)}
+
+	c.enqueue([]byte("a"))
+	c.enqueue([]byte("b"))
+	if len(c.send) != 0 {
+		t.Fatalf("sent %d events before the snapshot", len(c.send))
+	}
+
+	c.send <- []byte("snapshot")
+	c.flush()
+	c.enqueue([]byte("c"))
+
+	want := []string{"snapshot", "a", "b", "c"}
+	for _, w := range want {
+		if got := string(<-c.send); got != w {
+			t.Errorf("got %q, want %q", got, w)
+		}
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	s1 := "s1"
+	s2 := "s2"
+	scope := Scope{Tenant: "t1", UserID: "me", Setores: map[string]bool{s1: true}}
+
+	tests := []struct {
+		name string
+		ev   Event
+		want bool
+	}{
+		{"own event", Event{TenantID: "t1", UserID: "me"}, true},
+		{"led setor", Event{TenantID: "t1", UserID: "u", SetorID: &s1}, true},
+		{"other setor", Event{TenantID: "t1", UserID: "u", SetorID: &s2}, false},
+		{"no setor", Event{TenantID: "t1", UserID: "u"}, false},
+		{"other tenant", Event{TenantID: "t2", UserID: "me"}, false},
+	}
+	for _, tt := range tests {
+		if got := scope.Allows(tt.ev); got != tt.want {
+			t.Errorf("%s: Allows = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
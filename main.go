@@ -3,15 +3,25 @@ package main
 import (
 	// "context"
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata" // fusos IANA embutidos, não depende do SO
 
-	// mid "github.com/Rafhael-Viana/Gaart/middleware"
 	"github.com/Rafhael-Viana/m/cors"
 	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/live"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+	"github.com/Rafhael-Viana/m/presence"
+	"github.com/Rafhael-Viana/m/receipt"
+	"github.com/Rafhael-Viana/m/retention"
 	"github.com/Rafhael-Viana/m/routes"
+	"github.com/Rafhael-Viana/m/storage"
+	"github.com/Rafhael-Viana/m/webhooks"
 	"github.com/joho/godotenv"
 )
 
@@ -26,19 +36,45 @@ func main() {
 
 	pool.Ping(context.Background())
 
+	// migrations e jobs enxergam todas as empresas
+	if err := pool.Migrate(db.AllTenants(context.Background())); err != nil {
+		log.Fatalf("Error running migrations: %v", err)
+	}
+	pool.CheckRowSecurity(context.Background())
+
 	mux := http.NewServeMux()
 
-	uploadDir := "./uploads"
+	// Storage dos uploads (STORAGE_DRIVER=local|s3)
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring storage: %v", err)
+	}
 
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		log.Fatalf("Error creating upload dir: %v", err)
+	// status das empresas: suspensão vale para tokens já emitidos em até 30s
+	tenants := mid.NewTenants(pool, 30*time.Second)
+	auth := mid.AuthJWT(os.Getenv("JWT_SECRET"), tenants)
+	admin := func(h http.Handler) http.Handler {
+		return auth(mid.RequireRoles(models.RoleSuperAdmin, models.RoleAdmin)(h))
+	}
+	privileged := func(h http.Handler) http.Handler {
+		return auth(mid.RequireRoles(models.RoleSuperAdmin, models.RoleAdmin, models.RoleRH)(h))
 	}
+	// plataforma: empresas e o que vale para todas elas
+	superadmin := func(h http.Handler) http.Handler { return auth(mid.RequireRoles(models.RoleSuperAdmin)(h)) }
+
+	// Chave que assina os comprovantes de ponto
+	receiptKey, err := receipt.KeyFromEnv()
+	if err != nil {
+		log.Fatalf("Error loading receipt signing key: %v", err)
+	}
+	receiptPub := receiptKey.Public().(ed25519.PublicKey)
+
+	// Assina os QR codes rotativos exibidos nos locais de trabalho
+	presenceSigner := presence.FromEnv()
 
-	// Serve Files Route
-	mux.Handle(
-		"GET /uploads/",
-		http.StripPrefix("/uploads/", http.FileServer(http.Dir(uploadDir))),
-	)
+	// Arquivos: só por URL assinada e temporária (sem listagem de diretórios)
+	mux.Handle("GET /api/files/sign", auth(routes.SignFileURL(pool, store)))
+	mux.Handle("GET /api/files/{key...}", routes.ServeSignedFile(store))
 
 	// Health Check Route
 	mux.Handle("GET /api/hello", http.HandlerFunc(routes.Hello))
@@ -47,31 +83,141 @@ func main() {
 	mux.HandleFunc("POST /api/login", routes.Login(pool))
 
 	// Rotas de CRUD usuários
-	mux.Handle("POST /api/users", routes.CreateUser(pool))
-	mux.Handle("GET /api/users", routes.ListUsers(pool))
-	mux.Handle("GET /api/users/{id}", routes.GetUser(pool))
-	mux.Handle("PATCH /api/users/{id}", routes.UpdateUser(pool))
-	mux.Handle("DELETE /api/users/{id}", routes.DeleteUser(pool)) // /users/{id}
-	mux.HandleFunc("POST /api/users/{id}/upload", routes.UploadUserFile)
+	mux.Handle("POST /api/users", auth(routes.CreateUser(pool)))
+	mux.Handle("GET /api/users", auth(routes.ListUsers(pool)))
+	mux.Handle("GET /api/users/{id}", auth(routes.GetUser(pool)))
+	mux.Handle("PATCH /api/users/{id}", auth(routes.UpdateUser(pool)))
+	mux.Handle("DELETE /api/users/{id}", auth(routes.DeleteUser(pool))) // /users/{id}
+	mux.Handle("POST /api/users/{id}/upload", auth(routes.UploadUserFile(pool, store)))
+	mux.Handle("POST /api/users/import", privileged(routes.ImportUsers(pool)))
+	mux.Handle("GET /api/users/lookup", admin(routes.LookupUser(pool)))
+	mux.Handle("GET /api/users/export", privileged(routes.ExportUsers(pool)))
+	mux.Handle("GET /api/users/{id}/history", auth(routes.ListUserHistory(pool)))
+
+	// Documentos do funcionário (contrato, atestado, ASO, identidade)
+	mux.Handle("GET /api/users/{id}/documents", auth(routes.ListUserDocuments(pool)))
+	mux.Handle("GET /api/users/{id}/documents/{doc}/download", auth(routes.DownloadUserDocument(pool, store)))
+	mux.Handle("PUT /api/users/{id}/documents/{doc}", auth(routes.ReplaceUserDocument(pool, store)))
+	mux.Handle("DELETE /api/users/{id}/documents/{doc}", auth(routes.DeleteUserDocument(pool, store)))
+	mux.Handle("GET /api/documents/expiring", privileged(routes.ListExpiringDocuments(pool)))
+
+	// Tipos de contrato e regras de jornada
+	mux.Handle("GET /api/contract-types", auth(routes.ListContractTypes(pool)))
+	mux.Handle("POST /api/contract-types", superadmin(routes.CreateContractType(pool)))
+	mux.Handle("PATCH /api/contract-types/{code}", superadmin(routes.UpdateContractType(pool)))
+	mux.Handle("DELETE /api/contract-types/{code}", superadmin(routes.DeleteContractType(pool)))
+
+	// Empresas clientes (super-admin)
+	mux.Handle("GET /api/tenants", superadmin(routes.ListTenants(pool)))
+	mux.Handle("POST /api/tenants", superadmin(routes.CreateTenant(pool)))
+	mux.Handle("GET /api/tenants/{id}", superadmin(routes.GetTenant(pool)))
+	mux.Handle("PATCH /api/tenants/{id}", superadmin(routes.UpdateTenant(pool)))
+
+	// Catálogo de cargos (CBO, horário e contrato padrão)
+	mux.Handle("GET /api/cargos", auth(routes.ListCargos(pool)))
+	mux.Handle("POST /api/cargos", admin(routes.CreateCargo(pool)))
+	mux.Handle("POST /api/cargos/migrate", admin(routes.MigrateCargos(pool)))
+	mux.Handle("PATCH /api/cargos/{id}", admin(routes.UpdateCargo(pool)))
+	mux.Handle("DELETE /api/cargos/{id}", admin(routes.DeleteCargo(pool)))
 
 	// Rotas de CRUD Ponto Funcionário
-	mux.Handle("POST /api/points", routes.CreatePoint(pool))
-	mux.Handle("GET /api/points", routes.ListPoints(pool))
-	mux.Handle("GET /api/points/{id}", routes.GetPoint(pool))       // /users/{id}
-	mux.Handle("PATCH /api/points/{id}", routes.UpdatePoint(pool))  // /users/{id}
-	mux.Handle("DELETE /api/points/{id}", routes.DeletePoint(pool)) // /users/{id}
+	mux.Handle("POST /api/points", auth(routes.CreatePoint(pool, store, receiptKey, presenceSigner)))
+	mux.Handle("GET /api/points", auth(routes.ListPoints(pool)))
+	mux.Handle("GET /api/points/{id}", auth(routes.GetPoint(pool)))       // /users/{id}
+	mux.Handle("PATCH /api/points/{id}", auth(routes.UpdatePoint(pool)))  // /users/{id}
+	mux.Handle("DELETE /api/points/{id}", auth(routes.DeletePoint(pool))) // /users/{id}
+
+	// Comprovantes de registro de ponto
+	mux.Handle("GET /api/points/{id}/receipts", auth(routes.ListPointReceipts(pool)))
+	mux.Handle("GET /api/receipts/public-key", routes.ReceiptPublicKey(receiptPub))
+	mux.Handle("POST /api/receipts/verify", routes.VerifyReceiptDocument(pool, receiptPub))
+	mux.Handle("GET /api/receipts/{code}", auth(routes.GetReceipt(pool)))
+	mux.Handle("GET /api/receipts/{code}/verify", routes.VerifyReceipt(pool, receiptPub))
 
 	// Rotas de CRUD Setores
-	mux.Handle("POST /api/setor", routes.CreateSetor(pool))
-	mux.Handle("GET /api/setor", routes.ListSetores(pool))
-	mux.Handle("GET /api/setor/{id}", routes.GetSetor(pool))       // /users/{id}
-	mux.Handle("PATCH /api/setor/{id}", routes.UpdateSetor(pool))  // /users/{id}
-	mux.Handle("DELETE /api/setor/{id}", routes.DeleteSetor(pool)) // /users/{id}
+	mux.Handle("POST /api/setor", auth(routes.CreateSetor(pool)))
+	mux.Handle("GET /api/setor", auth(routes.ListSetores(pool)))
+	mux.Handle("GET /api/setor/{id}", auth(routes.GetSetor(pool)))       // /users/{id}
+	mux.Handle("PATCH /api/setor/{id}", auth(routes.UpdateSetor(pool)))  // /users/{id}
+	mux.Handle("DELETE /api/setor/{id}", auth(routes.DeleteSetor(pool))) // /users/{id}
+	mux.Handle("GET /api/setor/export", privileged(routes.ExportSetores(pool)))
+	mux.Handle("GET /api/setor/org-chart", auth(routes.SetorOrgChart(pool)))
 
 	// Relatórios
-	mux.Handle("GET /api/reports", routes.ReportWork(pool))
-	mux.Handle("GET /api/reports/points", routes.ReportPoints(pool))
-	mux.Handle("GET /api/reports/frequency", routes.ReportFrequency(pool))
+	mux.Handle("GET /api/reports", auth(routes.ReportWork(pool)))
+	mux.Handle("GET /api/reports/points", auth(routes.ReportPoints(pool)))
+	mux.Handle("GET /api/reports/frequency", auth(routes.ReportFrequency(pool)))
+	mux.Handle("GET /api/reports/suspicious-photos", auth(routes.ReportSuspiciousPhotos(pool)))
+	mux.Handle("GET /api/reports/network-flags", auth(routes.ReportNetworkFlags(pool)))
+
+	// LGPD: retenção de fotos/localizações (somente super-admin: as políticas
+	// valem para todas as empresas)
+	retentionJob := retention.NewJob(pool, store)
+
+	mux.Handle("GET /api/retention/policies", superadmin(routes.ListRetentionPolicies(pool)))
+	mux.Handle("PUT /api/retention/policies/{category}", superadmin(routes.UpdateRetentionPolicy(pool)))
+	mux.Handle("POST /api/retention/run", superadmin(routes.RunRetention(retentionJob)))
+	mux.Handle("GET /api/retention/runs", superadmin(routes.ListRetentionRuns(pool)))
+	mux.Handle("GET /api/retention/runs/{id}", superadmin(routes.GetRetentionRun(pool)))
+
+	retentionEvery := 24 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("RETENTION_INTERVAL")); err == nil && d > 0 {
+		retentionEvery = d
+	}
+	go retentionJob.Schedule(context.Background(), retentionEvery)
+
+	// Lixeira: itens excluídos logicamente
+	mux.Handle("GET /api/trash", admin(routes.ListTrash(pool)))
+	mux.Handle("POST /api/trash/{kind}/{id}/restore", admin(routes.RestoreTrash(pool)))
+	mux.Handle("DELETE /api/trash/{kind}/{id}", admin(routes.PurgeTrash(pool, store)))
+
+	// Webhooks de saída (admin)
+	mux.Handle("GET /api/webhooks/events", admin(http.HandlerFunc(routes.ListWebhookEvents)))
+	mux.Handle("POST /api/webhooks", admin(routes.CreateWebhook(pool)))
+	mux.Handle("GET /api/webhooks", admin(routes.ListWebhooks(pool)))
+	mux.Handle("GET /api/webhooks/{id}", admin(routes.GetWebhook(pool)))
+	mux.Handle("PATCH /api/webhooks/{id}", admin(routes.UpdateWebhook(pool)))
+	mux.Handle("DELETE /api/webhooks/{id}", admin(routes.DeleteWebhook(pool)))
+	mux.Handle("POST /api/webhooks/{id}/ping", admin(routes.PingWebhook(pool)))
+	mux.Handle("GET /api/webhooks/{id}/deliveries", admin(routes.ListWebhookDeliveries(pool)))
+	mux.Handle("GET /api/webhook-deliveries/{id}", admin(routes.GetWebhookDelivery(pool)))
+	mux.Handle("POST /api/webhook-deliveries/{id}/redeliver", admin(routes.RedeliverWebhook(pool)))
+
+	webhookEvery := 5 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("WEBHOOK_POLL_INTERVAL")); err == nil && d > 0 {
+		webhookEvery = d
+	}
+	go webhooks.NewDispatcher(pool).Run(context.Background(), webhookEvery)
+
+	// Quiosques compartilhados: cadastro (admin), credenciais do funcionário
+	// e batida identificada por PIN, crachá ou QR
+	mux.Handle("POST /api/kiosks", admin(routes.CreateKiosk(pool)))
+	mux.Handle("GET /api/kiosks", admin(routes.ListKiosks(pool)))
+	mux.Handle("PATCH /api/kiosks/{id}", admin(routes.UpdateKiosk(pool)))
+	mux.Handle("DELETE /api/kiosks/{id}", admin(routes.DeleteKiosk(pool)))
+	mux.Handle("POST /api/users/{id}/pin", auth(routes.GenerateUserPIN(pool)))
+	mux.Handle("PUT /api/users/{id}/badge", auth(routes.SetUserBadge(pool)))
+	mux.Handle("POST /api/users/{id}/qr", auth(routes.RotateUserQR(pool)))
+
+	kiosk := routes.RequireKiosk(pool)
+	mux.Handle("GET /api/kiosk/me", kiosk(http.HandlerFunc(routes.KioskInfo)))
+	mux.Handle("POST /api/kiosk/punch", kiosk(routes.KioskPunch(pool, store, receiptKey)))
+
+	// QR rotativo do local de trabalho (prova de presença)
+	mux.Handle("GET /api/setor/{id}/presence-qr", auth(routes.SetorPresenceQR(pool, presenceSigner)))
+	mux.Handle("GET /api/kiosk/presence-qr", kiosk(routes.KioskPresenceQR(presenceSigner)))
+
+	// Restrição de rede por setor (faixas de IP e BSSIDs de Wi-Fi)
+	mux.Handle("GET /api/setor/{id}/network-rules", admin(routes.ListNetworkRules(pool)))
+	mux.Handle("POST /api/setor/{id}/network-rules", admin(routes.CreateNetworkRule(pool)))
+	mux.Handle("DELETE /api/setor/{id}/network-rules/{rule}", admin(routes.DeleteNetworkRule(pool)))
+
+	// Aparelhos confiáveis: cadastro pelo funcionário, aprovação pelo líder/RH
+	mux.Handle("POST /api/devices", auth(routes.RegisterDevice(pool)))
+	mux.Handle("GET /api/devices", auth(routes.ListDevices(pool)))
+	mux.Handle("POST /api/devices/{id}/approve", auth(routes.DeviceAction(pool, "approve")))
+	mux.Handle("POST /api/devices/{id}/reject", auth(routes.DeviceAction(pool, "reject")))
+	mux.Handle("POST /api/devices/{id}/revoke", auth(routes.DeviceAction(pool, "revoke")))
 
 	// rotas permitidas
 	allowedOrigins := []string{
@@ -79,6 +225,26 @@ func main() {
 		"https://insociable-nicola-eastwardly.ngrok-free.dev",
 		"https://validpr.freedompbx.online",
 	}
+
+	// Painel de presença ao vivo (eventos via LISTEN/NOTIFY)
+	hub := live.NewHub(pool)
+	go hub.Listen(context.Background())
+
+	mux.Handle("GET /api/attendance", auth(routes.AttendanceSnapshot(pool)))
+	mux.Handle("GET /api/attendance/live", routes.AttendanceWS(pool, hub, tenants, os.Getenv("JWT_SECRET"), allowedOrigins, os.Getenv("WS_ALLOW_LOCALHOST") == "true"))
+
+	absenceEvery := time.Minute
+	if d, err := time.ParseDuration(os.Getenv("ABSENCE_CHECK_INTERVAL")); err == nil && d > 0 {
+		absenceEvery = d
+	}
+	go routes.WatchAbsences(context.Background(), pool, absenceEvery)
+
+	documentEvery := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("DOCUMENT_CHECK_INTERVAL")); err == nil && d > 0 {
+		documentEvery = d
+	}
+	go routes.WatchDocumentExpiry(context.Background(), pool, documentEvery)
+
 	handler := cors.Cors(allowedOrigins, true /* usa cookies/credenciais? */)(mux)
 
 	port := os.Getenv("PORT")
@@ -4,11 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Rafhael-Viana/m/db"
 )
 
 type ctxKey string
@@ -19,8 +22,9 @@ const (
 )
 
 type Claims struct {
-	UserID string   `json:"user_id"` // subject (id do usuário)
-	Role   []string `json:"role"`    // opcional
+	UserID   string   `json:"user_id"`   // subject (id do usuário)
+	TenantID string   `json:"tenant_id"` // empresa do usuário
+	Role     []string `json:"role"`      // opcional
 	jwt.RegisteredClaims
 }
 
@@ -45,10 +49,45 @@ func bearerToken(r *http.Request) (string, error) {
 	return parts[1], nil
 }
 
-// AuthJWT valida HS256 e injeta userId/roles no contexto.
-func AuthJWT(secret string) func(http.Handler) http.Handler {
-	secretBytes := []byte(secret)
+// ParseToken valida um JWT HS256 e devolve as claims. Usado pelo AuthJWT e
+// por conexões que não conseguem mandar o header (ex: WebSocket no browser).
+func ParseToken(secret, tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (any, error) {
+		// trava o algoritmo
+		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secret), nil
+	})
+	if err != nil || token == nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	// valida exp (RegisteredClaims já faz, mas aqui reforça)
+	if claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) <= 0 {
+		return nil, errors.New("token expired")
+	}
+
+	// tokens emitidos antes da multiempresa não têm tenant_id
+	if claims.UserID == "" || claims.TenantID == "" {
+		return nil, errors.New("invalid token claims")
+	}
+
+	return claims, nil
+}
+
+// WithClaims injeta userId/roles no contexto e restringe as queries à
+// empresa do token.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	ctx = context.WithValue(ctx, CtxUserID, claims.UserID)
+	ctx = db.WithTenant(ctx, claims.TenantID)
+	return context.WithValue(ctx, CtxRole, claims.Role)
+}
 
+// AuthJWT valida HS256, confere a empresa do token e injeta userId/roles no
+// contexto.
+func AuthJWT(secret string, tenants *Tenants) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			tokenStr, err := bearerToken(r)
@@ -57,38 +96,44 @@ func AuthJWT(secret string) func(http.Handler) http.Handler {
 				return
 			}
 
-			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (any, error) {
-				// trava o algoritmo
-				if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-					return nil, errors.New("unexpected signing method")
-				}
-				return secretBytes, nil
-			})
-			if err != nil || token == nil || !token.Valid {
-				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
-				return
-			}
-
-			// valida exp (RegisteredClaims já faz, mas aqui reforça)
-			if claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) <= 0 {
-				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "token expired"})
+			claims, err := ParseToken(secret, tokenStr)
+			if err != nil {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
 				return
 			}
 
-			if claims.UserID == "" {
-				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid token claims"})
+			// super-admin pode agir em outra empresa
+			override := strings.TrimSpace(r.Header.Get("X-Tenant-ID"))
+			if err := tenants.Check(r.Context(), claims, override); err != nil {
+				status := TenantErrorStatus(err)
+				msg := err.Error()
+				if status == http.StatusInternalServerError {
+					log.Println("DB error checking tenant:", err)
+					msg = "database error"
+				}
+				writeJSON(w, status, map[string]string{"error": msg})
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), CtxUserID, claims.UserID)
-			ctx = context.WithValue(ctx, CtxRole, claims.Role)
-
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
 		})
 	}
 }
 
+// TenantErrorStatus traduz um erro de Tenants.Check para o status HTTP.
+func TenantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errTokenTenant):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrUnknownTenant):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTenantSuspended), errors.Is(err, ErrTenantForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // RequireRoles exige pelo menos 1 role da lista
 func RequireRoles(allowed ...string) func(http.Handler) http.Handler {
 	set := map[string]struct{}{}
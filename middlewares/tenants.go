@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+var (
+	ErrUnknownTenant   = errors.New("unknown tenant")
+	ErrTenantSuspended = errors.New("tenant is suspended")
+	ErrTenantForbidden = errors.New("forbidden")
+
+	// empresa do token não existe mais
+	errTokenTenant = errors.New("invalid token claims")
+)
+
+// Tenants consulta o status das empresas com um cache curto: a suspensão
+// passa a valer para tokens já emitidos em até ttl, sem ir ao banco a cada
+// request.
+type Tenants struct {
+	lookup func(ctx context.Context, tenantID string) (string, error)
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	status map[string]tenantStatus
+}
+
+type tenantStatus struct {
+	status   string // "" quando a empresa não existe
+	loadedAt time.Time
+}
+
+func NewTenants(database *db.Database, ttl time.Duration) *Tenants {
+	return newTenants(func(ctx context.Context, tenantID string) (string, error) {
+		var status string
+		err := database.Pool().QueryRow(db.AllTenants(ctx),
+			`SELECT status FROM tenants WHERE tenant_id = $1`, tenantID,
+		).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return status, err
+	}, ttl)
+}
+
+// newTenants recebe a consulta do status ("" para empresa inexistente).
+func newTenants(lookup func(ctx context.Context, tenantID string) (string, error), ttl time.Duration) *Tenants {
+	return &Tenants{lookup: lookup, ttl: ttl, status: map[string]tenantStatus{}}
+}
+
+// Status devolve o status da empresa ("" se ela não existe).
+func (t *Tenants) Status(ctx context.Context, tenantID string) (string, error) {
+	t.mu.Lock()
+	cached, ok := t.status[tenantID]
+	t.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < t.ttl {
+		return cached.status, nil
+	}
+
+	status, err := t.lookup(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	t.status[tenantID] = tenantStatus{status: status, loadedAt: time.Now()}
+	t.mu.Unlock()
+	return status, nil
+}
+
+// Check confere a empresa do token, que precisa existir e estar ativa, e
+// aplica o X-Tenant-ID (override): só o super-admin pode agir em outra
+// empresa, e ela precisa existir. Acesso a todas as empresas de uma vez não
+// passa por aqui; fica restrito às rotas de empresas (/api/tenants).
+func (t *Tenants) Check(ctx context.Context, claims *Claims, override string) error {
+	status, err := t.Status(ctx, claims.TenantID)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case "":
+		return errTokenTenant
+	case "active":
+	default:
+		return ErrTenantSuspended
+	}
+
+	if override == "" || override == claims.TenantID {
+		return nil
+	}
+	if !slices.Contains(claims.Role, models.RoleSuperAdmin) {
+		return ErrTenantForbidden
+	}
+	status, err = t.Status(ctx, override)
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		return ErrUnknownTenant
+	}
+	claims.TenantID = override
+	return nil
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// fakeTenants devolve o status do mapa e conta as consultas.
+type fakeTenants struct {
+	status  map[string]string
+	lookups int
+	err     error
+}
+
+func (f *fakeTenants) lookup(ctx context.Context, tenantID string) (string, error) {
+	f.lookups++
+	return f.status[tenantID], f.err
+}
+
+func TestTenantsCheck(t *testing.T) {
+	fake := &fakeTenants{status: map[string]string{
+		"acme":   "active",
+		"globex": "active",
+		"frozen": "suspended",
+	}}
+	tenants := newTenants(fake.lookup, time.Minute)
+
+	employee := []string{"user"}
+	admin := []string{models.RoleAdmin}
+	super := []string{models.RoleSuperAdmin}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		roles      []string
+		override   string
+		want       error
+		wantTenant string
+		wantStatus int
+	}{
+		{"own tenant", "acme", employee, "", nil, "acme", 0},
+		{"override equal to own", "acme", admin, "acme", nil, "acme", 0},
+		{"suspended tenant", "frozen", employee, "", ErrTenantSuspended, "", http.StatusForbidden},
+		{"suspended tenant superadmin", "frozen", super, "acme", ErrTenantSuspended, "", http.StatusForbidden},
+		{"deleted tenant", "gone", employee, "", errTokenTenant, "", http.StatusUnauthorized},
+		{"admin override", "acme", admin, "globex", ErrTenantForbidden, "", http.StatusForbidden},
+		{"employee override", "acme", employee, "globex", ErrTenantForbidden, "", http.StatusForbidden},
+		{"superadmin override", "acme", super, "globex", nil, "globex", 0},
+		{"superadmin override to suspended", "acme", super, "frozen", nil, "frozen", 0},
+		{"superadmin override unknown", "acme", super, "nope", ErrUnknownTenant, "", http.StatusBadRequest},
+		{"superadmin override all tenants", "acme", super, "*", ErrUnknownTenant, "", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &Claims{UserID: "u", TenantID: tt.tenant, Role: tt.roles}
+			err := tenants.Check(context.Background(), claims, tt.override)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Check = %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				if got := TenantErrorStatus(err); got != tt.wantStatus {
+					t.Errorf("status = %d, want %d", got, tt.wantStatus)
+				}
+				return
+			}
+			if claims.TenantID != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", claims.TenantID, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestTenantsCache(t *testing.T) {
+	fake := &fakeTenants{status: map[string]string{"acme": "active"}}
+	tenants := newTenants(fake.lookup, 20*time.Millisecond)
+	ctx := context.Background()
+
+	check := func() error {
+		return tenants.Check(ctx, &Claims{UserID: "u", TenantID: "acme"}, "")
+	}
+
+	if err := check(); err != nil {
+		t.Fatal(err)
+	}
+	// suspensa, mas o cache ainda vale
+	fake.status["acme"] = "suspended"
+	if err := check(); err != nil {
+		t.Fatalf("cached check = %v", err)
+	}
+	if fake.lookups != 1 {
+		t.Errorf("lookups = %d, want 1", fake.lookups)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := check(); !errors.Is(err, ErrTenantSuspended) {
+		t.Fatalf("check after ttl = %v, want ErrTenantSuspended", err)
+	}
+	if fake.lookups != 2 {
+		t.Errorf("lookups = %d, want 2", fake.lookups)
+	}
+}
+
+func TestTenantsLookupError(t *testing.T) {
+	fake := &fakeTenants{err: errors.New("connection refused")}
+	tenants := newTenants(fake.lookup, time.Minute)
+
+	err := tenants.Check(context.Background(), &Claims{UserID: "u", TenantID: "acme"}, "")
+	if err == nil || TenantErrorStatus(err) != http.StatusInternalServerError {
+		t.Fatalf("Check = %v, want a 500 error", err)
+	}
+	// erro não entra no cache
+	fake.err = nil
+	fake.status = map[string]string{"acme": "active"}
+	if err := tenants.Check(context.Background(), &Claims{UserID: "u", TenantID: "acme"}, ""); err != nil {
+		t.Errorf("Check after recovery = %v", err)
+	}
+}
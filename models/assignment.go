@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Assignment é um período do vínculo do funcionário: setor, cargo e status
+// valendo de ValidFrom até ValidTo (exclusivo; nil = atual).
+type Assignment struct {
+	ID        int64      `json:"id"`
+	UserID    string     `json:"user_id"`
+	SetorID   *string    `json:"setor_id"`
+	SetorNome *string    `json:"setor_nome"`
+	Cargo     *string    `json:"cargo"`
+	CargoID   *string    `json:"cargo_id"`
+	Status    StatusUser `json:"status"`
+	Contract  *string    `json:"contract_type"`
+	ValidFrom time.Time  `json:"valid_from"`
+	ValidTo   *time.Time `json:"valid_to"`
+	ChangedBy *string    `json:"changed_by"`
+	Reason    *string    `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type Cargo struct {
+	Cargo_ID      string     `json:"cargo_id"`
+	Nome          string     `json:"name"`
+	CBO           *string    `json:"cbo"`                   // 6 dígitos, sem máscara
+	ScheduleStart *string    `json:"schedule_start"`        // HH:MM
+	ScheduleEnd   *string    `json:"schedule_end"`          // HH:MM
+	Contract      *string    `json:"default_contract_type"` // aplicado a quem recebe o cargo
+	Users         *int64     `json:"users,omitempty"`
+	CreatedAt     *time.Time `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
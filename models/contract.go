@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// contrato usado quando nenhum é informado
+const ContractCLT = "clt"
+
+// ContractType define as regras de jornada aplicadas às batidas e relatórios.
+type ContractType struct {
+	Code            string    `json:"code"`
+	Name            string    `json:"name"`
+	DailyMinutes    int       `json:"daily_minutes"`     // jornada normal
+	MaxDailyMinutes int       `json:"max_daily_minutes"` // teto com horas extras
+	OvertimeAllowed bool      `json:"overtime_allowed"`
+	HourBank        bool      `json:"hour_bank"` // excedente vai para o banco de horas
+	Users           *int64    `json:"users,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// DailyLimit é o máximo de minutos por dia: a jornada normal, ou o teto com
+// extras quando o contrato admite.
+func (c ContractType) DailyLimit() int {
+	if c.OvertimeAllowed {
+		return c.MaxDailyMinutes
+	}
+	return c.DailyMinutes
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type DeviceStatus string
+
+const (
+	DevicePending  DeviceStatus = "pending"
+	DeviceApproved DeviceStatus = "approved"
+	DeviceRejected DeviceStatus = "rejected"
+	DeviceRevoked  DeviceStatus = "revoked"
+)
+
+// Algoritmos de chave aceitos. ES256 é o que Secure Enclave e Android
+// Keystore geram; Ed25519 para quem guarda a chave em software.
+const (
+	DeviceKeyEd25519 = "ed25519"
+	DeviceKeyES256   = "es256"
+)
+
+type Device struct {
+	ID           string       `json:"id"`
+	UserID       string       `json:"user_id"`
+	UserName     *string      `json:"user_name,omitempty"`
+	Name         string       `json:"name"`
+	Platform     *string      `json:"platform"`
+	KeyAlg       string       `json:"key_alg"`
+	PublicKey    string       `json:"public_key"`
+	Status       DeviceStatus `json:"status"`
+	LastUsedAt   *time.Time   `json:"last_used_at"`
+	RegisteredAt time.Time    `json:"registered_at"`
+	DecidedBy    *string      `json:"decided_by"`
+	DecidedAt    *time.Time   `json:"decided_at"`
+	RevokedBy    *string      `json:"revoked_by"`
+	RevokedAt    *time.Time   `json:"revoked_at"`
+}
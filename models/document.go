@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type DocumentCategory string
+
+// Categorias de documento do funcionário. Arquivos enviados sem categoria
+// (foto de perfil, áudio) também são registrados, com category null.
+const (
+	DocumentContrato   DocumentCategory = "contrato"
+	DocumentAtestado   DocumentCategory = "atestado"
+	DocumentASO        DocumentCategory = "aso"
+	DocumentIdentidade DocumentCategory = "identidade"
+)
+
+func (c DocumentCategory) Valid() bool {
+	switch c {
+	case DocumentContrato, DocumentAtestado, DocumentASO, DocumentIdentidade:
+		return true
+	}
+	return false
+}
+
+type Document struct {
+	ID          int64             `json:"id"`
+	UserID      string            `json:"user_id"`
+	UserName    *string           `json:"user_name,omitempty"`
+	Category    *DocumentCategory `json:"category"`
+	Filename    *string           `json:"filename"`
+	ContentType string            `json:"content_type"`
+	Size        int64             `json:"size"`
+	SHA256      string            `json:"sha256"`
+	ExpiresOn   *time.Time        `json:"expires_on"`
+	UploadedBy  *string           `json:"uploaded_by"`
+	UploadedAt  time.Time         `json:"uploaded_at"`
+	ReplacedAt  *time.Time        `json:"replaced_at"`
+	TakenAt     *time.Time        `json:"taken_at"` // EXIF das imagens, se houver
+	URL         string            `json:"url,omitempty"`
+	ThumbURL    string            `json:"thumb_url,omitempty"`
+
+	ObjectKey string  `json:"-"`
+	ThumbKey  *string `json:"-"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Kiosk é um tablet compartilhado onde vários funcionários batem ponto.
+type Kiosk struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Setores    []string   `json:"setores"`
+	Active     bool       `json:"active"`
+	Token      string     `json:"token,omitempty"` // só aparece na criação e na troca
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	CreatedBy  *string    `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
@@ -19,6 +19,17 @@ type Point struct {
 	LocationOut string      `json:"location_out"`
 	PhotoIn     string      `json:"photo_in"`
 	PhotoOut    string      `json:"photo_out"`
+	ThumbIn     *string     `json:"thumb_in"`
+	ThumbOut    *string     `json:"thumb_out"`
+	Timezone    string      `json:"timezone"`
 	CreatedAt   *time.Time  `json:"createdAt"`
 	UpdatedAt   *time.Time  `json:"updatedAt"`
 }
+
+// De onde veio a batida.
+type PointSource string
+
+const (
+	PointSourceApp   PointSource = "app"
+	PointSourceKiosk PointSource = "kiosk"
+)
@@ -6,10 +6,15 @@ type Setor struct {
 	ID         int32      `json:"id"`
 	Setor_ID   string     `json:"setor_id"`
 	Lider_ID   *string    `json:"lider_id"`
+	Parent_ID  *string    `json:"parent_id"` // setor acima na hierarquia
 	Nome       string     `json:"name"`
 	Quantidade int32      `json:"qtd_users"`
 	Lider      string     `json:"lider"`
 	CreatedBy  string     `json:"createdBy"`
+	Timezone   *string    `json:"timezone"`
+	PresenceQR bool       `json:"require_presence_qr"` // exige o QR rotativo do local
+	Network    string     `json:"network_policy"`      // off|flag|reject
+	Devices    string     `json:"device_policy"`       // off|flag|reject
 	CreatedAt  *time.Time `json:"createdAt"`
 	UpdatedAt  *time.Time `json:"updatedAt"`
 }
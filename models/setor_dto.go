@@ -15,3 +15,15 @@ type SetorResumo struct {
 	Setor        string              `json:"setor"`
 	Funcionarios []FuncionarioResumo `json:"funcionarios"`
 }
+
+// OrgChartNode é um setor no organograma, com os sub-setores em Children.
+type OrgChartNode struct {
+	Setor_ID       string          `json:"setor_id"`
+	Nome           string          `json:"name"`
+	Parent_ID      *string         `json:"parent_id"`
+	Lider_ID       *string         `json:"lider_id"`
+	Lider          *string         `json:"lider"`
+	Headcount      int64           `json:"headcount"`       // ativos no próprio setor
+	TotalHeadcount int64           `json:"total_headcount"` // incluindo os sub-setores
+	Children       []*OrgChartNode `json:"children"`
+}
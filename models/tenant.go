@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Tenant é uma empresa cliente. Todos os dados (usuários, setores, batidas...)
+// pertencem a uma empresa e só são visíveis para ela.
+type Tenant struct {
+	Tenant_ID string     `json:"tenant_id"`
+	Slug      string     `json:"slug"`     // identificador usado no login
+	Name      string     `json:"name"`     // razão social / nome fantasia
+	CNPJ      *string    `json:"cnpj"`     // 14 dígitos, sem máscara
+	Address   *string    `json:"address"`  // endereço
+	Timezone  *string    `json:"timezone"` // fuso padrão da empresa (IANA)
+	Status    string     `json:"status"`   // active | suspended
+	Users     *int64     `json:"users,omitempty"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
@@ -10,6 +10,16 @@ const (
 	StatusVacations StatusUser = "vacations"
 )
 
+// roles com acesso a dados de qualquer funcionário
+const (
+	RoleAdmin = "admin"
+	RoleRH    = "rh"
+
+	// RoleSuperAdmin administra a plataforma: cadastra empresas e pode agir
+	// em qualquer uma delas (header X-Tenant-ID).
+	RoleSuperAdmin = "superadmin"
+)
+
 type User struct {
 	ID         int32      `json:"id"`
 	User_ID    string     `json:"user_id"`
@@ -17,12 +27,19 @@ type User struct {
 	Senha      string     `json:"senha"`
 	Setor      *string    `json:"setor"`
 	Setor_ID   *string    `json:"setor_id"`
-	Cargo      *string    `json:"cargo"`
+	Cargo      *string    `json:"cargo"`    // nome do cargo, espelhado de cargos
+	CargoID    *string    `json:"cargo_id"` // cargo no catálogo
 	Nascimento *time.Time `json:"birth"`
 	Username   string     `json:"username"`
 	Email      string     `json:"email"`
 	Status     StatusUser `json:"status"`
 	Role       string     `json:"role"`
+	Timezone   *string    `json:"timezone"`
+	CPF        *string    `json:"cpf"`            // só dígitos; mascarado para quem não é admin
+	PIS        *string    `json:"pis"`            // PIS/PASEP/NIS, só dígitos
+	Matricula  *string    `json:"matricula"`      // matrícula na empresa
+	Admissao   *time.Time `json:"admission_date"` // data de admissão
+	Contract   string     `json:"contract_type"`  // código em contract_types
 }
 
 func (u *User) IsValidStatus(status StatusUser) bool {
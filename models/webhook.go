@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookSubscription struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"` // só aparece na criação e na troca do segredo
+	Events      []string  `json:"events"`
+	Description *string   `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedBy   *string   `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64            `json:"id"`
+	SubscriptionID string           `json:"subscription_id"`
+	Event          string           `json:"event"`
+	EventID        string           `json:"event_id"`
+	Payload        json.RawMessage  `json:"payload,omitempty"`
+	Status         string           `json:"status"` // pending|succeeded|failed
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  *time.Time       `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time       `json:"last_attempt_at"`
+	ResponseStatus *int             `json:"response_status"`
+	LastError      *string          `json:"last_error"`
+	RedeliveryOf   *int64           `json:"redelivery_of"`
+	CreatedAt      time.Time        `json:"created_at"`
+	DeliveredAt    *time.Time       `json:"delivered_at"`
+	AttemptLog     []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	Attempt        int       `json:"attempt"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+	ResponseStatus *int      `json:"response_status"`
+	ResponseBody   *string   `json:"response_body"`
+	Error          *string   `json:"error"`
+	DurationMS     int       `json:"duration_ms"`
+}
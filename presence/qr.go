@@ -0,0 +1,103 @@
+// Package presence gera e confere os QR codes rotativos exibidos no local de
+// trabalho. O código muda a cada período, é assinado pelo servidor (HMAC) e
+// amarrado ao setor, então uma foto do QR não serve em outro setor e para de
+// valer em poucos segundos.
+package presence
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	prefix = "pq1"
+
+	DefaultPeriod = 30 * time.Second
+)
+
+var (
+	ErrInvalid = errors.New("invalid presence code")
+	ErrExpired = errors.New("expired presence code")
+)
+
+type Signer struct {
+	secret []byte
+	period time.Duration
+}
+
+func NewSigner(secret []byte, period time.Duration) *Signer {
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+	return &Signer{secret: secret, period: period}
+}
+
+// FromEnv usa PRESENCE_QR_SECRET (ou JWT_SECRET) e PRESENCE_QR_PERIOD.
+func FromEnv() *Signer {
+	secret := os.Getenv("PRESENCE_QR_SECRET")
+	if secret == "" {
+		secret = "presence-qr:" + os.Getenv("JWT_SECRET")
+	}
+
+	period := DefaultPeriod
+	if d, err := time.ParseDuration(os.Getenv("PRESENCE_QR_PERIOD")); err == nil && d >= 5*time.Second {
+		period = d
+	}
+
+	return NewSigner([]byte(secret), period)
+}
+
+func (s *Signer) Period() time.Duration {
+	return s.period
+}
+
+// Window é o número da janela de rotação que contém t.
+func (s *Signer) Window(t time.Time) int64 {
+	return t.UnixNano() / int64(s.period)
+}
+
+func (s *Signer) sign(setorID string, window int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(setorID + "|" + strconv.FormatInt(window, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Code devolve o código do setor para o instante now e quando ele troca.
+// Formato: pq1.<setor_id>.<janela>.<assinatura>
+func (s *Signer) Code(setorID string, now time.Time) (string, time.Time) {
+	window := s.Window(now)
+	code := strings.Join([]string{prefix, setorID, strconv.FormatInt(window, 10), s.sign(setorID, window)}, ".")
+	expires := time.Unix(0, (window+1)*int64(s.period))
+	return code, expires
+}
+
+// Verify confere se o código é do setor e está vigente. A janela anterior
+// também é aceita, para não recusar quem leu o QR logo antes da troca.
+// Devolve a janela do código, usada para barrar reuso.
+func (s *Signer) Verify(code, setorID string, now time.Time) (int64, error) {
+	parts := strings.Split(code, ".")
+	if len(parts) != 4 || parts[0] != prefix || parts[1] != setorID {
+		return 0, ErrInvalid
+	}
+
+	window, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	if !hmac.Equal([]byte(parts[3]), []byte(s.sign(setorID, window))) {
+		return 0, ErrInvalid
+	}
+
+	current := s.Window(now)
+	if window > current || window < current-1 {
+		return 0, ErrExpired
+	}
+	return window, nil
+}
@@ -0,0 +1,68 @@
+package presence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodeVerify(t *testing.T) {
+	s := NewSigner([]byte("secret"), 30*time.Second)
+	issued := time.Date(2026, 5, 4, 12, 0, 10, 0, time.UTC)
+	code, expires := s.Code("setor-1", issued)
+	window := s.Window(issued)
+
+	if want := time.Date(2026, 5, 4, 12, 0, 30, 0, time.UTC); !expires.Equal(want) {
+		t.Errorf("expires = %v, want %v", expires, want)
+	}
+
+	// troca um pedaço do código
+	replace := func(i int, v string) string {
+		parts := strings.Split(code, ".")
+		parts[i] = v
+		return strings.Join(parts, ".")
+	}
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		code    string
+		setorID string
+		now     time.Time
+		want    error
+	}{
+		{"same window", s, code, "setor-1", issued, nil},
+		{"end of window", s, code, "setor-1", expires.Add(-time.Nanosecond), nil},
+		{"previous window still accepted", s, code, "setor-1", expires.Add(29 * time.Second), nil},
+		{"two windows later", s, code, "setor-1", expires.Add(30 * time.Second), ErrExpired},
+		{"from the future", s, code, "setor-1", issued.Add(-time.Minute), ErrExpired},
+		{"wrong setor", s, code, "setor-2", issued, ErrInvalid},
+		{"setor swapped in code", s, replace(1, "setor-2"), "setor-2", issued, ErrInvalid},
+		{"window changed", s, replace(2, "1"), "setor-1", issued, ErrInvalid},
+		{"window not a number", s, replace(2, "x"), "setor-1", issued, ErrInvalid},
+		{"signature tampered", s, replace(3, "AAAAAAAAAAAAAAAAAAAAAA"), "setor-1", issued, ErrInvalid},
+		{"wrong prefix", s, replace(0, "pq0"), "setor-1", issued, ErrInvalid},
+		{"missing part", s, strings.TrimSuffix(code, "."+strings.Split(code, ".")[3]), "setor-1", issued, ErrInvalid},
+		{"other secret", NewSigner([]byte("other"), 30*time.Second), code, "setor-1", issued, ErrInvalid},
+		{"empty", s, "", "setor-1", issued, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.signer.Verify(tt.code, tt.setorID, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.want)
+			}
+			if err == nil && got != window {
+				t.Errorf("window = %d, want %d", got, window)
+			}
+		})
+	}
+}
+
+func TestNewSignerDefaultPeriod(t *testing.T) {
+	if got := NewSigner([]byte("k"), 0).Period(); got != DefaultPeriod {
+		t.Errorf("Period = %v, want %v", got, DefaultPeriod)
+	}
+}
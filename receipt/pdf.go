@@ -0,0 +1,90 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// PDF gera um comprovante em PDF de uma página, só com texto (Helvetica,
+// WinAnsi), sem dependências externas.
+func PDF(r *Receipt, verifyURL string) []byte {
+	loc := r.PunchedAt.Location()
+	lines := []struct {
+		size float64
+		text string
+	}{
+		{14, "Comprovante de Registro de Ponto do Trabalhador"},
+		{10, ""},
+		{10, "Empregador: " + r.CompanyName},
+		{10, "CNPJ: " + r.CompanyCNPJ},
+		{10, "Local: " + r.CompanyAddress},
+		{10, ""},
+		{10, "Trabalhador: " + r.EmployeeName},
+		{10, "Identificador: " + r.UserID},
+		{10, ""},
+		{10, fmt.Sprintf("NSR: %09d", r.NSR)},
+		{10, "Marcação: " + kindLabel(r.Kind)},
+		{10, "Data/hora: " + r.PunchedAt.Format("02/01/2006 15:04:05") + " (" + loc.String() + ")"},
+		{10, ""},
+		{8, "Código: " + r.Code},
+		{8, "Hash SHA-256: " + r.Hash},
+		{8, "Assinatura Ed25519: " + r.Signature[:min(44, len(r.Signature))]},
+		{8, "                    " + r.Signature[min(44, len(r.Signature)):]},
+		{8, ""},
+		{8, "Verifique em: " + verifyURL},
+	}
+
+	var content bytes.Buffer
+	y := 800.0
+	for _, l := range lines {
+		if l.text != "" {
+			fmt.Fprintf(&content, "BT /F1 %.0f Tf 50 %.0f Td (%s) Tj ET\n", l.size, y, pdfString(l.text))
+		}
+		y -= l.size + 8
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.Bytes()
+}
+
+func kindLabel(kind string) string {
+	if kind == "out" {
+		return "Saída"
+	}
+	return "Entrada"
+}
+
+// pdfString converte para Windows-1252 e escapa os caracteres especiais.
+func pdfString(s string) string {
+	enc, err := charmap.Windows1252.NewEncoder().String(s)
+	if err != nil {
+		enc = s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+	return r.Replace(enc)
+}
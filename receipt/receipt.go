@@ -0,0 +1,114 @@
+// Package receipt gera e verifica o comprovante de registro de ponto exigido
+// pela Portaria MTP 671/2021: cada marcação recebe um NSR, e o comprovante é
+// assinado (Ed25519) sobre o hash SHA-256 do seu conteúdo.
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+type Receipt struct {
+	Code           string    `json:"code"`
+	NSR            int64     `json:"nsr"`
+	PointID        int       `json:"point_id"`
+	Kind           string    `json:"kind"` // in|out
+	UserID         string    `json:"user_id"`
+	EmployeeName   string    `json:"employee_name"`
+	CompanyName    string    `json:"company_name"`
+	CompanyCNPJ    string    `json:"company_cnpj"`
+	CompanyAddress string    `json:"company_address"`
+	PunchedAt      time.Time `json:"punched_at"`
+	Timezone       string    `json:"timezone"`
+	Hash           string    `json:"hash"`
+	Signature      string    `json:"signature"`
+}
+
+// Company são os dados do empregador impressos no comprovante.
+type Company struct {
+	Name    string
+	CNPJ    string
+	Address string
+}
+
+func CompanyFromEnv() Company {
+	return Company{
+		Name:    os.Getenv("COMPANY_NAME"),
+		CNPJ:    os.Getenv("COMPANY_CNPJ"),
+		Address: os.Getenv("COMPANY_ADDRESS"),
+	}
+}
+
+// payload é o conteúdo canônico que entra no hash. A ordem dos campos é fixa
+// e o horário vai em UTC com segundos, para que qualquer um consiga
+// recalcular o hash a partir do JSON do comprovante.
+func (r *Receipt) payload() []byte {
+	p := struct {
+		Code           string `json:"code"`
+		NSR            int64  `json:"nsr"`
+		Kind           string `json:"kind"`
+		UserID         string `json:"user_id"`
+		EmployeeName   string `json:"employee_name"`
+		CompanyName    string `json:"company_name"`
+		CompanyCNPJ    string `json:"company_cnpj"`
+		CompanyAddress string `json:"company_address"`
+		PunchedAt      string `json:"punched_at"`
+	}{
+		r.Code, r.NSR, r.Kind, r.UserID, r.EmployeeName,
+		r.CompanyName, r.CompanyCNPJ, r.CompanyAddress,
+		r.PunchedAt.UTC().Format(time.RFC3339),
+	}
+	b, _ := json.Marshal(p)
+	return b
+}
+
+func (r *Receipt) ComputeHash() string {
+	sum := sha256.Sum256(r.payload())
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign preenche Hash e Signature.
+func (r *Receipt) Sign(key ed25519.PrivateKey) {
+	r.Hash = r.ComputeHash()
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(r.Hash)))
+}
+
+// Verify confere se o conteúdo bate com o hash e se a assinatura é válida.
+func (r *Receipt) Verify(pub ed25519.PublicKey) bool {
+	if r.Hash != r.ComputeHash() {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, []byte(r.Hash), sig)
+}
+
+// KeyFromEnv lê RECEIPT_SIGNING_KEY (seed Ed25519 de 32 bytes em base64).
+// Sem ela, a chave é derivada do JWT_SECRET para continuar estável entre
+// reinícios; em produção configure uma chave própria.
+func KeyFromEnv() (ed25519.PrivateKey, error) {
+	if v := os.Getenv("RECEIPT_SIGNING_KEY"); v != "" {
+		seed, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("RECEIPT_SIGNING_KEY must be a base64 encoded 32 byte seed")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("RECEIPT_SIGNING_KEY or JWT_SECRET is required")
+	}
+	log.Println("RECEIPT_SIGNING_KEY não definida, derivando chave dos comprovantes do JWT_SECRET")
+	seed := sha256.Sum256([]byte("receipt-signing:" + secret))
+	return ed25519.NewKeyFromSeed(seed[:]), nil
+}
@@ -0,0 +1,84 @@
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+func testKey(seed string) ed25519.PrivateKey {
+	s := sha256.Sum256([]byte(seed))
+	return ed25519.NewKeyFromSeed(s[:])
+}
+
+func testReceipt(nsr int64) *Receipt {
+	return &Receipt{
+		Code:           "5f0c7a52-8c1b-4c3e-9d5e-2b1f3c4d5e6f",
+		NSR:            nsr,
+		PointID:        10,
+		Kind:           "in",
+		UserID:         "u-1",
+		EmployeeName:   "Ana Souza",
+		CompanyName:    "Empresa Ltda",
+		CompanyCNPJ:    "11222333000181",
+		CompanyAddress: "Rua A, 1",
+		PunchedAt:      time.Date(2026, 3, 2, 8, 0, 5, 0, time.FixedZone("BRT", -3*3600)),
+		Timezone:       "America/Sao_Paulo",
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := testKey("receipts")
+	pub := key.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name   string
+		tamper func(r *Receipt)
+		pub    ed25519.PublicKey
+		want   bool
+	}{
+		{"untouched", func(r *Receipt) {}, pub, true},
+		// o fuso só muda a exibição: o hash usa o horário em UTC
+		{"same instant in UTC", func(r *Receipt) { r.PunchedAt = r.PunchedAt.UTC() }, pub, true},
+		{"nsr changed", func(r *Receipt) { r.NSR++ }, pub, false},
+		{"employee changed", func(r *Receipt) { r.EmployeeName = "Outra Pessoa" }, pub, false},
+		{"kind changed", func(r *Receipt) { r.Kind = "out" }, pub, false},
+		{"time changed", func(r *Receipt) { r.PunchedAt = r.PunchedAt.Add(time.Second) }, pub, false},
+		{"hash replaced", func(r *Receipt) { r.Hash = r.ComputeHash()[1:] + "0" }, pub, false},
+		{"signature not base64", func(r *Receipt) { r.Signature = "***" }, pub, false},
+		{"other key", func(r *Receipt) {}, testKey("other").Public().(ed25519.PublicKey), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testReceipt(1)
+			r.Sign(key)
+			tt.tamper(r)
+			if got := r.Verify(tt.pub); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Cada NSR da sequência gera um comprovante diferente, então um comprovante
+// não pode ser reaproveitado com outro número.
+func TestNSRSequence(t *testing.T) {
+	key := testKey("receipts")
+	seen := map[string]int64{}
+	for nsr := int64(1); nsr <= 50; nsr++ {
+		r := testReceipt(nsr)
+		r.Sign(key)
+		if prev, ok := seen[r.Hash]; ok {
+			t.Fatalf("NSR %d has the same hash as NSR %d", nsr, prev)
+		}
+		seen[r.Hash] = nsr
+
+		moved := *r
+		moved.NSR = nsr + 1
+		if moved.Verify(key.Public().(ed25519.PublicKey)) {
+			t.Errorf("receipt %d still verifies with NSR %d", nsr, nsr+1)
+		}
+	}
+}
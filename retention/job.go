@@ -0,0 +1,466 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/storage"
+)
+
+const (
+	batchSize = 500
+
+	// migration que criou user_documents: documentos enviados antes dela não
+	// têm linha na tabela e nunca contam como órfãos
+	documentsMigration = "0014_user_documents"
+
+	// usos do QR de presença só servem para barrar o mesmo código duas vezes;
+	// depois disso (bem acima da validade de qualquer código) são apagados
+	presenceUseTTL = 24 * time.Hour
+
+	// chave do pg_advisory_lock que impede duas instâncias de rodar juntas
+	advisoryLockKey = 74_031
+)
+
+var ErrAlreadyRunning = errors.New("retention job already running")
+
+type CategoryReport struct {
+	Category        string    `json:"category"`
+	RetentionMonths int       `json:"retention_months"`
+	Cutoff          time.Time `json:"cutoff"`
+	Rows            int       `json:"rows"`
+	Files           int       `json:"files"`
+}
+
+type Report struct {
+	RunID        int64            `json:"run_id"`
+	DryRun       bool             `json:"dry_run"`
+	TriggeredBy  string           `json:"triggered_by"`
+	StartedAt    time.Time        `json:"started_at"`
+	FinishedAt   time.Time        `json:"finished_at"`
+	Categories   []CategoryReport `json:"categories"`
+	OrphanFiles  int              `json:"orphan_files"`
+	PresenceUses int              `json:"presence_qr_uses"`
+	Errors       []string         `json:"errors"`
+}
+
+type Job struct {
+	database *db.Database
+	store    storage.Storage
+}
+
+func NewJob(database *db.Database, store storage.Storage) *Job {
+	return &Job{database: database, store: store}
+}
+
+// Run aplica todas as políticas ativas (inclusive a de arquivos órfãos, que
+// vem desligada). Em dry run nada é apagado, mas o relatório mostra o que
+// seria. As políticas valem para todas as empresas.
+func (j *Job) Run(ctx context.Context, dryRun bool, triggeredBy string) (*Report, error) {
+	ctx = db.AllTenants(ctx)
+
+	conn, err := j.database.Pool().Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, advisoryLockKey).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrAlreadyRunning
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockKey)
+
+	rep := &Report{
+		DryRun:      dryRun,
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+		Categories:  []CategoryReport{},
+		Errors:      []string{},
+	}
+
+	err = j.database.Pool().QueryRow(ctx, `
+		INSERT INTO retention_runs (started_at, dry_run, triggered_by)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, rep.StartedAt, dryRun, triggeredBy).Scan(&rep.RunID)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := LoadPolicies(ctx, j.database)
+	if err != nil {
+		j.finish(rep, err)
+		return rep, err
+	}
+
+	for _, p := range policies {
+		if !p.Enabled {
+			continue
+		}
+
+		cr := CategoryReport{
+			Category:        p.Category,
+			RetentionMonths: p.RetentionMonths,
+			Cutoff:          p.Cutoff(rep.StartedAt),
+		}
+
+		switch p.Category {
+		case CategoryPointPhotos:
+			err = j.purgePhotos(ctx, rep, &cr)
+		case CategoryPointLocations:
+			err = j.purgeLocations(ctx, rep, &cr)
+		case CategoryTrash:
+			err = j.purgeTrash(ctx, rep, &cr)
+		case CategoryOrphanFiles:
+			err = j.purgeOrphans(ctx, rep, &cr)
+		default:
+			err = fmt.Errorf("unknown category %q", p.Category)
+		}
+		if err != nil {
+			rep.Errors = append(rep.Errors, fmt.Sprintf("%s: %v", p.Category, err))
+		}
+
+		rep.Categories = append(rep.Categories, cr)
+	}
+
+	if err := j.prunePresenceUses(ctx, rep); err != nil {
+		rep.Errors = append(rep.Errors, fmt.Sprintf("presence_qr_uses: %v", err))
+	}
+
+	j.finish(rep, nil)
+	return rep, nil
+}
+
+func (j *Job) finish(rep *Report, runErr error) {
+	rep.FinishedAt = time.Now()
+
+	status := "done"
+	if runErr != nil {
+		status = "failed"
+		rep.Errors = append(rep.Errors, runErr.Error())
+	}
+
+	summary, _ := json.Marshal(rep)
+	_, err := j.database.Pool().Exec(context.Background(), `
+		UPDATE retention_runs
+		SET finished_at = $1, status = $2, summary = $3
+		WHERE id = $4
+	`, rep.FinishedAt, status, summary, rep.RunID)
+	if err != nil {
+		log.Println("DB error saving retention run:", err)
+	}
+}
+
+func (j *Job) recordItem(ctx context.Context, rep *Report, category string, pointID *int, key *string) {
+	if rep.DryRun {
+		return
+	}
+	_, err := j.database.Pool().Exec(ctx, `
+		INSERT INTO retention_run_items (run_id, category, point_id, object_key)
+		VALUES ($1, $2, $3, $4)
+	`, rep.RunID, category, pointID, key)
+	if err != nil {
+		log.Println("DB error recording retention item:", err)
+	}
+}
+
+// purgePhotos apaga selfies e miniaturas de batidas anteriores ao corte e limpa
+// as colunas (inclusive os hashes). Os horários da batida são mantidos.
+func (j *Job) purgePhotos(ctx context.Context, rep *Report, cr *CategoryReport) error {
+	lastID := 0
+	for {
+		rows, err := j.database.Pool().Query(ctx, `
+			SELECT id, photo_in, photo_in_thumb, photo_out, photo_out_thumb
+			FROM points
+			WHERE clock_in < $1
+			  AND photos_purged_at IS NULL
+			  AND (photo_in IS NOT NULL OR photo_out IS NOT NULL)
+			  AND id > $2
+			ORDER BY id
+			LIMIT $3
+		`, cr.Cutoff, lastID, batchSize)
+		if err != nil {
+			return err
+		}
+
+		type row struct {
+			id   int
+			urls []*string
+		}
+		batch := []row{}
+		for rows.Next() {
+			var r row
+			var in, inThumb, out, outThumb *string
+			if err := rows.Scan(&r.id, &in, &inThumb, &out, &outThumb); err != nil {
+				rows.Close()
+				return err
+			}
+			r.urls = []*string{in, inThumb, out, outThumb}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		for _, r := range batch {
+			lastID = r.id
+			id := r.id
+
+			failed := false
+			for _, u := range r.urls {
+				if u == nil {
+					continue
+				}
+				key, ok := storage.KeyFromURL(*u)
+				if !ok {
+					continue
+				}
+				if !rep.DryRun {
+					err := j.store.Delete(ctx, key)
+					if err != nil && !errors.Is(err, storage.ErrNotFound) {
+						rep.Errors = append(rep.Errors, fmt.Sprintf("delete %s: %v", key, err))
+						failed = true
+						continue
+					}
+				}
+				cr.Files++
+				j.recordItem(ctx, rep, cr.Category, &id, &key)
+			}
+
+			// se algum arquivo não saiu do storage, a linha fica para a próxima execução
+			if failed || rep.DryRun {
+				if !failed {
+					cr.Rows++
+				}
+				continue
+			}
+
+			_, err := j.database.Pool().Exec(ctx, `
+				UPDATE points
+				SET photo_in = NULL, photo_in_thumb = NULL,
+				    photo_in_sha256 = NULL, photo_in_phash = NULL,
+				    photo_out = NULL, photo_out_thumb = NULL,
+				    photo_out_sha256 = NULL, photo_out_phash = NULL,
+				    photos_purged_at = now()
+				WHERE id = $1
+			`, id)
+			if err != nil {
+				return err
+			}
+			cr.Rows++
+		}
+	}
+}
+
+// purgeLocations remove as localizações de batidas anteriores ao corte.
+func (j *Job) purgeLocations(ctx context.Context, rep *Report, cr *CategoryReport) error {
+	where := `
+		clock_in < $1
+		AND locations_purged_at IS NULL
+		AND (location_in IS NOT NULL OR location_out IS NOT NULL)
+	`
+
+	if rep.DryRun {
+		return j.database.Pool().QueryRow(ctx, `SELECT COUNT(*) FROM points WHERE `+where, cr.Cutoff).Scan(&cr.Rows)
+	}
+
+	rows, err := j.database.Pool().Query(ctx, `
+		UPDATE points
+		SET location_in = NULL, location_out = NULL, locations_purged_at = now()
+		WHERE `+where+`
+		RETURNING id
+	`, cr.Cutoff)
+	if err != nil {
+		return err
+	}
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		id := id
+		j.recordItem(ctx, rep, cr.Category, &id, nil)
+	}
+	cr.Rows = len(ids)
+	return nil
+}
+
+// prunePresenceUses apaga os usos de QR de presença vencidos. Não depende de
+// política: depois que o código expira a linha não tem mais utilidade.
+func (j *Job) prunePresenceUses(ctx context.Context, rep *Report) error {
+	limit := rep.StartedAt.Add(-presenceUseTTL)
+
+	if rep.DryRun {
+		return j.database.Pool().QueryRow(ctx,
+			`SELECT COUNT(*) FROM presence_qr_uses WHERE used_at < $1`, limit,
+		).Scan(&rep.PresenceUses)
+	}
+
+	cmd, err := j.database.Pool().Exec(ctx, `DELETE FROM presence_qr_uses WHERE used_at < $1`, limit)
+	if err != nil {
+		return err
+	}
+	rep.PresenceUses = int(cmd.RowsAffected())
+	return nil
+}
+
+// purgeOrphans apaga as fotos de ponto (points/) que nenhuma linha de points
+// referencia e os documentos de usuário ({id}/...) que nenhuma linha de
+// user_documents referencia. Só entram arquivos anteriores ao corte da
+// política e, no caso dos documentos, gravados depois da migration que criou
+// user_documents: os uploads antigos não têm linha e não são órfãos.
+func (j *Job) purgeOrphans(ctx context.Context, rep *Report, cr *CategoryReport) error {
+	referenced := map[string]struct{}{}
+
+	rows, err := j.database.Pool().Query(ctx, `
+		SELECT u FROM points,
+			LATERAL (VALUES (photo_in), (photo_in_thumb), (photo_out), (photo_out_thumb)) AS v(u)
+		WHERE u IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return err
+		}
+		if key, ok := storage.KeyFromURL(u); ok {
+			referenced[key] = struct{}{}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// documentos guardam a chave, não a URL
+	rows, err = j.database.Pool().Query(ctx, `
+		SELECT k FROM user_documents,
+			LATERAL (VALUES (object_key), (thumb_key)) AS v(k)
+		WHERE k IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		referenced[key] = struct{}{}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var docsSince time.Time
+	err = j.database.Pool().QueryRow(ctx,
+		`SELECT applied_at FROM schema_migrations WHERE version = $1`, documentsMigration,
+	).Scan(&docsSince)
+	if err != nil {
+		return fmt.Errorf("documents migration: %w", err)
+	}
+
+	orphans := []string{}
+	err = j.store.List(ctx, "", func(info storage.Info) error {
+		if _, ok := referenced[info.Key]; ok {
+			return nil
+		}
+		if orphanCandidate(info, cr.Cutoff, docsSince) {
+			orphans = append(orphans, info.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range orphans {
+		key := key
+		if !rep.DryRun {
+			if err := j.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("delete %s: %v", key, err))
+				continue
+			}
+		}
+		cr.Files++
+		rep.OrphanFiles++
+		j.recordItem(ctx, rep, cr.Category, nil, &key)
+	}
+	return nil
+}
+
+// orphanCandidate diz se um arquivo sem referência no banco pode ser apagado.
+// Só valem os layouts que o job sabe conferir: points/... (fotos de ponto) e
+// {id do usuário}/... (documentos). Sem data de modificação nada é apagado.
+func orphanCandidate(info storage.Info, cutoff, docsSince time.Time) bool {
+	if info.ModTime.IsZero() || !info.ModTime.Before(cutoff) {
+		return false
+	}
+
+	dir, _, ok := strings.Cut(info.Key, "/")
+	if !ok {
+		return false
+	}
+	if dir == "points" {
+		return true
+	}
+	if _, err := strconv.ParseUint(dir, 10, 64); err != nil {
+		return false
+	}
+	return info.ModTime.After(docsSince)
+}
+
+// Schedule roda o job a cada intervalo até o contexto ser cancelado.
+func (j *Job) Schedule(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rep, err := j.Run(ctx, false, "scheduler")
+			switch {
+			case errors.Is(err, ErrAlreadyRunning):
+				// outra instância está rodando
+			case err != nil:
+				log.Println("Retention job error:", err)
+			default:
+				log.Printf("Retention job #%d: %d categorias, %d órfãos, %d erros",
+					rep.RunID, len(rep.Categories), rep.OrphanFiles, len(rep.Errors))
+			}
+		}
+	}
+}
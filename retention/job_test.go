@@ -0,0 +1,38 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Rafhael-Viana/m/storage"
+)
+
+func TestOrphanCandidate(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cutoff := now.AddDate(0, -1, 0)
+	docsSince := now.AddDate(0, -6, 0)
+
+	old := cutoff.Add(-time.Hour)
+	tests := []struct {
+		name string
+		key  string
+		mod  time.Time
+		want bool
+	}{
+		{"old point photo", "points/7/in/a.jpg", old, true},
+		{"recent point photo", "points/7/in/a.jpg", cutoff.Add(time.Hour), false},
+		{"unknown mod time", "points/7/in/a.jpg", time.Time{}, false},
+		{"document after migration", "7/docs/a.pdf", old, true},
+		{"document before migration", "7/docs/a.pdf", docsSince.Add(-time.Hour), false},
+		{"unmanaged prefix", "exports/a.csv", old, false},
+		{"root file", "a.jpg", old, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := storage.Info{Key: tt.key, ModTime: tt.mod}
+			if got := orphanCandidate(info, cutoff, docsSince); got != tt.want {
+				t.Errorf("orphanCandidate(%q, %v) = %v, want %v", tt.key, tt.mod, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/Rafhael-Viana/m/db"
+)
+
+// Categorias de dado pessoal com retenção configurável.
+const (
+	CategoryPointPhotos    = "point_photos"    // selfies de entrada/saída e miniaturas
+	CategoryPointLocations = "point_locations" // location_in/location_out
+	CategoryTrash          = "trash"           // usuários, setores e batidas excluídos
+	CategoryOrphanFiles    = "orphan_files"    // arquivos do storage sem linha no banco
+)
+
+var Categories = []string{CategoryPointPhotos, CategoryPointLocations, CategoryTrash, CategoryOrphanFiles}
+
+func ValidCategory(c string) bool {
+	for _, v := range Categories {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+type Policy struct {
+	Category        string     `json:"category"`
+	RetentionMonths int        `json:"retention_months"`
+	Enabled         bool       `json:"enabled"`
+	UpdatedAt       *time.Time `json:"updated_at"`
+}
+
+// Cutoff é o instante antes do qual os dados da categoria devem ser apagados.
+func (p Policy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, -p.RetentionMonths, 0)
+}
+
+func LoadPolicies(ctx context.Context, database *db.Database) ([]Policy, error) {
+	rows, err := database.Pool().Query(ctx, `
+		SELECT category, retention_months, enabled, updated_at
+		FROM retention_policies
+		ORDER BY category
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []Policy{}
+	for rows.Next() {
+		var p Policy
+		if err := rows.Scan(&p.Category, &p.RetentionMonths, &p.Enabled, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// LoadPolicy busca a política de uma categoria.
+func LoadPolicy(ctx context.Context, database *db.Database, category string) (Policy, error) {
+	p := Policy{Category: category}
+	err := database.Pool().QueryRow(ctx, `
+		SELECT retention_months, enabled, updated_at
+		FROM retention_policies
+		WHERE category = $1
+	`, category).Scan(&p.RetentionMonths, &p.Enabled, &p.UpdatedAt)
+	return p, err
+}
+
+func SavePolicy(ctx context.Context, database *db.Database, p Policy) error {
+	_, err := database.Pool().Exec(ctx, `
+		INSERT INTO retention_policies (category, retention_months, enabled, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (category) DO UPDATE
+		SET retention_months = EXCLUDED.retention_months,
+		    enabled = EXCLUDED.enabled,
+		    updated_at = now()
+	`, p.Category, p.RetentionMonths, p.Enabled)
+	return err
+}
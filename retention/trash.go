@@ -0,0 +1,169 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/storage"
+)
+
+// Tabelas com exclusão lógica (deleted_at) e a coluna que identifica a linha.
+var TrashTables = map[string]string{
+	"users":   "id",
+	"setores": "setor_id",
+	"points":  "id",
+}
+
+// Ordem de remoção: batidas antes de usuários e setores.
+var trashOrder = []string{"points", "users", "setores"}
+
+var ErrNotTrashed = errors.New("item is not in trash")
+
+// PurgeTrashed apaga de vez uma linha que já está na lixeira. No caso de
+// batidas, os comprovantes e as fotos vão junto; no de usuários, os
+// documentos (registros e arquivos) e o histórico de vínculo. Os arquivos só
+// são apagados depois do commit; se algum falhar, fica órfão e sai no job.
+func PurgeTrashed(ctx context.Context, database *db.Database, store storage.Storage, kind, key string) error {
+	column, ok := TrashTables[kind]
+	if !ok {
+		return fmt.Errorf("unknown trash kind %q", kind)
+	}
+
+	tx, err := database.Pool().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	objects := []string{}
+
+	if kind == "points" {
+		_, err = tx.Exec(ctx, `
+			DELETE FROM point_receipts
+			WHERE point_id IN (SELECT id FROM points WHERE id::text = $1 AND deleted_at IS NOT NULL)
+		`, key)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT u FROM points,
+				LATERAL (VALUES (photo_in), (photo_in_thumb), (photo_out), (photo_out_thumb)) AS v(u)
+			WHERE id::text = $1 AND deleted_at IS NOT NULL AND u IS NOT NULL
+		`, key)
+		if err != nil {
+			return err
+		}
+		urls, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		for _, u := range urls {
+			if k, ok := storage.KeyFromURL(u); ok {
+				objects = append(objects, k)
+			}
+		}
+	}
+
+	if kind == "users" {
+		rows, err := tx.Query(ctx, `
+			DELETE FROM user_documents
+			WHERE user_id IN (SELECT user_id FROM users WHERE id::text = $1 AND deleted_at IS NOT NULL)
+			RETURNING object_key, thumb_key
+		`, key)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var object string
+			var thumb *string
+			if err := rows.Scan(&object, &thumb); err != nil {
+				rows.Close()
+				return err
+			}
+			objects = append(objects, object)
+			if thumb != nil {
+				objects = append(objects, *thumb)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			DELETE FROM user_assignments
+			WHERE user_id IN (SELECT user_id FROM users WHERE id::text = $1 AND deleted_at IS NOT NULL)
+		`, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	cmd, err := tx.Exec(ctx, `DELETE FROM `+kind+` WHERE `+column+`::text = $1 AND deleted_at IS NOT NULL`, key)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotTrashed
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if err := store.Delete(ctx, object); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Storage error purging %s/%s (%s): %v", kind, key, object, err)
+		}
+	}
+	return nil
+}
+
+// purgeTrash remove definitivamente o que está na lixeira desde antes do corte.
+func (j *Job) purgeTrash(ctx context.Context, rep *Report, cr *CategoryReport) error {
+	for _, kind := range trashOrder {
+		column := TrashTables[kind]
+
+		rows, err := j.database.Pool().Query(ctx, `
+			SELECT `+column+`::text FROM `+kind+`
+			WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			LIMIT $2
+		`, cr.Cutoff, batchSize)
+		if err != nil {
+			return err
+		}
+		keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if !rep.DryRun {
+				if err := PurgeTrashed(ctx, j.database, j.store, kind, key); err != nil {
+					if !errors.Is(err, ErrNotTrashed) {
+						rep.Errors = append(rep.Errors, fmt.Sprintf("purge %s/%s: %v", kind, key, err))
+					}
+					continue
+				}
+				j.recordRef(ctx, rep, cr.Category, kind+"/"+key)
+			}
+			cr.Rows++
+		}
+	}
+	return nil
+}
+
+func (j *Job) recordRef(ctx context.Context, rep *Report, category, ref string) {
+	_, err := j.database.Pool().Exec(ctx, `
+		INSERT INTO retention_run_items (run_id, category, ref)
+		VALUES ($1, $2, $3)
+	`, rep.RunID, category, ref)
+	if err != nil {
+		log.Println("DB error recording retention item:", err)
+	}
+}
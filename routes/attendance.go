@@ -0,0 +1,336 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/live"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+)
+
+type AttendanceRow struct {
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	SetorID   *string    `json:"setor_id"`
+	Setor     *string    `json:"setor"`
+	Status    string     `json:"status"` // clocked_in|on_break|missing
+	LastPunch *time.Time `json:"last_punch"`
+}
+
+// attendanceScope: admin/RH veem todos, líderes veem os setores que lideram
+// (com os sub-setores) e todo mundo vê a si mesmo.
+func attendanceScope(ctx context.Context, database *db.Database, callerID string, roles []string) (live.Scope, error) {
+	scope := live.Scope{Tenant: db.TenantFromContext(ctx), UserID: callerID, Setores: map[string]bool{}}
+	if isPrivileged(roles) {
+		scope.All = true
+		return scope, nil
+	}
+
+	rows, err := database.Pool().Query(ctx, ledSetoresSQL(1), callerID)
+	if err != nil {
+		return scope, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return scope, err
+		}
+		scope.Setores[id] = true
+	}
+	return scope, rows.Err()
+}
+
+// attendanceSnapshot monta a situação atual de cada funcionário ativo visível.
+// "Hoje" é o dia local do funcionário.
+func attendanceSnapshot(ctx context.Context, database *db.Database, scope live.Scope) ([]AttendanceRow, error) {
+	setores := []string{}
+	for id := range scope.Setores {
+		setores = append(setores, id)
+	}
+
+	rows, err := database.Pool().Query(ctx, `
+		WITH us AS (
+			SELECT u.user_id, u.name, u.setor_id, s.nome AS setor,
+				`+effectiveTimezoneSQL(1)+` AS tz
+			FROM users u
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
+			WHERE u.status = 'active' AND u.deleted_at IS NULL
+			  AND ($2 OR u.user_id = $3 OR u.setor_id = ANY($4))
+		)
+		SELECT
+			us.user_id, us.name, us.setor_id, us.setor,
+			CASE
+				WHEN EXISTS (SELECT 1 FROM points p WHERE p.user_id = us.user_id AND p.status = 'open' AND p.deleted_at IS NULL) THEN 'clocked_in'
+				WHEN last.at IS NOT NULL THEN 'on_break'
+				ELSE 'missing'
+			END,
+			last.at
+		FROM us
+		LEFT JOIN LATERAL (
+			SELECT MAX(GREATEST(p.clock_in, COALESCE(p.clock_out, p.clock_in))) AS at
+			FROM points p
+			WHERE p.user_id = us.user_id
+			  AND p.deleted_at IS NULL
+			  AND (p.clock_in AT TIME ZONE us.tz)::date = (now() AT TIME ZONE us.tz)::date
+		) last ON true
+		ORDER BY us.setor NULLS LAST, us.name
+	`, defaultTimezone(), scope.All, scope.UserID, setores)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []AttendanceRow{}
+	for rows.Next() {
+		var a AttendanceRow
+		if err := rows.Scan(&a.UserID, &a.Name, &a.SetorID, &a.Setor, &a.Status, &a.LastPunch); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// publishAttendance resolve nome/setor do funcionário e publica o evento.
+// Falhas só são logadas: o painel não pode derrubar a batida.
+func publishAttendance(ctx context.Context, database *db.Database, ev live.Event) {
+	// status recalculado no banco para refletir também correções e exclusões
+	var status string
+	err := database.Pool().QueryRow(ctx, `
+		SELECT u.name, u.setor_id,
+			CASE
+				WHEN EXISTS (SELECT 1 FROM points p WHERE p.user_id = u.user_id AND p.status = 'open' AND p.deleted_at IS NULL) THEN 'clocked_in'
+				WHEN EXISTS (
+					SELECT 1 FROM points p
+					WHERE p.user_id = u.user_id
+					  AND p.deleted_at IS NULL
+					  AND (p.clock_in AT TIME ZONE `+effectiveTimezoneSQL(2)+`)::date
+					    = (now() AT TIME ZONE `+effectiveTimezoneSQL(2)+`)::date
+				) THEN 'on_break'
+				ELSE 'missing'
+			END
+		FROM users u
+		LEFT JOIN setores s ON s.setor_id = u.setor_id
+		WHERE u.user_id = $1
+	`, ev.UserID, defaultTimezone()).Scan(&ev.UserName, &ev.SetorID, &status)
+	if err == nil && ev.Status == "" {
+		ev.Status = status
+	}
+	if err != nil {
+		log.Println("DB error loading user for live event:", err)
+	}
+
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	if err := live.Publish(ctx, database, ev); err != nil {
+		log.Println("DB error publishing live event:", err)
+	}
+}
+
+// GET /api/attendance — mesmo snapshot do WebSocket, para quem só quer consultar
+func AttendanceSnapshot(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		callerID, _ := mid.UserIDFromContext(r.Context())
+		roles, _ := mid.RoleFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		scope, err := attendanceScope(ctx, database, callerID, roles)
+		if err != nil {
+			log.Println("DB error loading attendance scope:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		items, err := attendanceSnapshot(ctx, database, scope)
+		if err != nil {
+			log.Println("DB error loading attendance:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, items)
+	}
+}
+
+// GET /api/attendance/live (WebSocket)
+//
+// O browser não manda Authorization no handshake, então o token vai no
+// Sec-WebSocket-Protocol: new WebSocket(url, ["bearer", token]). Token na URL
+// não é aceito (acabaria em logs de proxy). O primeiro frame é o snapshot;
+// depois chegam os eventos. allowLocalhost libera qualquer origem localhost,
+// só para desenvolvimento (WS_ALLOW_LOCALHOST=true).
+func AttendanceWS(database *db.Database, hub *live.Hub, tenants *mid.Tenants, secret string, allowedOrigins []string, allowLocalhost bool) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 4096,
+		Subprotocols:    []string{wsBearerProtocol},
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return true
+			}
+			for _, o := range allowedOrigins {
+				if o == origin {
+					return true
+				}
+			}
+			return allowLocalhost &&
+				(strings.HasPrefix(origin, "http://localhost:") || strings.HasPrefix(origin, "http://127.0.0.1:"))
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := mid.ParseToken(secret, wsToken(r))
+		if err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := tenants.Check(r.Context(), claims, ""); err != nil {
+			status := mid.TenantErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				log.Println("DB error checking tenant:", err)
+				http.Error(w, "database error", status)
+				return
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(mid.WithClaims(r.Context(), claims), 5*time.Second)
+		defer cancel()
+
+		scope, err := attendanceScope(ctx, database, claims.UserID, claims.Role)
+		if err != nil {
+			log.Println("DB error loading attendance scope:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade já respondeu ao cliente
+			return
+		}
+
+		// o snapshot é montado depois do registro no hub (ver Hub.Serve)
+		hub.Serve(conn, scope, func() (any, error) {
+			return attendanceSnapshot(ctx, database, scope)
+		})
+	}
+}
+
+// subprotocolo que carrega o token no handshake: "bearer, <token>"
+const wsBearerProtocol = "bearer"
+
+// wsToken lê o JWT do Authorization (clientes fora do browser) ou do
+// Sec-WebSocket-Protocol, logo depois de "bearer".
+func wsToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	protocols := websocket.Subprotocols(r)
+	for i, p := range protocols {
+		if p == wsBearerProtocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// horário local a partir do qual quem não bateu ponto vira falta (HH:MM)
+func absenceCutoff() (int, int) {
+	if t, err := time.Parse("15:04", os.Getenv("ABSENCE_AFTER")); err == nil {
+		return t.Hour(), t.Minute()
+	}
+	return 10, 0
+}
+
+// WatchAbsences publica um evento de falta para cada funcionário ativo que
+// passou do horário de corte (no fuso dele) sem nenhuma batida no dia. Fins de
+// semana são ignorados. attendance_absences garante um aviso por pessoa/dia
+// mesmo com várias instâncias rodando. Cada empresa é verificada à parte.
+func WatchAbsences(ctx context.Context, database *db.Database, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := database.ForEachTenant(ctx, func(ctx context.Context, _ string) error {
+				return notifyAbsences(ctx, database)
+			})
+			if err != nil {
+				log.Println("Absence watcher error:", err)
+			}
+		}
+	}
+}
+
+func notifyAbsences(ctx context.Context, database *db.Database) error {
+	hour, minute := absenceCutoff()
+
+	rows, err := database.Pool().Query(ctx, `
+		WITH us AS (
+			SELECT u.user_id, `+effectiveTimezoneSQL(1)+` AS tz
+			FROM users u
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
+			WHERE u.status = 'active' AND u.deleted_at IS NULL
+		), due AS (
+			SELECT us.user_id, (now() AT TIME ZONE us.tz)::date AS day
+			FROM us
+			WHERE EXTRACT(ISODOW FROM now() AT TIME ZONE us.tz) < 6
+			  AND (now() AT TIME ZONE us.tz)::time >= make_time($2, $3, 0)
+			  AND NOT EXISTS (
+				SELECT 1 FROM points p
+				WHERE p.user_id = us.user_id
+				  AND p.deleted_at IS NULL
+				  AND (p.clock_in AT TIME ZONE us.tz)::date = (now() AT TIME ZONE us.tz)::date
+			  )
+		)
+		INSERT INTO attendance_absences (user_id, day)
+		SELECT user_id, day FROM due
+		ON CONFLICT DO NOTHING
+		RETURNING user_id
+	`, defaultTimezone(), hour, minute)
+	if err != nil {
+		return err
+	}
+
+	users := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range users {
+		publishAttendance(ctx, database, live.Event{
+			Type:   live.EventAbsence,
+			UserID: id,
+			Status: live.StatusMissing,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWSToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		target string
+		want   string
+	}{
+		{"authorization", map[string]string{"Authorization": "Bearer abc"}, "/", "abc"},
+		{"subprotocol", map[string]string{"Sec-WebSocket-Protocol": "bearer, abc.def.ghi"}, "/", "abc.def.ghi"},
+		{"subprotocol without token", map[string]string{"Sec-WebSocket-Protocol": "bearer"}, "/", ""},
+		{"other subprotocol", map[string]string{"Sec-WebSocket-Protocol": "chat, abc"}, "/", ""},
+		{"query string is ignored", nil, "/?token=abc", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := wsToken(r); got != tt.want {
+				t.Errorf("wsToken = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
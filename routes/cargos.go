@@ -0,0 +1,588 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// cargoRequest é o corpo do POST e do PATCH de cargo.
+type cargoRequest struct {
+	Name          optional[string] `json:"name"`
+	CBO           optional[string] `json:"cbo"`
+	ScheduleStart optional[string] `json:"schedule_start"`
+	ScheduleEnd   optional[string] `json:"schedule_end"`
+	Contract      optional[string] `json:"default_contract_type"`
+}
+
+// validate aplica o corpo sobre c (o cargo atual no PATCH, zerado no POST).
+func (req cargoRequest) validate(c *models.Cargo, create bool) (cols []column, errs fieldErrors) {
+	set := func(name string, value any) { cols = append(cols, column{name, value}) }
+
+	if req.Name.ok(&errs, "name", false) {
+		c.Nome = strings.Join(strings.Fields(req.Name.Value), " ")
+		switch {
+		case c.Nome == "":
+			errs.add("name", "is required")
+		case len(c.Nome) > 100:
+			errs.add("name", "is too long")
+		}
+		set("nome", c.Nome)
+	} else if create && !req.Name.Set {
+		errs.add("name", "is required")
+	}
+
+	// CBO com ou sem máscara (2124-05); guarda só os dígitos
+	if req.CBO.ok(&errs, "cbo", true) {
+		c.CBO = nullIfEmpty(strings.TrimSpace(emptyIfNull(req.CBO.Ptr())))
+		if c.CBO != nil {
+			d := onlyDigits(*c.CBO)
+			if len(d) != 6 || len(strings.Trim(*c.CBO, "0123456789-. ")) > 0 {
+				errs.add("cbo", "must have 6 digits")
+			}
+			c.CBO = &d
+		}
+		set("cbo", c.CBO)
+	}
+
+	clock := func(field string, o optional[string], target **string) {
+		if !o.ok(&errs, field, true) {
+			return
+		}
+		v := nullIfEmpty(strings.TrimSpace(emptyIfNull(o.Ptr())))
+		if v != nil {
+			t, err := time.Parse("15:04", *v)
+			if err != nil {
+				errs.add(field, "must be a time (HH:MM)")
+				return
+			}
+			s := t.Format("15:04")
+			v = &s
+		}
+		*target = v
+		set(field, v)
+	}
+	clock("schedule_start", req.ScheduleStart, &c.ScheduleStart)
+	clock("schedule_end", req.ScheduleEnd, &c.ScheduleEnd)
+	if (c.ScheduleStart == nil) != (c.ScheduleEnd == nil) {
+		errs.add("schedule_end", "schedule_start and schedule_end go together")
+	}
+
+	if req.Contract.ok(&errs, "default_contract_type", true) {
+		c.Contract = nullIfEmpty(strings.ToLower(strings.TrimSpace(emptyIfNull(req.Contract.Ptr()))))
+		set("default_contract_type", c.Contract)
+	}
+
+	return cols, errs
+}
+
+const cargoColumns = `cargo_id, nome, cbo, to_char(schedule_start, 'HH24:MI'), to_char(schedule_end, 'HH24:MI'),
+	default_contract_type, created_at, updated_at`
+
+func scanCargo(row pgx.Row, c *models.Cargo, extra ...any) error {
+	return row.Scan(append([]any{&c.Cargo_ID, &c.Nome, &c.CBO, &c.ScheduleStart, &c.ScheduleEnd,
+		&c.Contract, &c.CreatedAt, &c.UpdatedAt}, extra...)...)
+}
+
+// cargoKey é o nome comparável do cargo: minúsculo, sem acentos e com um
+// espaço só entre as palavras ("Auxiliar  Técnico" e "auxiliar tecnico"
+// são o mesmo cargo).
+func cargoKey(s string) string {
+	return strings.Join(strings.Fields(accentReplacer.Replace(strings.ToLower(s))), " ")
+}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// cargoCatalog indexa os cargos por ID e por cargoKey do nome.
+type cargoCatalog struct {
+	byID  map[string]models.Cargo
+	byKey map[string]models.Cargo
+}
+
+func loadCargos(ctx context.Context, database *db.Database) (cargoCatalog, error) {
+	cat := cargoCatalog{byID: map[string]models.Cargo{}, byKey: map[string]models.Cargo{}}
+	rows, err := database.Pool().Query(ctx, `SELECT `+cargoColumns+` FROM cargos`)
+	if err != nil {
+		return cat, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Cargo
+		if err := scanCargo(rows, &c); err != nil {
+			return cat, err
+		}
+		cat.byID[c.Cargo_ID] = c
+		cat.byKey[cargoKey(c.Nome)] = c
+	}
+	return cat, rows.Err()
+}
+
+// find procura pelo ID e, se não achar, pelo nome.
+func (cat cargoCatalog) find(ref string) (models.Cargo, bool) {
+	if c, ok := cat.byID[ref]; ok {
+		return c, true
+	}
+	c, ok := cat.byKey[cargoKey(ref)]
+	return c, ok
+}
+
+// resolveUserCargo liga o usuário a um cargo do catálogo, por cargo_id ou
+// pelo nome em cargo (sem diferenciar maiúsculas e acentos), e acrescenta
+// cargo_id e cargo às colunas. Sem contract_type no corpo, vale o contrato
+// padrão do cargo.
+func resolveUserCargo(ctx context.Context, database *db.Database, req userRequest, u *models.User, cols *[]column, errs *fieldErrors) error {
+	field, o := "cargo_id", req.CargoID
+	if !o.Set {
+		field, o = "cargo", req.Cargo
+	}
+	if !o.ok(errs, field, true) {
+		return nil
+	}
+
+	ref := strings.TrimSpace(emptyIfNull(o.Ptr()))
+	if ref == "" {
+		u.CargoID, u.Cargo = nil, nil
+		*cols = append(*cols, column{"cargo_id", nil}, column{"cargo", nil})
+		return nil
+	}
+
+	cat, err := loadCargos(ctx, database)
+	if err != nil {
+		return err
+	}
+	var c models.Cargo
+	var found bool
+	if field == "cargo_id" {
+		c, found = cat.byID[ref]
+	} else {
+		c, found = cat.byKey[cargoKey(ref)]
+	}
+	if !found {
+		if field == "cargo_id" {
+			errs.add(field, "cargo not found")
+		} else {
+			errs.add(field, "is not in the positions catalog (use cargo_id)")
+		}
+		return nil
+	}
+
+	u.CargoID, u.Cargo = &c.Cargo_ID, &c.Nome
+	*cols = append(*cols, column{"cargo_id", u.CargoID}, column{"cargo", u.Cargo})
+	if c.Contract != nil && !req.Contract.Set {
+		u.Contract = *c.Contract
+		*cols = append(*cols, column{"contract_type", u.Contract})
+	}
+	return nil
+}
+
+// GET /api/cargos?q=
+func ListCargos(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+cargoColumns+`,
+				(SELECT COUNT(*) FROM users u WHERE u.cargo_id = c.cargo_id AND u.deleted_at IS NULL)
+			FROM cargos c
+			WHERE $1 = '' OR nome ILIKE '%' || $1 || '%' OR cbo = $1
+			ORDER BY nome
+		`, escapeLike(strings.TrimSpace(r.URL.Query().Get("q"))))
+		if err != nil {
+			log.Println("DB error listing cargos:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		out := []models.Cargo{}
+		for rows.Next() {
+			var c models.Cargo
+			var users int64
+			if err := scanCargo(rows, &c, &users); err != nil {
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			c.Users = &users
+			out = append(out, c)
+		}
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// POST /api/cargos
+func CreateCargo(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req cargoRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		var c models.Cargo
+		_, errs := req.validate(&c, true)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if c.Contract != nil && len(errs) == 0 {
+			if err := checkContractType(ctx, database, &errs, *c.Contract); err != nil {
+				log.Println("DB error checking contract type:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if errs.write(w) {
+			return
+		}
+
+		err := scanCargo(database.Pool().QueryRow(ctx, `
+			INSERT INTO cargos (cargo_id, nome, cbo, schedule_start, schedule_end, default_contract_type)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+cargoColumns,
+			uuid.NewString(), c.Nome, c.CBO, c.ScheduleStart, c.ScheduleEnd, c.Contract,
+		), &c)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error creating cargo:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, c)
+	}
+}
+
+// PATCH /api/cargos/{id}
+//
+// Renomear o cargo atualiza também o nome gravado nos funcionários. O
+// contrato padrão só vale para quem receber o cargo daqui em diante.
+func UpdateCargo(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req cargoRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var c models.Cargo
+		err := scanCargo(database.Pool().QueryRow(ctx, `
+			SELECT `+cargoColumns+` FROM cargos WHERE cargo_id = $1
+		`, r.PathValue("id")), &c)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "cargo not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching cargo:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		cols, errs := req.validate(&c, false)
+		if req.Contract.Set && c.Contract != nil && len(errs) == 0 {
+			if err := checkContractType(ctx, database, &errs, *c.Contract); err != nil {
+				log.Println("DB error checking contract type:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if errs.write(w) {
+			return
+		}
+		if len(cols) == 0 {
+			http.Error(w, "no valid fields to update", http.StatusBadRequest)
+			return
+		}
+
+		fields := []string{}
+		values := []any{}
+		for _, col := range cols {
+			values = append(values, col.Value)
+			fields = append(fields, fmt.Sprintf("%s = $%d", col.Name, len(values)))
+		}
+		values = append(values, c.Cargo_ID)
+
+		tx, err := database.Pool().Begin(ctx)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		err = scanCargo(tx.QueryRow(ctx, fmt.Sprintf(`
+			UPDATE cargos SET %s, updated_at = now()
+			WHERE cargo_id = $%d
+			RETURNING `+cargoColumns,
+			strings.Join(fields, ", "), len(values)), values...), &c)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error updating cargo:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if req.Name.Set {
+			_, err = tx.Exec(ctx, `UPDATE users SET cargo = $2 WHERE cargo_id = $1`, c.Cargo_ID, c.Nome)
+			if err != nil {
+				log.Println("DB error renaming cargo on users:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c)
+	}
+}
+
+// DELETE /api/cargos/{id}
+//
+// Só apaga cargo sem funcionários (nem na lixeira).
+func DeleteCargo(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id := r.PathValue("id")
+
+		var inUse bool
+		err := database.Pool().QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM users WHERE cargo_id = $1)
+		`, id).Scan(&inUse)
+		if err != nil {
+			log.Println("DB error checking cargo:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if inUse {
+			http.Error(w, "cargo is in use", http.StatusConflict)
+			return
+		}
+
+		cmd, err := database.Pool().Exec(ctx, `DELETE FROM cargos WHERE cargo_id = $1`, id)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error deleting cargo:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if cmd.RowsAffected() == 0 {
+			http.Error(w, "cargo not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// CargoMigrationGroup é um conjunto de grafias do mesmo cargo em texto livre
+// e o cargo do catálogo que passa a valer para elas.
+type CargoMigrationGroup struct {
+	Key      string   `json:"key"`
+	Variants []string `json:"variants"`
+	Users    int      `json:"users"`
+	CargoID  *string  `json:"cargo_id"` // nil no dry_run quando o cargo ainda seria criado
+	Cargo    string   `json:"cargo"`
+	Action   string   `json:"action"` // mapped, matched ou created
+}
+
+type CargoMigrationReport struct {
+	DryRun       bool                  `json:"dry_run"`
+	Groups       []CargoMigrationGroup `json:"groups"`
+	Created      int                   `json:"created"`
+	UsersUpdated int                   `json:"users_updated"`
+}
+
+// POST /api/cargos/migrate?dry_run=true
+//
+// Converte o cargo em texto livre dos funcionários sem cargo_id. As grafias
+// são agrupadas por cargoKey; cada grupo vai para o cargo indicado em
+// mappings (chave = qualquer grafia), para o cargo do catálogo de mesmo nome
+// ou para um cargo novo com a grafia mais usada. O histórico de vínculos
+// recebe o mesmo cargo_id.
+//
+//	{"mappings": {"Aux. administrativo": "<cargo_id>"}}
+func MigrateCargos(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Mappings map[string]string `json:"mappings"`
+		}
+		if r.ContentLength != 0 && !decodeBody(w, r, &body) {
+			return
+		}
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+		defer cancel()
+
+		cat, err := loadCargos(ctx, database)
+		if err != nil {
+			log.Println("DB error loading cargos:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		var errs fieldErrors
+		mapped := map[string]models.Cargo{}
+		for variant, id := range body.Mappings {
+			c, ok := cat.byID[id]
+			if !ok {
+				errs.add("mappings."+variant, "cargo not found")
+				continue
+			}
+			mapped[cargoKey(variant)] = c
+		}
+		if errs.write(w) {
+			return
+		}
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT cargo, COUNT(*)
+			FROM users
+			WHERE cargo_id IS NULL AND btrim(COALESCE(cargo, '')) <> ''
+			GROUP BY cargo
+			ORDER BY COUNT(*) DESC, cargo
+		`)
+		if err != nil {
+			log.Println("DB error listing free-text cargos:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		groups := []*CargoMigrationGroup{}
+		byKey := map[string]*CargoMigrationGroup{}
+		for rows.Next() {
+			var variant string
+			var n int
+			if err := rows.Scan(&variant, &n); err != nil {
+				rows.Close()
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			key := cargoKey(variant)
+			g, ok := byKey[key]
+			if !ok {
+				// a grafia mais usada vem primeiro e dá nome ao cargo novo
+				g = &CargoMigrationGroup{Key: key, Cargo: strings.Join(strings.Fields(variant), " ")}
+				byKey[key] = g
+				groups = append(groups, g)
+			}
+			g.Variants = append(g.Variants, variant)
+			g.Users += n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		rep := CargoMigrationReport{DryRun: dryRun, Groups: []CargoMigrationGroup{}}
+		for _, g := range groups {
+			c, ok := mapped[g.Key]
+			g.Action = "mapped"
+			if !ok {
+				c, ok = cat.byKey[g.Key]
+				g.Action = "matched"
+			}
+			if ok {
+				g.CargoID, g.Cargo = &c.Cargo_ID, c.Nome
+			} else {
+				g.Action = "created"
+				rep.Created++
+			}
+			rep.UsersUpdated += g.Users
+		}
+		sort.SliceStable(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+
+		if !dryRun && len(groups) > 0 {
+			if err := applyCargoMigration(ctx, database, groups); err != nil {
+				if writeDBError(w, err) {
+					return
+				}
+				log.Println("DB error migrating cargos:", err)
+				http.Error(w, "could not migrate cargos", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		for _, g := range groups {
+			rep.Groups = append(rep.Groups, *g)
+		}
+		writeJSON(w, http.StatusOK, rep)
+	}
+}
+
+// applyCargoMigration cria os cargos que faltam e liga funcionários e
+// histórico a eles, tudo numa transação.
+func applyCargoMigration(ctx context.Context, database *db.Database, groups []*CargoMigrationGroup) error {
+	tx, err := database.Pool().Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, g := range groups {
+		if g.CargoID == nil {
+			id := uuid.NewString()
+			_, err := tx.Exec(ctx, `INSERT INTO cargos (cargo_id, nome) VALUES ($1, $2)`, id, g.Cargo)
+			if err != nil {
+				return err
+			}
+			g.CargoID = &id
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE users SET cargo_id = $1, cargo = $2
+			WHERE cargo_id IS NULL AND cargo = ANY($3)
+		`, *g.CargoID, g.Cargo, g.Variants)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE user_assignments SET cargo_id = $1
+			WHERE cargo_id IS NULL AND cargo = ANY($2)
+		`, *g.CargoID, g.Variants)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,350 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+var contractCodePattern = regexp.MustCompile(`^[a-z0-9_]{2,30}$`)
+
+// jornada máxima aceita no cadastro de um contrato
+const maxContractMinutes = 24 * 60
+
+// contractRequest é o corpo do POST e do PATCH de tipo de contrato.
+type contractRequest struct {
+	Code            optional[string] `json:"code"`
+	Name            optional[string] `json:"name"`
+	DailyMinutes    optional[int]    `json:"daily_minutes"`
+	MaxDailyMinutes optional[int]    `json:"max_daily_minutes"`
+	OvertimeAllowed optional[bool]   `json:"overtime_allowed"`
+	HourBank        optional[bool]   `json:"hour_bank"`
+}
+
+// validate aplica o corpo sobre c (o contrato atual no PATCH, zerado no POST)
+// e confere as regras resultantes.
+func (req contractRequest) validate(c *models.ContractType, create bool) (cols []column, errs fieldErrors) {
+	set := func(name string, value any) { cols = append(cols, column{name, value}) }
+
+	if create {
+		if !req.Code.Set {
+			errs.add("code", "is required")
+		} else if req.Code.ok(&errs, "code", false) {
+			c.Code = strings.ToLower(strings.TrimSpace(req.Code.Value))
+			if !contractCodePattern.MatchString(c.Code) {
+				errs.add("code", "must have 2 to 30 lowercase letters, digits or _")
+			}
+		}
+	}
+
+	if req.Name.ok(&errs, "name", false) {
+		c.Name = strings.TrimSpace(req.Name.Value)
+		switch {
+		case c.Name == "":
+			errs.add("name", "is required")
+		case len(c.Name) > 100:
+			errs.add("name", "is too long")
+		}
+		set("name", c.Name)
+	} else if create && !req.Name.Set {
+		errs.add("name", "is required")
+	}
+
+	minutes := func(field string, o optional[int], target *int) {
+		if !o.ok(&errs, field, false) {
+			return
+		}
+		if o.Value <= 0 || o.Value > maxContractMinutes {
+			errs.add(field, fmt.Sprintf("must be between 1 and %d", maxContractMinutes))
+			return
+		}
+		*target = o.Value
+		set(field, o.Value)
+	}
+	minutes("daily_minutes", req.DailyMinutes, &c.DailyMinutes)
+	minutes("max_daily_minutes", req.MaxDailyMinutes, &c.MaxDailyMinutes)
+
+	if req.OvertimeAllowed.ok(&errs, "overtime_allowed", false) {
+		c.OvertimeAllowed = req.OvertimeAllowed.Value
+		set("overtime_allowed", c.OvertimeAllowed)
+	}
+	if req.HourBank.ok(&errs, "hour_bank", false) {
+		c.HourBank = req.HourBank.Value
+		set("hour_bank", c.HourBank)
+	}
+
+	if len(errs) > 0 {
+		return cols, errs
+	}
+
+	switch {
+	case c.DailyMinutes == 0:
+		errs.add("daily_minutes", "is required")
+	case !c.OvertimeAllowed && !req.MaxDailyMinutes.Set && c.MaxDailyMinutes != c.DailyMinutes:
+		// sem extra o teto é a própria jornada
+		c.MaxDailyMinutes = c.DailyMinutes
+		set("max_daily_minutes", c.MaxDailyMinutes)
+	case c.MaxDailyMinutes == 0:
+		errs.add("max_daily_minutes", "is required when overtime is allowed")
+	case c.MaxDailyMinutes < c.DailyMinutes:
+		errs.add("max_daily_minutes", "cannot be less than daily_minutes")
+	case !c.OvertimeAllowed && c.MaxDailyMinutes != c.DailyMinutes:
+		errs.add("max_daily_minutes", "must equal daily_minutes when overtime is not allowed")
+	}
+	if c.HourBank && !c.OvertimeAllowed {
+		errs.add("hour_bank", "requires overtime_allowed")
+	}
+	return cols, errs
+}
+
+const contractColumns = `code, name, daily_minutes, max_daily_minutes, overtime_allowed, hour_bank, created_at, updated_at`
+
+func scanContract(row pgx.Row, c *models.ContractType, extra ...any) error {
+	return row.Scan(append([]any{&c.Code, &c.Name, &c.DailyMinutes, &c.MaxDailyMinutes,
+		&c.OvertimeAllowed, &c.HourBank, &c.CreatedAt, &c.UpdatedAt}, extra...)...)
+}
+
+// checkContractType acrescenta o erro de campo se o contrato não existe.
+func checkContractType(ctx context.Context, database *db.Database, errs *fieldErrors, code string) error {
+	var exists bool
+	err := database.Pool().QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM contract_types WHERE code = $1)
+	`, code).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		errs.add("contract_type", "contract type not found")
+	}
+	return nil
+}
+
+// GET /api/contract-types
+func ListContractTypes(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+contractColumns+`,
+				(SELECT COUNT(*) FROM users u WHERE u.contract_type = c.code AND u.deleted_at IS NULL)
+			FROM contract_types c
+			ORDER BY name
+		`)
+		if err != nil {
+			log.Println("DB error listing contract types:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		out := []models.ContractType{}
+		for rows.Next() {
+			var c models.ContractType
+			var users int64
+			if err := scanContract(rows, &c, &users); err != nil {
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			c.Users = &users
+			out = append(out, c)
+		}
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// POST /api/contract-types
+func CreateContractType(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req contractRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		var c models.ContractType
+		if _, errs := req.validate(&c, true); errs.write(w) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		err := scanContract(database.Pool().QueryRow(ctx, `
+			INSERT INTO contract_types (code, name, daily_minutes, max_daily_minutes, overtime_allowed, hour_bank)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+contractColumns,
+			c.Code, c.Name, c.DailyMinutes, c.MaxDailyMinutes, c.OvertimeAllowed, c.HourBank,
+		), &c)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error creating contract type:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, c)
+	}
+}
+
+// PATCH /api/contract-types/{code}
+//
+// As regras novas valem para as batidas e relatórios daqui em diante,
+// inclusive de dias passados (o contrato guarda só a regra atual).
+func UpdateContractType(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req contractRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var c models.ContractType
+		err := scanContract(database.Pool().QueryRow(ctx, `
+			SELECT `+contractColumns+` FROM contract_types WHERE code = $1
+		`, r.PathValue("code")), &c)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "contract type not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching contract type:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		cols, errs := req.validate(&c, false)
+		if errs.write(w) {
+			return
+		}
+		if len(cols) == 0 {
+			http.Error(w, "no valid fields to update", http.StatusBadRequest)
+			return
+		}
+
+		fields := []string{}
+		values := []any{}
+		for _, col := range cols {
+			values = append(values, col.Value)
+			fields = append(fields, fmt.Sprintf("%s = $%d", col.Name, len(values)))
+		}
+		values = append(values, c.Code)
+
+		err = scanContract(database.Pool().QueryRow(ctx, fmt.Sprintf(`
+			UPDATE contract_types SET %s, updated_at = now()
+			WHERE code = $%d
+			RETURNING `+contractColumns,
+			strings.Join(fields, ", "), len(values)), values...), &c)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error updating contract type:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, c)
+	}
+}
+
+// DELETE /api/contract-types/{code}
+//
+// Só apaga contrato sem funcionários (nem na lixeira, nem no histórico) em
+// nenhuma empresa: o catálogo é da plataforma.
+func DeleteContractType(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(db.AllTenants(r.Context()), 5*time.Second)
+		defer cancel()
+
+		code := r.PathValue("code")
+
+		var inUse bool
+		err := database.Pool().QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM users WHERE contract_type = $1)
+				OR EXISTS (SELECT 1 FROM user_assignments WHERE contract_type = $1)
+		`, code).Scan(&inUse)
+		if err != nil {
+			log.Println("DB error checking contract type:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if inUse {
+			http.Error(w, "contract type is in use", http.StatusConflict)
+			return
+		}
+
+		cmd, err := database.Pool().Exec(ctx, `DELETE FROM contract_types WHERE code = $1`, code)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error deleting contract type:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if cmd.RowsAffected() == 0 {
+			http.Error(w, "contract type not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// contractDay é o contrato do funcionário e quanto ele já trabalhou no dia
+// local de at (batida aberta conta até at).
+type contractDay struct {
+	Contract      models.ContractType
+	WorkedMinutes int
+}
+
+func loadContractDay(ctx context.Context, database *db.Database, userID string, at time.Time) (contractDay, error) {
+	var d contractDay
+	var worked float64
+	err := database.Pool().QueryRow(ctx, `
+		SELECT c.code, c.name, c.daily_minutes, c.max_daily_minutes, c.overtime_allowed, c.hour_bank,
+			COALESCE((
+				SELECT SUM(EXTRACT(EPOCH FROM (COALESCE(p.clock_out, $2) - p.clock_in)))
+				FROM points p
+				WHERE p.user_id = u.user_id AND p.deleted_at IS NULL
+				  AND (p.clock_in AT TIME ZONE $3) >= ($2 AT TIME ZONE $3)::date
+			), 0)
+		FROM users u
+		JOIN contract_types c ON c.code = u.contract_type
+		WHERE u.user_id = $1
+	`, userID, at, at.Location().String()).Scan(&d.Contract.Code, &d.Contract.Name, &d.Contract.DailyMinutes,
+		&d.Contract.MaxDailyMinutes, &d.Contract.OvertimeAllowed, &d.Contract.HourBank, &worked)
+	d.WorkedMinutes = int(worked / 60)
+	return d, err
+}
+
+// checkContractLimit recusa a entrada de quem já cumpriu o máximo diário do
+// contrato (a jornada, se ele não admite extra). A saída nunca é recusada; o
+// excesso aparece na resposta e nos relatórios.
+func checkContractLimit(w http.ResponseWriter, d contractDay) bool {
+	if d.WorkedMinutes < d.Contract.DailyLimit() {
+		return true
+	}
+	http.Error(w, fmt.Sprintf("daily limit of %d minutes reached for contract %s", d.Contract.DailyLimit(), d.Contract.Code), http.StatusForbidden)
+	return false
+}
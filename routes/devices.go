@@ -0,0 +1,441 @@
+package routes
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// Políticas de aparelho do setor (mesmos valores da política de rede)
+const (
+	DevicePolicyOff    = "off"
+	DevicePolicyFlag   = "flag"
+	DevicePolicyReject = "reject"
+)
+
+func validDevicePolicy(p string) bool {
+	return p == DevicePolicyOff || p == DevicePolicyFlag || p == DevicePolicyReject
+}
+
+// diferença máxima entre o relógio do aparelho e o do servidor
+const deviceClockSkew = 5 * time.Minute
+
+func parseDevicePublicKey(alg, b64 string) (any, error) {
+	raw, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, errors.New("public_key must be base64")
+	}
+
+	switch alg {
+	case models.DeviceKeyEd25519:
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return ed25519.PublicKey(raw), nil
+
+	case models.DeviceKeyES256:
+		pub, err := x509.ParsePKIXPublicKey(raw)
+		if err != nil {
+			return nil, errors.New("invalid es256 public key (use PKIX DER)")
+		}
+		ec, ok := pub.(*ecdsa.PublicKey)
+		if !ok || ec.Curve.Params().Name != "P-256" {
+			return nil, errors.New("es256 public key must be P-256")
+		}
+		return ec, nil
+	}
+
+	return nil, errors.New("invalid key_alg (ed25519|es256)")
+}
+
+// devicePunchMessage é o que o app assina em cada batida:
+//
+//	punch-v1\n<user_id>\n<device_id>\n<timestamp_ms>\n<sha256 da foto em hex>
+//
+// A foto entra no hash para a assinatura não servir para outra batida.
+func devicePunchMessage(userID, deviceID string, timestampMS int64, photoSHA256 string) []byte {
+	return []byte(strings.Join([]string{
+		"punch-v1", userID, deviceID, strconv.FormatInt(timestampMS, 10), photoSHA256,
+	}, "\n"))
+}
+
+func verifyDeviceSignature(alg, publicKey string, msg []byte, sigB64 string) bool {
+	pub, err := parseDevicePublicKey(alg, publicKey)
+	if err != nil {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return false
+	}
+
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, msg, sig)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	}
+	return false
+}
+
+// deviceProof vem no JSON da batida pelo app.
+type deviceProof struct {
+	DeviceID  string `json:"device_id"`
+	Timestamp int64  `json:"device_ts"` // unix em milissegundos
+	Signature string `json:"device_signature"`
+}
+
+type deviceCheck struct {
+	DeviceID *string // aparelho do funcionário, quando reconhecido
+	Reason   string  // vazio = aparelho aprovado e assinatura válida
+}
+
+// checkDevice confere se a batida veio de um aparelho aprovado do
+// funcionário. Conforme a política do setor a falha só é registrada (flag)
+// ou a batida é recusada (reject, escreve o 403 e devolve false).
+func checkDevice(w http.ResponseWriter, ctx context.Context, database *db.Database, userID string, proof deviceProof, photoSHA256 string) (deviceCheck, bool) {
+	var policy string
+	err := database.Pool().QueryRow(ctx, `
+		SELECT COALESCE(s.device_policy, 'off')
+		FROM users u
+		LEFT JOIN setores s ON s.setor_id = u.setor_id
+		WHERE u.user_id = $1 AND u.deleted_at IS NULL
+	`, userID).Scan(&policy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return deviceCheck{}, false
+	} else if err != nil {
+		log.Println("DB error loading device policy:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return deviceCheck{}, false
+	}
+
+	check, err := verifyDevice(ctx, database, userID, proof, photoSHA256)
+	if err != nil {
+		log.Println("DB error checking device:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return check, false
+	}
+
+	if check.Reason != "" && policy == DevicePolicyReject {
+		http.Error(w, "device not trusted: "+check.Reason, http.StatusForbidden)
+		return check, false
+	}
+	if policy == DevicePolicyOff {
+		check.Reason = ""
+	}
+	return check, true
+}
+
+func verifyDevice(ctx context.Context, database *db.Database, userID string, proof deviceProof, photoSHA256 string) (deviceCheck, error) {
+	check := deviceCheck{}
+
+	if proof.DeviceID == "" || proof.Signature == "" {
+		check.Reason = "missing"
+		return check, nil
+	}
+	if _, err := uuid.Parse(proof.DeviceID); err != nil {
+		check.Reason = "unknown"
+		return check, nil
+	}
+
+	var alg, publicKey string
+	var status models.DeviceStatus
+	err := database.Pool().QueryRow(ctx, `
+		SELECT key_alg, public_key, status FROM user_devices
+		WHERE id = $1 AND user_id = $2
+	`, proof.DeviceID, userID).Scan(&alg, &publicKey, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		check.Reason = "unknown"
+		return check, nil
+	} else if err != nil {
+		return check, err
+	}
+
+	deviceID := proof.DeviceID
+	check.DeviceID = &deviceID
+
+	if status != models.DeviceApproved {
+		check.Reason = string(status)
+		return check, nil
+	}
+
+	signedAt := time.UnixMilli(proof.Timestamp)
+	if d := time.Since(signedAt); d > deviceClockSkew || d < -deviceClockSkew {
+		check.Reason = "stale"
+		return check, nil
+	}
+
+	msg := devicePunchMessage(userID, proof.DeviceID, proof.Timestamp, photoSHA256)
+	if !verifyDeviceSignature(alg, publicKey, msg, proof.Signature) {
+		check.Reason = "bad_signature"
+		return check, nil
+	}
+
+	// o timestamp só anda para frente: a mesma assinatura não vale duas vezes
+	cmd, err := database.Pool().Exec(ctx, `
+		UPDATE user_devices SET last_signed_ms = $2, last_used_at = now()
+		WHERE id = $1 AND last_signed_ms < $2
+	`, proof.DeviceID, proof.Timestamp)
+	if err != nil {
+		return check, err
+	}
+	if cmd.RowsAffected() == 0 {
+		check.Reason = "replay"
+	}
+	return check, nil
+}
+
+func flagDevice(ctx context.Context, database *db.Database, pointID int, punch string, check deviceCheck) {
+	_, err := database.Pool().Exec(ctx, `
+		INSERT INTO point_device_flags (point_id, punch, device_id, reason)
+		VALUES ($1, $2, $3, $4)
+	`, pointID, punch, check.DeviceID, check.Reason)
+	if err != nil {
+		log.Println("DB error flagging device:", err)
+	}
+}
+
+// =====================
+// CADASTRO E APROVAÇÃO
+// =====================
+
+const deviceColumns = `
+	d.id, d.user_id, u.name, d.name, d.platform, d.key_alg, d.public_key, d.status,
+	d.last_used_at, d.registered_at, d.decided_by, d.decided_at, d.revoked_by, d.revoked_at
+`
+
+func scanDevice(row pgx.Row, d *models.Device) error {
+	return row.Scan(
+		&d.ID, &d.UserID, &d.UserName, &d.Name, &d.Platform, &d.KeyAlg, &d.PublicKey, &d.Status,
+		&d.LastUsedAt, &d.RegisteredAt, &d.DecidedBy, &d.DecidedAt, &d.RevokedBy, &d.RevokedAt,
+	)
+}
+
+// POST /api/devices — o funcionário registra o aparelho dele; fica pendente
+// até o líder ou o RH aprovar.
+func RegisterDevice(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Name      string  `json:"name"`
+			Platform  *string `json:"platform"`
+			KeyAlg    string  `json:"key_alg"`
+			PublicKey string  `json:"public_key"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		if input.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if _, err := parseDevicePublicKey(input.KeyAlg, input.PublicKey); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		callerID, _ := mid.UserIDFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		id := uuid.NewString()
+		_, err := database.Pool().Exec(ctx, `
+			INSERT INTO user_devices (id, user_id, name, platform, key_alg, public_key)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, id, callerID, input.Name, input.Platform, input.KeyAlg, input.PublicKey)
+		if isUniqueViolation(err) {
+			http.Error(w, "device already registered", http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Println("DB error registering device:", err)
+			http.Error(w, "could not register device", http.StatusInternalServerError)
+			return
+		}
+
+		var d models.Device
+		err = scanDevice(database.Pool().QueryRow(ctx, `
+			SELECT `+deviceColumns+`
+			FROM user_devices d
+			LEFT JOIN users u ON u.user_id = d.user_id
+			WHERE d.id = $1
+		`, id), &d)
+		if err != nil {
+			log.Println("DB error fetching device:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, d)
+	}
+}
+
+// GET /api/devices?user_id=&status=
+//
+// Admin/RH veem todos, líderes os do seu setor e cada um os próprios.
+func ListDevices(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		callerID, _ := mid.UserIDFromContext(r.Context())
+		roles, _ := mid.RoleFromContext(r.Context())
+
+		userID := r.URL.Query().Get("user_id")
+		status := r.URL.Query().Get("status")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+deviceColumns+`
+			FROM user_devices d
+			LEFT JOIN users u ON u.user_id = d.user_id
+			WHERE ($1 OR d.user_id = $2 OR u.setor_id IN (`+ledSetoresSQL(2)+`))
+			  AND ($3 = '' OR d.user_id = $3)
+			  AND ($4 = '' OR d.status = $4)
+			ORDER BY d.registered_at DESC
+		`, isPrivileged(roles), callerID, userID, status)
+		if err != nil {
+			log.Println("DB error fetching devices:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		devices := []models.Device{}
+		for rows.Next() {
+			var d models.Device
+			if err := scanDevice(rows, &d); err != nil {
+				log.Println("DB error scanning device:", err)
+				http.Error(w, "scan error", http.StatusInternalServerError)
+				return
+			}
+			devices = append(devices, d)
+		}
+
+		writeJSON(w, http.StatusOK, devices)
+	}
+}
+
+// POST /api/devices/{id}/approve | reject | revoke
+//
+// Aprovar e recusar é do líder do setor ou de admin/RH. Revogar também pode
+// ser feito pelo próprio funcionário (aparelho perdido).
+func DeviceAction(database *db.Database, action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		callerID, _ := mid.UserIDFromContext(r.Context())
+		roles, _ := mid.RoleFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var ownerID string
+		var status models.DeviceStatus
+		err = database.Pool().QueryRow(ctx, `
+			SELECT user_id, status FROM user_devices WHERE id = $1
+		`, id).Scan(&ownerID, &status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "device not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching device:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		allowed := action == "revoke" && callerID == ownerID
+		if !allowed {
+			allowed, err = canManageUser(ctx, database, callerID, roles, ownerID)
+			if err != nil {
+				log.Println("DB error checking permission:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if !allowed {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		// o WHERE repete a transição para não atropelar outra decisão simultânea
+		switch action {
+		case "approve", "reject":
+			next := models.DeviceApproved
+			if action == "reject" {
+				next = models.DeviceRejected
+			}
+			cmd, err := database.Pool().Exec(ctx, `
+				UPDATE user_devices SET status = $3, decided_by = $2, decided_at = now()
+				WHERE id = $1 AND status = 'pending'
+			`, id, callerID, next)
+			if err != nil {
+				log.Println("DB error updating device:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if cmd.RowsAffected() == 0 {
+				http.Error(w, "device is not pending", http.StatusConflict)
+				return
+			}
+
+		case "revoke":
+			cmd, err := database.Pool().Exec(ctx, `
+				UPDATE user_devices SET status = 'revoked', revoked_by = $2, revoked_at = now()
+				WHERE id = $1 AND status IN ('pending', 'approved')
+			`, id, callerID)
+			if err != nil {
+				log.Println("DB error updating device:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if cmd.RowsAffected() == 0 {
+				http.Error(w, "device already "+string(status), http.StatusConflict)
+				return
+			}
+
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+
+		var d models.Device
+		err = scanDevice(database.Pool().QueryRow(ctx, `
+			SELECT `+deviceColumns+`
+			FROM user_devices d
+			LEFT JOIN users u ON u.user_id = d.user_id
+			WHERE d.id = $1
+		`, id), &d)
+		if err != nil {
+			log.Println("DB error fetching device:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, d)
+	}
+}
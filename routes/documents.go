@@ -0,0 +1,559 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/imaging"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+	"github.com/Rafhael-Viana/m/storage"
+	"github.com/Rafhael-Viana/m/webhooks"
+)
+
+const maxDocumentUpload = 10 << 20 // 10MB
+
+// tipos aceitos no upload e a pasta de cada um: {id}/{images|audios|docs}
+var documentTypes = map[string]string{
+	"image/png":       "images",
+	"image/jpeg":      "images",
+	"audio/mpeg":      "audios",
+	"application/pdf": "docs",
+}
+
+// documentExpiryDays é a antecedência do aviso de vencimento (padrão 30 dias).
+func documentExpiryDays() int {
+	if n, err := strconv.Atoi(os.Getenv("DOCUMENT_EXPIRY_DAYS")); err == nil && n > 0 {
+		return n
+	}
+	return 30
+}
+
+// documentUpload é o multipart recebido no upload e na troca do arquivo.
+type documentUpload struct {
+	data        []byte
+	filename    string
+	contentType string
+	subDir      string
+	category    optional[string]
+	expiresOn   optional[string]
+}
+
+// formField diferencia campo ausente de campo vazio ("" limpa o valor).
+func formField(r *http.Request, name string) optional[string] {
+	v, ok := r.MultipartForm.Value[name]
+	if !ok || len(v) == 0 {
+		return optional[string]{}
+	}
+	return optional[string]{Set: true, Null: v[0] == "", Value: strings.TrimSpace(v[0])}
+}
+
+// readDocumentUpload lê o arquivo do campo "file" e os campos category e
+// expires_on. O tipo vem dos bytes; o Content-Type do cliente é ignorado.
+func readDocumentUpload(w http.ResponseWriter, r *http.Request) (*documentUpload, bool) {
+	if err := r.ParseMultipartForm(maxDocumentUpload); err != nil {
+		http.Error(w, "File too large", http.StatusBadRequest)
+		return nil, false
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Invalid file", http.StatusBadRequest)
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return nil, false
+	}
+
+	contentType := detectUploadType(data)
+	subDir, ok := documentTypes[contentType]
+	if !ok {
+		http.Error(w, "File type not allowed", http.StatusForbidden)
+		return nil, false
+	}
+
+	return &documentUpload{
+		data:        data,
+		filename:    header.Filename,
+		contentType: contentType,
+		subDir:      subDir,
+		category:    formField(r, "category"),
+		expiresOn:   formField(r, "expires_on"),
+	}, true
+}
+
+// validate confere category e expires_on; "" ou ausente vira null.
+func (u *documentUpload) validate() (*models.DocumentCategory, *time.Time, fieldErrors) {
+	var errs fieldErrors
+
+	var category *models.DocumentCategory
+	if u.category.Set && !u.category.Null {
+		c := models.DocumentCategory(strings.ToLower(u.category.Value))
+		if !c.Valid() {
+			errs.add("category", "must be contrato, atestado, aso or identidade")
+		}
+		category = &c
+	}
+
+	expires := checkDate(&errs, "expires_on", u.expiresOn, false)
+	return category, expires, errs
+}
+
+// save grava o arquivo em {id}/{subDir}/ (imagens passam pelo pipeline e
+// ganham miniatura) e devolve o documento com chave, tamanho e SHA-256 do que
+// foi gravado e, nas imagens, a data de captura do EXIF. Escreve o erro e
+// devolve false em caso de falha.
+func (u *documentUpload) save(w http.ResponseWriter, ctx context.Context, store storage.Storage, dir string) (models.Document, bool) {
+	doc := models.Document{ContentType: u.contentType, Filename: nullIfEmpty(u.filename)}
+	stored := u.data
+
+	if u.subDir == "images" {
+		processed, err := imaging.Process(u.data, imaging.DefaultOptions)
+		if err != nil {
+			imageError(w, err)
+			return doc, false
+		}
+
+		saved, err := saveImage(ctx, store, processed, dir, u.subDir)
+		if err != nil {
+			http.Error(w, "Failed to save file", http.StatusInternalServerError)
+			return doc, false
+		}
+
+		doc.ObjectKey, _ = storage.KeyFromURL(saved.URL)
+		if thumb, ok := storage.KeyFromURL(saved.ThumbURL); ok {
+			doc.ThumbKey = &thumb
+		}
+		doc.ContentType = processed.ContentType
+		stored = processed.Data
+		doc.TakenAt = processed.TakenAt
+	} else {
+		ext := ".pdf"
+		if u.contentType == "audio/mpeg" {
+			ext = ".mp3"
+		}
+
+		key, err := saveFile(ctx, store, u.data, ext, u.contentType, dir, u.subDir)
+		if err != nil {
+			http.Error(w, "Failed to save file", http.StatusInternalServerError)
+			return doc, false
+		}
+		doc.ObjectKey = key
+	}
+
+	sum := sha256.Sum256(stored)
+	doc.SHA256 = hex.EncodeToString(sum[:])
+	doc.Size = int64(len(stored))
+	return doc, true
+}
+
+// deleteDocumentObjects apaga o arquivo e a miniatura; falhas só são logadas
+// (o que sobrar no storage não aparece mais em lugar nenhum).
+func deleteDocumentObjects(ctx context.Context, store storage.Storage, key string, thumb *string) {
+	for _, k := range []string{key, emptyIfNull(thumb)} {
+		if k == "" {
+			continue
+		}
+		if err := store.Delete(ctx, k); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Println("Storage error deleting document:", err)
+		}
+	}
+}
+
+// withDocumentURLs preenche as URLs assinadas do arquivo e da miniatura.
+func withDocumentURLs(d *models.Document) {
+	ttl := fileURLTTL()
+	d.URL, _ = signFileURL(d.ObjectKey, ttl)
+	if d.ThumbKey != nil {
+		d.ThumbURL, _ = signFileURL(*d.ThumbKey, ttl)
+	}
+}
+
+const documentColumns = `d.id, d.user_id, d.category, d.filename, d.content_type, d.size_bytes, d.sha256,
+	d.expires_on, d.uploaded_by, d.uploaded_at, d.replaced_at, d.taken_at, d.object_key, d.thumb_key`
+
+func scanDocument(row pgx.Row, d *models.Document, extra ...any) error {
+	return row.Scan(append([]any{&d.ID, &d.UserID, &d.Category, &d.Filename, &d.ContentType, &d.Size, &d.SHA256,
+		&d.ExpiresOn, &d.UploadedBy, &d.UploadedAt, &d.ReplacedAt, &d.TakenAt, &d.ObjectKey, &d.ThumbKey}, extra...)...)
+}
+
+// findDocument carrega o documento {doc} do usuário; escreve 404/500.
+func findDocument(w http.ResponseWriter, r *http.Request, ctx context.Context, database *db.Database, userID string) (models.Document, bool) {
+	var d models.Document
+
+	docID, err := strconv.ParseInt(r.PathValue("doc"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid document id", http.StatusBadRequest)
+		return d, false
+	}
+
+	err = scanDocument(database.Pool().QueryRow(ctx, `
+		SELECT `+documentColumns+`
+		FROM user_documents d
+		WHERE d.id = $1 AND d.user_id = $2
+	`, docID, userID), &d)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "document not found", http.StatusNotFound)
+		return d, false
+	}
+	if err != nil {
+		log.Println("DB error fetching document:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return d, false
+	}
+	return d, true
+}
+
+// GET /api/users/{id}/documents?category=&expiring=true
+func ListUserDocuments(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		_, userID, ok := pathUser(w, r, ctx, database, false)
+		if !ok {
+			return
+		}
+
+		where := []string{"d.user_id = $1"}
+		args := []any{userID}
+
+		q := r.URL.Query()
+		if c := q.Get("category"); c != "" {
+			if !models.DocumentCategory(c).Valid() {
+				http.Error(w, "invalid category", http.StatusBadRequest)
+				return
+			}
+			args = append(args, c)
+			where = append(where, "d.category = $"+strconv.Itoa(len(args)))
+		}
+		if q.Get("expiring") == "true" {
+			args = append(args, documentExpiryDays())
+			where = append(where, "d.expires_on <= current_date + $"+strconv.Itoa(len(args))+"::int")
+		}
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+documentColumns+`
+			FROM user_documents d
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY d.uploaded_at DESC, d.id DESC
+		`, args...)
+		if err != nil {
+			log.Println("DB error listing documents:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		docs := []models.Document{}
+		for rows.Next() {
+			var d models.Document
+			if err := scanDocument(rows, &d); err != nil {
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			withDocumentURLs(&d)
+			docs = append(docs, d)
+		}
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, docs)
+	}
+}
+
+// GET /api/users/{id}/documents/{doc}/download
+func DownloadUserDocument(database *db.Database, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		_, userID, ok := pathUser(w, r, ctx, database, false)
+		if !ok {
+			return
+		}
+		d, ok := findDocument(w, r, ctx, database, userID)
+		if !ok {
+			return
+		}
+
+		rc, info, err := store.Get(r.Context(), d.ObjectKey)
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("Storage error:", err)
+			http.Error(w, "could not read file", http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+
+		filename := emptyIfNull(d.Filename)
+		if filename == "" {
+			filename = path.Base(d.ObjectKey)
+		}
+		filename = strings.NewReplacer(`"`, "", "\r", "", "\n", "").Replace(filename)
+
+		w.Header().Set("Content-Type", d.ContentType)
+		if info.Size > 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "private, no-store")
+
+		io.Copy(w, rc)
+	}
+}
+
+// PUT /api/users/{id}/documents/{doc} (multipart: file, category?, expires_on?)
+//
+// Troca o arquivo mantendo o registro; category e expires_on só mudam se
+// vierem no form. O arquivo antigo é apagado depois que o banco confirma.
+func ReplaceUserDocument(database *db.Database, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		id, userID, ok := pathUser(w, r, ctx, database, true)
+		if !ok {
+			return
+		}
+		old, ok := findDocument(w, r, ctx, database, userID)
+		if !ok {
+			return
+		}
+
+		upload, ok := readDocumentUpload(w, r)
+		if !ok {
+			return
+		}
+		category, expires, errs := upload.validate()
+		if errs.write(w) {
+			return
+		}
+
+		doc, ok := upload.save(w, ctx, store, strconv.Itoa(id))
+		if !ok {
+			return
+		}
+
+		uploadedBy, _ := mid.UserIDFromContext(r.Context())
+		newKey, newThumb := doc.ObjectKey, doc.ThumbKey
+
+		// aviso de vencimento volta a valer se a data mudar
+		err := scanDocument(database.Pool().QueryRow(ctx, `
+			UPDATE user_documents d
+			SET object_key = $2, thumb_key = $3, content_type = $4, size_bytes = $5, sha256 = $6,
+				filename = $7, uploaded_by = $8, replaced_at = now(), taken_at = $13,
+				category = CASE WHEN $9 THEN $10 ELSE category END,
+				expires_on = CASE WHEN $11 THEN $12::date ELSE expires_on END,
+				expiry_alerted_at = CASE WHEN $11 AND $12::date IS DISTINCT FROM expires_on THEN NULL ELSE expiry_alerted_at END
+			WHERE d.id = $1
+			RETURNING `+documentColumns,
+			old.ID, newKey, newThumb, doc.ContentType, doc.Size, doc.SHA256,
+			doc.Filename, nullIfEmpty(uploadedBy),
+			upload.category.Set, category, upload.expiresOn.Set, expires, doc.TakenAt,
+		), &doc)
+		if err != nil {
+			deleteDocumentObjects(ctx, store, newKey, newThumb)
+			if writeDBError(w, err) {
+				return
+			}
+			log.Println("DB error replacing document:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		deleteDocumentObjects(ctx, store, old.ObjectKey, old.ThumbKey)
+
+		withDocumentURLs(&doc)
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+// DELETE /api/users/{id}/documents/{doc}
+//
+// Apaga o registro e o arquivo de vez (documentos não passam pela lixeira).
+func DeleteUserDocument(database *db.Database, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		_, userID, ok := pathUser(w, r, ctx, database, true)
+		if !ok {
+			return
+		}
+		d, ok := findDocument(w, r, ctx, database, userID)
+		if !ok {
+			return
+		}
+
+		if _, err := database.Pool().Exec(ctx, `DELETE FROM user_documents WHERE id = $1`, d.ID); err != nil {
+			log.Println("DB error deleting document:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		deleteDocumentObjects(ctx, store, d.ObjectKey, d.ThumbKey)
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// GET /api/documents/expiring?days=30&category=
+//
+// Documentos vencidos ou que vencem nos próximos dias, de todos os
+// funcionários ativos (admin/RH).
+func ListExpiringDocuments(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		days := documentExpiryDays()
+		if v := q.Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 3650 {
+				http.Error(w, "days must be between 0 and 3650", http.StatusBadRequest)
+				return
+			}
+			days = n
+		}
+
+		where := []string{"d.expires_on <= current_date + $1::int", "u.deleted_at IS NULL"}
+		args := []any{days}
+		if c := q.Get("category"); c != "" {
+			if !models.DocumentCategory(c).Valid() {
+				http.Error(w, "invalid category", http.StatusBadRequest)
+				return
+			}
+			args = append(args, c)
+			where = append(where, "d.category = $2")
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+documentColumns+`, u.name
+			FROM user_documents d
+			JOIN users u ON u.user_id = d.user_id
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY d.expires_on, u.name
+		`, args...)
+		if err != nil {
+			log.Println("DB error listing expiring documents:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		docs := []models.Document{}
+		for rows.Next() {
+			var d models.Document
+			if err := scanDocument(rows, &d, &d.UserName); err != nil {
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			docs = append(docs, d)
+		}
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"days":      days,
+			"documents": docs,
+		})
+	}
+}
+
+// WatchDocumentExpiry emite document.expiring uma vez por documento quando
+// ele entra na janela de DOCUMENT_EXPIRY_DAYS. expiry_alerted_at marca o aviso
+// (o UPDATE ... RETURNING garante um só mesmo com várias instâncias) e é
+// zerado quando o arquivo é trocado com outra data. Roda por empresa.
+func WatchDocumentExpiry(ctx context.Context, database *db.Database, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := database.ForEachTenant(ctx, func(ctx context.Context, _ string) error {
+				return notifyExpiringDocuments(ctx, database)
+			})
+			if err != nil {
+				log.Println("Document expiry watcher error:", err)
+			}
+		}
+	}
+}
+
+func notifyExpiringDocuments(ctx context.Context, database *db.Database) error {
+	rows, err := database.Pool().Query(ctx, `
+		UPDATE user_documents d
+		SET expiry_alerted_at = now()
+		FROM users u
+		WHERE u.user_id = d.user_id
+		  AND u.deleted_at IS NULL
+		  AND u.status = 'active'
+		  AND d.expires_on <= current_date + $1::int
+		  AND d.expiry_alerted_at IS NULL
+		RETURNING d.id, d.user_id, u.name, d.category, d.filename, d.expires_on, d.expires_on < current_date
+	`, documentExpiryDays())
+	if err != nil {
+		return err
+	}
+
+	type expiring struct {
+		ID        int64
+		UserID    string
+		Name      string
+		Category  *string
+		Filename  *string
+		ExpiresOn time.Time
+		Expired   bool
+	}
+	docs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[expiring])
+	if err != nil {
+		return err
+	}
+
+	for _, d := range docs {
+		emitWebhook(ctx, database, webhooks.EventDocumentExpiring, map[string]any{
+			"document_id": d.ID,
+			"user_id":     d.UserID,
+			"user_name":   d.Name,
+			"category":    d.Category,
+			"filename":    d.Filename,
+			"expires_on":  d.ExpiresOn.Format("2006-01-02"),
+			"expired":     d.Expired,
+		})
+	}
+	return nil
+}
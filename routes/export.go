@@ -0,0 +1,278 @@
+package routes
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// a cada tantas linhas o CSV é enviado ao cliente
+const exportFlushEvery = 500
+
+// sheetWriter grava a planilha linha a linha, sem montar tudo em memória.
+type sheetWriter interface {
+	Write(row []any) error
+	Close() error
+}
+
+// exportFormat lê ?format=csv|xlsx (padrão csv).
+func exportFormat(r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	return format, format == "csv" || format == "xlsx"
+}
+
+// newSheetWriter cria o gravador do formato e já escreve os cabeçalhos HTTP
+// do download.
+func newSheetWriter(w http.ResponseWriter, format, name string) (sheetWriter, error) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		// BOM para o Excel reconhecer UTF-8
+		if _, err := w.Write([]byte("\xef\xbb\xbf")); err != nil {
+			return nil, err
+		}
+		return &csvSheet{w: w, cw: csv.NewWriter(w)}, nil
+	case "xlsx":
+		f := excelize.NewFile()
+		sw, err := f.NewStreamWriter("Sheet1")
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		return &xlsxSheet{w: w, f: f, sw: sw}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvSheet struct {
+	w    http.ResponseWriter
+	cw   *csv.Writer
+	rows int
+}
+
+func (s *csvSheet) Write(row []any) error {
+	rec := make([]string, len(row))
+	for i, v := range row {
+		rec[i] = exportCell(v)
+	}
+	if err := s.cw.Write(rec); err != nil {
+		return err
+	}
+	s.rows++
+	if s.rows%exportFlushEvery == 0 {
+		s.cw.Flush()
+		if f, ok := s.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	return s.cw.Error()
+}
+
+func (s *csvSheet) Close() error {
+	s.cw.Flush()
+	return s.cw.Error()
+}
+
+// O StreamWriter do excelize despeja as linhas em arquivo temporário quando
+// passam do buffer, então a memória fica limitada mesmo em bases grandes.
+type xlsxSheet struct {
+	w    http.ResponseWriter
+	f    *excelize.File
+	sw   *excelize.StreamWriter
+	rows int
+}
+
+func (s *xlsxSheet) Write(row []any) error {
+	s.rows++
+	cell, err := excelize.CoordinatesToCellName(1, s.rows)
+	if err != nil {
+		return err
+	}
+	values := make([]any, len(row))
+	for i, v := range row {
+		values[i] = exportCell(v)
+	}
+	return s.sw.SetRow(cell, values)
+}
+
+func (s *xlsxSheet) Close() error {
+	defer s.f.Close()
+	if err := s.sw.Flush(); err != nil {
+		return err
+	}
+	return s.f.Write(s.w)
+}
+
+// exportCell converte os valores do banco para texto de planilha.
+func exportCell(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case *string:
+		return emptyIfNull(x)
+	case string:
+		return x
+	case *time.Time:
+		if x == nil {
+			return ""
+		}
+		return x.Format("2006-01-02")
+	case time.Time:
+		return x.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// GET /api/users/export?format=csv|xlsx&setor_id=&include_subsetores=true&status=&role=&cargo=&cargo_id=&q=&sort=
+//
+// Aceita os mesmos filtros e ordenação da listagem. A senha nunca sai.
+func ExportUsers(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := exportFormat(r)
+		if !ok {
+			http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+			return
+		}
+
+		pg, err := parsePage(r, userPageSpec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		where, args, _ := userFilters(r.URL.Query())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT u.id, u.user_id, u.name, u.username, u.email,
+				COALESCE(s.nome, u.setor), COALESCE(c.nome, u.cargo), c.cbo, u.status, u.role, u.nascimento, u.timezone,
+				u.cpf, u.pis, u.matricula, u.admissao, u.contract_type
+			FROM users u
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
+			LEFT JOIN cargos c ON c.cargo_id = u.cargo_id
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY `+pg.OrderBy(),
+			args...)
+		if err != nil {
+			log.Println("DB error exporting users:", err)
+			http.Error(w, "error fetching users", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		sheet, err := newSheetWriter(w, format, "usuarios")
+		if err != nil {
+			log.Println("export error:", err)
+			http.Error(w, "could not create file", http.StatusInternalServerError)
+			return
+		}
+
+		roles, _ := mid.RoleFromContext(r.Context())
+
+		header := []any{"id", "user_id", "name", "username", "email", "setor", "cargo", "cbo", "status", "role", "birth", "timezone", "cpf", "pis", "matricula", "admission_date", "contract_type"}
+		writeExport(sheet, rows, header, func() ([]any, error) {
+			var u models.User
+			var setor, cbo, status, role *string
+			err := rows.Scan(&u.ID, &u.User_ID, &u.Name, &u.Username, &u.Email, &setor, &u.Cargo, &cbo, &status, &role, &u.Nascimento, &u.Timezone, &u.CPF, &u.PIS, &u.Matricula, &u.Admissao, &u.Contract)
+			maskUser(&u, roles)
+			return []any{u.ID, u.User_ID, u.Name, u.Username, u.Email, setor, u.Cargo, cbo, status, role, u.Nascimento, u.Timezone, u.CPF, u.PIS, u.Matricula, u.Admissao, u.Contract}, err
+		})
+	}
+}
+
+// GET /api/setor/export?format=csv|xlsx
+func ExportSetores(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := exportFormat(r)
+		if !ok {
+			http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		defer cancel()
+
+		// headcount conta só funcionários ativos e fora da lixeira
+		rows, err := database.Pool().Query(ctx, `
+			SELECT s.setor_id, s.nome, s.parent_id, COALESCE(l.name, s.lider), s.lider_id, s.timezone,
+				(SELECT COUNT(*) FROM users u
+				 WHERE u.setor_id = s.setor_id AND u.status = 'active' AND u.deleted_at IS NULL)
+			FROM setores s
+			LEFT JOIN users l ON l.user_id = s.lider_id
+			WHERE s.deleted_at IS NULL
+			ORDER BY s.nome
+		`)
+		if err != nil {
+			log.Println("DB error exporting setores:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		sheet, err := newSheetWriter(w, format, "setores")
+		if err != nil {
+			log.Println("export error:", err)
+			http.Error(w, "could not create file", http.StatusInternalServerError)
+			return
+		}
+
+		writeExport(sheet, rows, []any{"setor_id", "nome", "parent_id", "lider", "lider_id", "timezone", "headcount"}, func() ([]any, error) {
+			var (
+				setorID, nome            string
+				parentID, lider, liderID *string
+				timezone                 *string
+				headcount                int64
+			)
+			err := rows.Scan(&setorID, &nome, &parentID, &lider, &liderID, &timezone, &headcount)
+			return []any{setorID, nome, parentID, lider, liderID, timezone, headcount}, err
+		})
+	}
+}
+
+// writeExport escreve o cabeçalho e as linhas. Depois que o download começou
+// não dá mais para trocar o status HTTP, então erros no meio só são logados.
+func writeExport(sheet sheetWriter, rows pgx.Rows, header []any, scan func() ([]any, error)) {
+	if err := sheet.Write(header); err != nil {
+		log.Println("export error:", err)
+		return
+	}
+	for rows.Next() {
+		row, err := scan()
+		if err != nil {
+			log.Println("export scan error:", err)
+			return
+		}
+		if err := sheet.Write(row); err != nil {
+			log.Println("export error:", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("export rows error:", err)
+		return
+	}
+	if err := sheet.Close(); err != nil {
+		log.Println("export error:", err)
+	}
+}
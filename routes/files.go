@@ -0,0 +1,173 @@
+package routes
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/storage"
+)
+
+// Arquivos não são mais públicos: só saem por URL assinada e com validade
+// (GET /api/files/{key}?expires=&signature=), emitida por GET /api/files/sign
+// para quem pode ver os arquivos do dono.
+
+const (
+	filesURLPrefix    = "/api/files/"
+	defaultFileURLTTL = 15 * time.Minute
+	maxFileURLTTL     = 24 * time.Hour
+)
+
+func fileURLSecret() []byte {
+	if s := os.Getenv("FILE_URL_SECRET"); s != "" {
+		return []byte(s)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func fileURLTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("FILE_URL_TTL")); err == nil && d > 0 && d <= maxFileURLTTL {
+		return d
+	}
+	return defaultFileURLTTL
+}
+
+func fileSignature(key string, expires int64) string {
+	m := hmac.New(sha256.New, fileURLSecret())
+	m.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// signFileURL gera a URL temporária para a chave do storage.
+func signFileURL(key string, ttl time.Duration) (string, time.Time) {
+	exp := time.Now().Add(ttl).Truncate(time.Second)
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(exp.Unix(), 10))
+	q.Set("signature", fileSignature(key, exp.Unix()))
+	return filesURLPrefix + key + "?" + q.Encode(), exp
+}
+
+func validFileSignature(key, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(fileSignature(key, exp)))
+}
+
+// fileOwner descobre de qual usuário é o arquivo pela chave:
+// points/{user_id}/... (fotos de ponto) ou {id|user_id}/{images|audios|docs}/...
+func fileOwner(ctx context.Context, database *db.Database, key string) (string, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) < 2 {
+		return "", pgx.ErrNoRows
+	}
+
+	ref := parts[0]
+	if ref == "points" {
+		ref = parts[1]
+	}
+
+	var ownerID string
+	err := database.Pool().QueryRow(ctx, `
+		SELECT user_id FROM users WHERE user_id = $1 OR id::text = $1 LIMIT 1
+	`, ref).Scan(&ownerID)
+	return ownerID, err
+}
+
+// GET /api/files/sign?url=/uploads/points/...  (autenticado)
+func SignFileURL(database *db.Database, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw := strings.TrimSpace(r.URL.Query().Get("url"))
+		key, ok := storage.KeyFromURL(raw)
+		if !ok {
+			// aceita também a chave pura (points/...)
+			key = strings.TrimPrefix(raw, "/")
+		}
+		if key == "" {
+			http.Error(w, "url is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		ownerID, err := fileOwner(ctx, database, key)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error resolving file owner:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if !callerCanViewUser(w, r, ctx, database, ownerID) {
+			return
+		}
+
+		if _, err := store.Stat(ctx, key); errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("Storage error:", err)
+			http.Error(w, "could not read file", http.StatusInternalServerError)
+			return
+		}
+
+		signed, exp := signFileURL(key, fileURLTTL())
+		writeJSON(w, http.StatusOK, map[string]any{
+			"url":        signed,
+			"expires_at": exp,
+		})
+	}
+}
+
+// GET /api/files/{key...}?expires=&signature=
+func ServeSignedFile(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		q := r.URL.Query()
+
+		if !validFileSignature(key, q.Get("expires"), q.Get("signature")) {
+			http.Error(w, "invalid or expired link", http.StatusForbidden)
+			return
+		}
+
+		rc, info, err := store.Get(r.Context(), key)
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.Println("Storage error:", err)
+			http.Error(w, "could not read file", http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+
+		if info.ContentType != "" {
+			w.Header().Set("Content-Type", info.ContentType)
+		}
+		if info.Size > 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// o link expira; não deixa proxies compartilhados guardarem a resposta
+		w.Header().Set("Cache-Control", "private, max-age=300")
+
+		io.Copy(w, rc)
+	}
+}
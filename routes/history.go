@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// recordAssignment grava o cadastro atual (setor, cargo, status, contrato) como o
+// vínculo em vigor a partir de from (nil = hoje). Períodos que começavam a
+// partir dessa data são substituídos e o que estava valendo é encerrado nela,
+// então uma mudança retroativa reescreve o histórico dali em diante.
+func recordAssignment(ctx context.Context, tx pgx.Tx, userID string, from *time.Time, changedBy, reason *string) error {
+	var day time.Time
+	if err := tx.QueryRow(ctx, `SELECT COALESCE($1::date, current_date)`, from).Scan(&day); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `DELETE FROM user_assignments WHERE user_id = $1 AND valid_from >= $2`, userID, day)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_assignments SET valid_to = $2
+		WHERE user_id = $1 AND (valid_to IS NULL OR valid_to > $2)
+	`, userID, day)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_assignments (user_id, setor_id, cargo, cargo_id, status, contract_type, valid_from, changed_by, reason)
+		SELECT user_id, setor_id, cargo, cargo_id, COALESCE(status, 'active'), contract_type, $2, $3, $4
+		FROM users
+		WHERE user_id = $1
+	`, userID, day, changedBy, reason)
+	return err
+}
+
+// assignmentJoinSQL junta a cada batida p (com o usuário em u) o vínculo em
+// vigor no dia dayExpr, como ua. Sem histórico para o dia, assignedSetorSQL e
+// assignedCargoSQL caem no cadastro atual.
+func assignmentJoinSQL(dayExpr string) string {
+	return `LEFT JOIN LATERAL (
+		SELECT true AS found, a.setor_id, a.cargo, a.cargo_id, a.contract_type
+		FROM user_assignments a
+		WHERE a.user_id = p.user_id
+		  AND a.valid_from <= ` + dayExpr + `
+		  AND (a.valid_to IS NULL OR a.valid_to > ` + dayExpr + `)
+		ORDER BY a.valid_from DESC
+		LIMIT 1
+	) ua ON true`
+}
+
+const (
+	assignedSetorSQL   = `CASE WHEN ua.found THEN ua.setor_id ELSE u.setor_id END`
+	assignedCargoSQL   = `CASE WHEN ua.found THEN ua.cargo ELSE u.cargo END`
+	assignedCargoIDSQL = `CASE WHEN ua.found THEN ua.cargo_id ELSE u.cargo_id END`
+	// períodos gravados antes dos contratos não têm contract_type
+	assignedContractSQL = `COALESCE(ua.contract_type, u.contract_type)`
+)
+
+// GET /api/users/{id}/history
+//
+// Períodos de setor, cargo e status do funcionário, do mais recente ao mais
+// antigo.
+func ListUserHistory(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		_, userID, ok := pathUser(w, r, ctx, database, false)
+		if !ok {
+			return
+		}
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT a.id, a.user_id, a.setor_id, s.nome, a.cargo, a.cargo_id, a.status, a.contract_type,
+				a.valid_from, a.valid_to, a.changed_by, a.reason, a.created_at
+			FROM user_assignments a
+			LEFT JOIN setores s ON s.setor_id = a.setor_id
+			WHERE a.user_id = $1
+			ORDER BY a.valid_from DESC
+		`, userID)
+		if err != nil {
+			log.Println("DB error listing user history:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		out := []models.Assignment{}
+		for rows.Next() {
+			var a models.Assignment
+			if err := rows.Scan(&a.ID, &a.UserID, &a.SetorID, &a.SetorNome, &a.Cargo, &a.CargoID, &a.Status, &a.Contract,
+				&a.ValidFrom, &a.ValidTo, &a.ChangedBy, &a.Reason, &a.CreatedAt); err != nil {
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			out = append(out, a)
+		}
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// changedBy é quem está autenticado na requisição (nil se a rota não exige login).
+func changedBy(r *http.Request) *string {
+	id, _ := mid.UserIDFromContext(r.Context())
+	return nullIfEmpty(id)
+}
@@ -0,0 +1,182 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// normalizeCPF aceita o CPF com ou sem máscara e devolve só os dígitos,
+// conferindo os dois dígitos verificadores.
+func normalizeCPF(s string) (string, bool) {
+	d := onlyDigits(s)
+	if len(d) != 11 || strings.Count(d, d[:1]) == 11 {
+		return "", false
+	}
+	for _, n := range []int{9, 10} {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(d[i]-'0') * (n + 1 - i)
+		}
+		dv := sum * 10 % 11 % 10
+		if dv != int(d[n]-'0') {
+			return "", false
+		}
+	}
+	return d, true
+}
+
+// normalizePIS faz o mesmo para PIS/PASEP/NIS (um dígito verificador).
+func normalizePIS(s string) (string, bool) {
+	d := onlyDigits(s)
+	if len(d) != 11 || strings.Count(d, d[:1]) == 11 {
+		return "", false
+	}
+	weights := []int{3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(d[i]-'0') * w
+	}
+	dv := 11 - sum%11
+	if dv >= 10 {
+		dv = 0
+	}
+	if dv != int(d[10]-'0') {
+		return "", false
+	}
+	return d, true
+}
+
+// normalizeCNPJ faz o mesmo para o CNPJ da empresa (dois dígitos
+// verificadores).
+func normalizeCNPJ(s string) (string, bool) {
+	d := onlyDigits(s)
+	if len(d) != 14 || strings.Count(d, d[:1]) == 14 {
+		return "", false
+	}
+	weights := []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	for _, n := range []int{12, 13} {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(d[i]-'0') * weights[i+13-n]
+		}
+		dv := 0
+		if sum%11 >= 2 {
+			dv = 11 - sum%11
+		}
+		if dv != int(d[n]-'0') {
+			return "", false
+		}
+	}
+	return d, true
+}
+
+// normalizeMatricula só apara espaços; o formato é livre, de cada empresa.
+func normalizeMatricula(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	return s, s != "" && len(s) <= 30
+}
+
+// maskCPF mostra só os dígitos do meio: ***.456.789-**
+func maskCPF(d string) string {
+	if len(d) != 11 {
+		return "***"
+	}
+	return "***." + d[3:6] + "." + d[6:9] + "-**"
+}
+
+// maskPIS mostra só o bloco do meio: ***.45678.**-*
+func maskPIS(d string) string {
+	if len(d) != 11 {
+		return "***"
+	}
+	return "***." + d[3:8] + ".**-*"
+}
+
+// maskUser esconde CPF e PIS de quem não é admin.
+func maskUser(u *models.User, roles []string) {
+	if hasRole(roles, models.RoleSuperAdmin, models.RoleAdmin) {
+		return
+	}
+	if u.CPF != nil {
+		m := maskCPF(*u.CPF)
+		u.CPF = &m
+	}
+	if u.PIS != nil {
+		m := maskPIS(*u.PIS)
+		u.PIS = &m
+	}
+}
+
+// identifierNormalizers valida cada identificador (criação, PATCH e importação).
+var identifierNormalizers = map[string]func(string) (string, bool){
+	"cpf":       normalizeCPF,
+	"pis":       normalizePIS,
+	"matricula": normalizeMatricula,
+}
+
+// GET /api/users/lookup?cpf=|pis=|matricula=
+//
+// Busca exata por um identificador trabalhista (só admin).
+func LookupUser(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var column, value string
+		for _, name := range []string{"cpf", "pis", "matricula"} {
+			if v := q.Get(name); v != "" {
+				d, ok := identifierNormalizers[name](v)
+				if !ok {
+					http.Error(w, "invalid "+name, http.StatusBadRequest)
+					return
+				}
+				column, value = name, d
+				break
+			}
+		}
+		if column == "" {
+			http.Error(w, "cpf, pis or matricula is required", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var u models.User
+		err := database.Pool().QueryRow(ctx, `
+			SELECT id, name, email, username, user_id, setor, cargo, nascimento, status, role, setor_id, timezone,
+				cpf, pis, matricula, admissao, contract_type, cargo_id
+			FROM users
+			WHERE `+column+` = $1 AND deleted_at IS NULL
+		`, value).Scan(&u.ID, &u.Name, &u.Email, &u.Username, &u.User_ID, &u.Setor, &u.Cargo, &u.Nascimento, &u.Status, &u.Role, &u.Setor_ID, &u.Timezone, &u.CPF, &u.PIS, &u.Matricula, &u.Admissao, &u.Contract, &u.CargoID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("DB error looking up user:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, u)
+	}
+}
@@ -0,0 +1,96 @@
+package routes
+
+import "testing"
+
+func TestNormalizeCPF(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"529.982.247-25", "52998224725", true},
+		{"52998224725", "52998224725", true},
+		{" 111.444.777-35 ", "11144477735", true},
+		{"529.982.247-24", "", false}, // segundo dígito errado
+		{"529.982.247-15", "", false}, // primeiro dígito errado
+		{"111.111.111-11", "", false}, // dígitos repetidos passam na conta
+		{"5299822472", "", false},
+		{"529982247250", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := normalizeCPF(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeCPF(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizePIS(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"170.33259.50-4", "17033259504", true},
+		{"17033259504", "17033259504", true},
+		{"120.54412.10-6", "12054412106", true},
+		{"400.00000.00-0", "40000000000", true}, // 11 - resto dá 10: dígito 0
+		{"170.33259.50-3", "", false},
+		{"000.00000.00-0", "", false},
+		{"1703325950", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := normalizePIS(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizePIS(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeCNPJ(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"11.222.333/0001-81", "11222333000181", true},
+		{"11222333000181", "11222333000181", true},
+		{"04.252.011/0001-10", "04252011000110", true},
+		{"11.222.333/0001-82", "", false},
+		{"11.222.333/0001-71", "", false},
+		{"11.111.111/1111-11", "", false},
+		{"1122233300018", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := normalizeCNPJ(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeCNPJ(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMaskIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		mask func(string) string
+		in   string
+		want string
+	}{
+		{"cpf", maskCPF, "52998224725", "***.982.247-**"},
+		{"cpf short", maskCPF, "123", "***"},
+		{"pis", maskPIS, "17033259504", "***.33259.**-*"},
+		{"pis short", maskPIS, "", "***"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.mask(tt.in); got != tt.want {
+			t.Errorf("%s: mask(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
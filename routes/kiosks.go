@@ -0,0 +1,658 @@
+package routes
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+	"github.com/Rafhael-Viana/m/storage"
+)
+
+const (
+	// header com o token do quiosque
+	kioskTokenHeader = "X-Kiosk-Token"
+
+	// prefixo do conteúdo do QR pessoal
+	kioskQRPrefix = "kq1:"
+
+	// identificações erradas seguidas antes de travar o quiosque
+	kioskMaxFailures = 5
+	kioskLockout     = 5 * time.Minute
+
+	pinDigits = 6
+)
+
+// kioskPepper é o segredo do HMAC dos PINs. Com HMAC (e não bcrypt) dá para
+// achar o funcionário pelo PIN com um índice único.
+func kioskPepper() []byte {
+	if s := os.Getenv("KIOSK_PIN_SECRET"); s != "" {
+		return []byte(s)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func hashPIN(pin string) string {
+	mac := hmac.New(sha256.New, kioskPepper())
+	mac.Write([]byte(pin))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+type kioskCtxKey struct{}
+
+// RequireKiosk autentica o quiosque pelo header X-Kiosk-Token. A empresa das
+// queries seguintes é a do quiosque.
+func RequireKiosk(database *db.Database) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(kioskTokenHeader)
+			if token == "" {
+				http.Error(w, "missing kiosk token", http.StatusUnauthorized)
+				return
+			}
+
+			// o token é único entre todas as empresas
+			ctx, cancel := context.WithTimeout(db.AllTenants(r.Context()), 5*time.Second)
+			defer cancel()
+
+			var k models.Kiosk
+			var tenantID string
+			err := database.Pool().QueryRow(ctx, `
+				UPDATE kiosks k SET last_seen_at = now()
+				FROM tenants t
+				WHERE k.token_hash = $1 AND k.active
+				  AND t.tenant_id = k.tenant_id AND t.status = 'active'
+				RETURNING k.id, k.name, k.setores, k.active, k.last_seen_at, k.created_by, k.created_at, k.updated_at, k.tenant_id
+			`, hashToken(token)).Scan(&k.ID, &k.Name, &k.Setores, &k.Active, &k.LastSeenAt, &k.CreatedBy, &k.CreatedAt, &k.UpdatedAt, &tenantID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "invalid kiosk token", http.StatusUnauthorized)
+				return
+			} else if err != nil {
+				log.Println("DB error authenticating kiosk:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+
+			ctx = db.WithTenant(r.Context(), tenantID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, kioskCtxKey{}, k)))
+		})
+	}
+}
+
+func kioskFromContext(ctx context.Context) (models.Kiosk, bool) {
+	k, ok := ctx.Value(kioskCtxKey{}).(models.Kiosk)
+	return k, ok
+}
+
+// =====================
+// ADMIN DOS QUIOSQUES
+// =====================
+
+const kioskColumns = `id, name, setores, active, last_seen_at, created_by, created_at, updated_at`
+
+func scanKiosk(row pgx.Row, k *models.Kiosk) error {
+	return row.Scan(&k.ID, &k.Name, &k.Setores, &k.Active, &k.LastSeenAt, &k.CreatedBy, &k.CreatedAt, &k.UpdatedAt)
+}
+
+// kioskRequest é o corpo do POST e do PATCH de quiosque.
+type kioskRequest struct {
+	Name        optional[string]   `json:"name"`
+	Setores     optional[[]string] `json:"setores"`
+	Active      optional[bool]     `json:"active"`
+	RotateToken optional[bool]     `json:"rotate_token"`
+}
+
+// validate aplica o corpo sobre k (zerado no POST). rotate_token não vira
+// coluna aqui: o token novo é gerado pelo handler.
+func (req kioskRequest) validate(k *models.Kiosk, create bool) (cols []column, errs fieldErrors) {
+	set := func(name string, value any) { cols = append(cols, column{name, value}) }
+
+	if req.Name.ok(&errs, "name", false) {
+		k.Name = strings.TrimSpace(req.Name.Value)
+		switch {
+		case k.Name == "":
+			errs.add("name", "is required")
+		case len(k.Name) > 100:
+			errs.add("name", "is too long")
+		}
+		set("name", k.Name)
+	} else if create && !req.Name.Set {
+		errs.add("name", "is required")
+	}
+
+	if req.Setores.ok(&errs, "setores", false) {
+		k.Setores = []string{}
+		for _, id := range req.Setores.Value {
+			if id = strings.TrimSpace(id); id != "" && !slices.Contains(k.Setores, id) {
+				k.Setores = append(k.Setores, id)
+			}
+		}
+		if len(k.Setores) == 0 {
+			errs.add("setores", "is required")
+		}
+		set("setores", k.Setores)
+	} else if create && !req.Setores.Set {
+		errs.add("setores", "is required")
+	}
+
+	if req.Active.ok(&errs, "active", false) {
+		k.Active = req.Active.Value
+		set("active", k.Active)
+	}
+	req.RotateToken.ok(&errs, "rotate_token", false)
+
+	return cols, errs
+}
+
+// checkSetoresExist registra em errs os setores que não existem (ou foram
+// excluídos).
+func checkSetoresExist(ctx context.Context, database *db.Database, errs *fieldErrors, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var missing []string
+	err := database.Pool().QueryRow(ctx, `
+		SELECT coalesce(array_agg(id ORDER BY id), '{}')
+		FROM unnest($1::text[]) AS id
+		WHERE NOT EXISTS (SELECT 1 FROM setores s WHERE s.setor_id = id AND s.deleted_at IS NULL)
+	`, ids).Scan(&missing)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		errs.add("setores", "setor not found: "+strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// POST /api/kiosks — o token só aparece nesta resposta
+func CreateKiosk(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req kioskRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		var k models.Kiosk
+		_, errs := req.validate(&k, true)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if len(errs) == 0 {
+			if err := checkSetoresExist(ctx, database, &errs, k.Setores); err != nil {
+				log.Println("DB error checking setores:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if errs.write(w) {
+			return
+		}
+
+		callerID, _ := mid.UserIDFromContext(r.Context())
+		token := "ksk_" + randomHex(32)
+
+		err := scanKiosk(database.Pool().QueryRow(ctx, `
+			INSERT INTO kiosks (id, name, token_hash, setores, created_by)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+kioskColumns,
+			uuid.NewString(), k.Name, hashToken(token), k.Setores, nullIfEmpty(callerID),
+		), &k)
+		if err != nil {
+			log.Println("DB error creating kiosk:", err)
+			http.Error(w, "could not create kiosk", http.StatusInternalServerError)
+			return
+		}
+
+		k.Token = token
+		writeJSON(w, http.StatusCreated, k)
+	}
+}
+
+// GET /api/kiosks
+func ListKiosks(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `SELECT `+kioskColumns+` FROM kiosks ORDER BY name`)
+		if err != nil {
+			log.Println("DB error fetching kiosks:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		kiosks := []models.Kiosk{}
+		for rows.Next() {
+			var k models.Kiosk
+			if err := scanKiosk(rows, &k); err != nil {
+				log.Println("DB error scanning kiosk:", err)
+				http.Error(w, "scan error", http.StatusInternalServerError)
+				return
+			}
+			kiosks = append(kiosks, k)
+		}
+
+		writeJSON(w, http.StatusOK, kiosks)
+	}
+}
+
+// PATCH /api/kiosks/{id}
+//
+// "rotate_token": true invalida o token atual e devolve um novo.
+func UpdateKiosk(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var req kioskRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var k models.Kiosk
+		cols, errs := req.validate(&k, false)
+		if req.Setores.Set && len(errs) == 0 {
+			if err := checkSetoresExist(ctx, database, &errs, k.Setores); err != nil {
+				log.Println("DB error checking setores:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if errs.write(w) {
+			return
+		}
+
+		newToken := ""
+		if req.RotateToken.Value {
+			newToken = "ksk_" + randomHex(32)
+			cols = append(cols, column{"token_hash", hashToken(newToken)})
+		}
+		if len(cols) == 0 {
+			http.Error(w, "no valid fields to update", http.StatusBadRequest)
+			return
+		}
+
+		fields := []string{}
+		values := []any{}
+		for _, col := range cols {
+			values = append(values, col.Value)
+			fields = append(fields, fmt.Sprintf("%s = $%d", col.Name, len(values)))
+		}
+		values = append(values, id)
+
+		query := fmt.Sprintf(`
+			UPDATE kiosks
+			SET %s, updated_at = now()
+			WHERE id = $%d
+			RETURNING %s
+		`, strings.Join(fields, ", "), len(values), kioskColumns)
+
+		err = scanKiosk(database.Pool().QueryRow(ctx, query, values...), &k)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "kiosk not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error updating kiosk:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		k.Token = newToken
+		writeJSON(w, http.StatusOK, k)
+	}
+}
+
+// DELETE /api/kiosks/{id}
+func DeleteKiosk(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		cmd, err := database.Pool().Exec(ctx, `DELETE FROM kiosks WHERE id = $1`, id)
+		if err != nil {
+			log.Println("DB error deleting kiosk:", err)
+			http.Error(w, "error deleting kiosk", http.StatusInternalServerError)
+			return
+		}
+
+		if cmd.RowsAffected() == 0 {
+			http.Error(w, "kiosk not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// =====================
+// CREDENCIAIS DO FUNCIONÁRIO
+// =====================
+
+// kioskCredentialTarget resolve o funcionário de /api/users/{id}/... e checa
+// se quem chama pode mexer nas credenciais dele (ele mesmo ou admin/RH).
+func kioskCredentialTarget(w http.ResponseWriter, r *http.Request, ctx context.Context, database *db.Database, selfAllowed bool) (string, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return "", false
+	}
+
+	var userID string
+	err = database.Pool().QueryRow(ctx, `SELECT user_id FROM users WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return "", false
+	} else if err != nil {
+		log.Println("DB error fetching user:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return "", false
+	}
+
+	callerID, _ := mid.UserIDFromContext(r.Context())
+	roles, _ := mid.RoleFromContext(r.Context())
+	if !isPrivileged(roles) && !(selfAllowed && callerID == userID) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return "", false
+	}
+
+	return userID, true
+}
+
+// POST /api/users/{id}/pin — gera um PIN novo (único) e devolve uma vez só.
+// O PIN é sorteado pelo servidor para não dar para descobrir, escolhendo,
+// quais PINs já existem.
+func GenerateUserPIN(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		userID, ok := kioskCredentialTarget(w, r, ctx, database, true)
+		if !ok {
+			return
+		}
+
+		max := big.NewInt(1)
+		for range pinDigits {
+			max.Mul(max, big.NewInt(10))
+		}
+
+		for range 10 {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				http.Error(w, "could not generate pin", http.StatusInternalServerError)
+				return
+			}
+			pin := fmt.Sprintf("%0*d", pinDigits, n)
+
+			_, err = database.Pool().Exec(ctx, `UPDATE users SET pin_hash = $1 WHERE user_id = $2`, hashPIN(pin), userID)
+			if isUniqueViolation(err) {
+				continue
+			}
+			if err != nil {
+				log.Println("DB error saving pin:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+
+			writeJSON(w, http.StatusOK, map[string]string{"pin": pin})
+			return
+		}
+
+		http.Error(w, "could not generate a unique pin", http.StatusConflict)
+	}
+}
+
+// PUT /api/users/{id}/badge {"badge": "..."} — vazio remove
+func SetUserBadge(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Badge string `json:"badge"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		userID, ok := kioskCredentialTarget(w, r, ctx, database, false)
+		if !ok {
+			return
+		}
+
+		badge := strings.TrimSpace(input.Badge)
+		_, err := database.Pool().Exec(ctx, `UPDATE users SET badge = $1 WHERE user_id = $2`, nullIfEmpty(badge), userID)
+		if isUniqueViolation(err) {
+			http.Error(w, "badge already assigned to another user", http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Println("DB error saving badge:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"badge": nullIfEmpty(badge)})
+	}
+}
+
+// POST /api/users/{id}/qr — gera o conteúdo do QR pessoal; o anterior deixa
+// de valer. O cliente é quem desenha o QR.
+func RotateUserQR(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		userID, ok := kioskCredentialTarget(w, r, ctx, database, true)
+		if !ok {
+			return
+		}
+
+		code := kioskQRPrefix + randomHex(24)
+		_, err := database.Pool().Exec(ctx, `UPDATE users SET qr_token_hash = $1 WHERE user_id = $2`, hashToken(code), userID)
+		if err != nil {
+			log.Println("DB error saving qr token:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"qr": code})
+	}
+}
+
+// =====================
+// USO PELO QUIOSQUE
+// =====================
+
+// GET /api/kiosk/me
+func KioskInfo(w http.ResponseWriter, r *http.Request) {
+	k, _ := kioskFromContext(r.Context())
+	writeJSON(w, http.StatusOK, k)
+}
+
+var errKioskLocked = errors.New("kiosk locked")
+
+// identifyAtKiosk acha o funcionário ativo pelo PIN, crachá ou QR. Erros
+// seguidos travam o quiosque por um tempo para segurar tentativa de PIN.
+func identifyAtKiosk(ctx context.Context, database *db.Database, kioskID, pin, badge, qr string) (userID string, setorID *string, err error) {
+	var lockedUntil *time.Time
+	if err := database.Pool().QueryRow(ctx, `
+		SELECT locked_until FROM kiosks WHERE id = $1
+	`, kioskID).Scan(&lockedUntil); err != nil {
+		return "", nil, err
+	}
+	if lockedUntil != nil && lockedUntil.After(time.Now()) {
+		return "", nil, errKioskLocked
+	}
+
+	var column, value string
+	switch {
+	case pin != "":
+		column, value = "pin_hash", hashPIN(pin)
+	case badge != "":
+		column, value = "badge", badge
+	case qr != "":
+		column, value = "qr_token_hash", hashToken(qr)
+	}
+
+	err = database.Pool().QueryRow(ctx, `
+		SELECT user_id, setor_id FROM users
+		WHERE `+column+` = $1 AND status = 'active' AND deleted_at IS NULL
+	`, value).Scan(&userID, &setorID)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, dbErr := database.Pool().Exec(ctx, `
+			UPDATE kiosks
+			SET locked_until = CASE WHEN failed_attempts + 1 >= $2
+			                        THEN now() + $3 * interval '1 second' END,
+			    failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0
+			                           ELSE failed_attempts + 1 END
+			WHERE id = $1
+		`, kioskID, kioskMaxFailures, kioskLockout.Seconds())
+		if dbErr != nil {
+			log.Println("DB error counting kiosk failure:", dbErr)
+		}
+		return "", nil, err
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, err = database.Pool().Exec(ctx, `
+		UPDATE kiosks SET failed_attempts = 0, locked_until = NULL WHERE id = $1
+	`, kioskID)
+	return userID, setorID, err
+}
+
+// POST /api/kiosk/punch (multipart)
+//
+//	data: {"pin": "123456"} | {"badge": "0042"} | {"qr": "kq1:..."}, mais "location"
+//	file: foto
+func KioskPunch(database *db.Database, store storage.Storage, receiptKey ed25519.PrivateKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, ok := kioskFromContext(r.Context())
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			http.Error(w, "invalid multipart form", http.StatusBadRequest)
+			return
+		}
+
+		var input struct {
+			PIN      string `json:"pin"`
+			Badge    string `json:"badge"`
+			QR       string `json:"qr"`
+			Location string `json:"location"`
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("data")), &input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if input.PIN == "" && input.Badge == "" && input.QR == "" {
+			http.Error(w, "pin, badge or qr is required", http.StatusBadRequest)
+			return
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "photo file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		photo, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "could not read photo", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		userID, setorID, err := identifyAtKiosk(ctx, database, k.ID, input.PIN, input.Badge, input.QR)
+		if errors.Is(err, errKioskLocked) {
+			http.Error(w, "too many failed attempts, try again later", http.StatusTooManyRequests)
+			return
+		} else if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "employee not identified", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			log.Println("DB error identifying employee at kiosk:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		// o quiosque só atende os setores a que foi atribuído
+		if setorID == nil || !slices.Contains(k.Setores, *setorID) {
+			http.Error(w, "employee is not assigned to this kiosk", http.StatusForbidden)
+			return
+		}
+
+		kioskID := k.ID
+		registerPunch(w, r, database, store, receiptKey, punchInput{
+			UserID:   userID,
+			Location: input.Location,
+			Photo:    photo,
+			Source:   models.PointSourceKiosk,
+			KioskID:  &kioskID,
+			ClientIP: clientIP(r),
+		})
+	}
+}
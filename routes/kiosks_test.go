@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Rafhael-Viana/m/models"
+)
+
+func TestKioskRequestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		create   bool
+		wantCols []column
+		wantErrs fieldErrors
+	}{
+		{"create", `{"name":" Portaria ","setores":["a"," b ","a",""]}`, true,
+			[]column{{"name", "Portaria"}, {"setores", []string{"a", "b"}}}, nil},
+		{"create without fields", `{}`, true, nil,
+			fieldErrors{{Field: "name", Reason: "is required"}, {Field: "setores", Reason: "is required"}}},
+		{"empty setores", `{"setores":[""]}`, false,
+			[]column{{"setores", []string{}}}, fieldErrors{{Field: "setores", Reason: "is required"}}},
+		{"null setores", `{"setores":null}`, false, nil,
+			fieldErrors{{Field: "setores", Reason: "cannot be null"}}},
+		{"wrong types", `{"active":"yes","rotate_token":1}`, false, nil,
+			fieldErrors{{Field: "active", Reason: "must be a boolean"}, {Field: "rotate_token", Reason: "must be a boolean"}}},
+		{"patch active only", `{"active":false,"rotate_token":true}`, false,
+			[]column{{"active", false}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req kioskRequest
+			if err := json.Unmarshal([]byte(tt.json), &req); err != nil {
+				t.Fatal(err)
+			}
+			var k models.Kiosk
+			cols, errs := req.validate(&k, tt.create)
+			if !reflect.DeepEqual(cols, tt.wantCols) {
+				t.Errorf("cols = %+v, want %+v", cols, tt.wantCols)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("errors = %+v, want %+v", errs, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -30,16 +30,17 @@ func (l *LoginResponse) setToken(token string) {
 }
 
 // geraToken cria um JWT com expiração
-func geraToken(userID string, username string, role string) (string, error) {
+func geraToken(userID string, tenantID string, username string, role string) (string, error) {
 	godotenv.Load()
 	var jwtSecret = []byte(os.Getenv("JWT_SECRET")) // defina JWT_SECRET no ambiente
 
 	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"role":     role,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // expira em 24h
-		"iat":      time.Now().Unix(),
+		"user_id":   userID,
+		"tenant_id": tenantID,
+		"username":  username,
+		"role":      []string{role},                        // middleware.Claims espera uma lista
+		"exp":       time.Now().Add(time.Hour * 24).Unix(), // expira em 24h
+		"iat":       time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -50,7 +51,12 @@ func geraToken(userID string, username string, role string) (string, error) {
 func Login(database *db.Database) http.HandlerFunc {
 	// fmt.Println(jwtSecret)
 	return func(w http.ResponseWriter, r *http.Request) {
-		var u models.User
+		// tenant: slug ou CNPJ da empresa. Só é obrigatório quando o mesmo
+		// username e a mesma senha valem em mais de uma empresa.
+		var u struct {
+			models.User
+			Tenant string `json:"tenant"`
+		}
 		defer r.Body.Close()
 
 		dec := json.NewDecoder(r.Body)
@@ -76,34 +82,66 @@ func Login(database *db.Database) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		// a empresa ainda não é conhecida: busca em todas
+		ctx, cancel := context.WithTimeout(db.AllTenants(r.Context()), 5*time.Second)
 		defer cancel()
 
-		// busca no banco o hash da senha do usuário
-		var hashedPassword string
-		var userID string
-
-		query := `SELECT user_id,senha FROM "users" WHERE username = $1 LIMIT 1`
-		err := database.Pool().QueryRow(ctx, query, u.Username).Scan(&userID, &hashedPassword)
+		type account struct {
+			userID, tenantID, hashedPassword, role, tenantStatus string
+		}
 
-		if errors.Is(err, pgx.ErrNoRows) {
-			http.Error(w, "invalid username or password", http.StatusUnauthorized)
+		// busca no banco o hash da senha do usuário
+		rows, err := database.Pool().Query(ctx, `
+			SELECT u.user_id, u.tenant_id, u.senha, COALESCE(u.role, ''), t.status
+			FROM "users" u
+			JOIN tenants t ON t.tenant_id = u.tenant_id
+			WHERE u.username = $1 AND u.deleted_at IS NULL
+			  AND ($2 = '' OR t.slug = $2 OR t.cnpj = $3)
+		`, u.Username, u.Tenant, onlyDigits(u.Tenant))
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			fmt.Println("DB Error:", err)
 			return
-		} else if err != nil {
+		}
+		accounts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (account, error) {
+			var a account
+			err := row.Scan(&a.userID, &a.tenantID, &a.hashedPassword, &a.role, &a.tenantStatus)
+			return a, err
+		})
+		if err != nil {
 			http.Error(w, "database error", http.StatusInternalServerError)
 			fmt.Println("DB Error:", err)
 			return
 		}
 
-		// compara bcrypt
-		err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(u.Senha))
-		if err != nil {
+		// compara bcrypt com cada conta; a ambiguidade só é revelada para quem
+		// acertou a senha, senão a resposta diria em quais empresas o
+		// username existe
+		matched := []account{}
+		for _, a := range accounts {
+			if bcrypt.CompareHashAndPassword([]byte(a.hashedPassword), []byte(u.Senha)) == nil {
+				matched = append(matched, a)
+			}
+		}
+
+		switch {
+		case len(matched) == 0:
 			http.Error(w, "invalid username or password", http.StatusUnauthorized)
 			return
+		case len(matched) > 1:
+			http.Error(w, "tenant is required", http.StatusBadRequest)
+			return
+		}
+		acc := matched[0]
+		userID, role := acc.userID, acc.role
+
+		if acc.tenantStatus != "active" {
+			http.Error(w, "tenant is suspended", http.StatusForbidden)
+			return
 		}
 
 		// gera jwt token
-		token, err := geraToken(userID, u.Username, u.Role)
+		token, err := geraToken(userID, acc.tenantID, u.Username, role)
 		if err != nil {
 			log.Println(err)
 			return
@@ -0,0 +1,425 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+)
+
+// Políticas de rede do setor
+const (
+	NetworkPolicyOff    = "off"
+	NetworkPolicyFlag   = "flag"
+	NetworkPolicyReject = "reject"
+)
+
+func validNetworkPolicy(p string) bool {
+	return p == NetworkPolicyOff || p == NetworkPolicyFlag || p == NetworkPolicyReject
+}
+
+// trustedProxies vem de TRUSTED_PROXIES (IPs ou CIDRs separados por vírgula).
+// Sem a variável, só o loopback é confiável (proxy/túnel na mesma máquina).
+var trustedProxies = sync.OnceValue(func() []netip.Prefix {
+	raw := os.Getenv("TRUSTED_PROXIES")
+	if raw == "" {
+		raw = "127.0.0.0/8,::1/128"
+	}
+
+	prefixes := []netip.Prefix{}
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		p, err := parsePrefix(v)
+		if err != nil {
+			log.Println("TRUSTED_PROXIES: ignoring invalid entry", v)
+			continue
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes
+})
+
+// parsePrefix aceita CIDR ou IP solto (vira /32 ou /128).
+func parsePrefix(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		p, err := netip.ParsePrefix(v)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func isTrustedProxy(addr netip.Addr) bool {
+	for _, p := range trustedProxies() {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP devolve o IP de quem fez a requisição. X-Forwarded-For só é
+// considerado quando a conexão vem de um proxy confiável, e é lido da direita
+// para a esquerda até o primeiro endereço que não é proxy nosso (o resto da
+// lista pode ter sido forjado pelo cliente).
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	remote = remote.Unmap()
+
+	if !isTrustedProxy(remote) {
+		return remote.String()
+	}
+
+	hops := []string{}
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	if len(hops) == 0 {
+		if v := r.Header.Get("X-Real-IP"); v != "" {
+			hops = []string{v}
+		}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !isTrustedProxy(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// normalizeBSSID deixa o BSSID no formato aa:bb:cc:dd:ee:ff.
+func normalizeBSSID(v string) (string, bool) {
+	mac, err := net.ParseMAC(strings.TrimSpace(v))
+	if err != nil || len(mac) != 6 {
+		return "", false
+	}
+	return mac.String(), true
+}
+
+type networkCheck struct {
+	Policy    string
+	Violation bool
+}
+
+// checkNetwork aplica a política de rede do setor do funcionário. O BSSID é
+// informado pelo próprio app e pode ser forjado, então vale só como indício:
+// com política flag a batida passa limpa se o IP estiver numa faixa permitida
+// ou se o BSSID estiver na lista; com reject só o IP decide, e fora das
+// faixas escreve o 403 e devolve false. A API só aceita reject em setor com
+// alguma regra de IP (requireIPRule).
+func checkNetwork(w http.ResponseWriter, ctx context.Context, database *db.Database, userID, ip, bssid string) (networkCheck, bool) {
+	var (
+		setorID *string
+		policy  string
+	)
+	err := database.Pool().QueryRow(ctx, `
+		SELECT u.setor_id, COALESCE(s.network_policy, 'off')
+		FROM users u
+		LEFT JOIN setores s ON s.setor_id = u.setor_id
+		WHERE u.user_id = $1 AND u.deleted_at IS NULL
+	`, userID).Scan(&setorID, &policy)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return networkCheck{}, false
+	} else if err != nil {
+		log.Println("DB error loading network policy:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return networkCheck{}, false
+	}
+
+	check := networkCheck{Policy: policy}
+	if setorID == nil || policy == NetworkPolicyOff {
+		return check, true
+	}
+
+	rows, err := database.Pool().Query(ctx, `
+		SELECT kind, value FROM setor_network_rules WHERE setor_id = $1
+	`, *setorID)
+	if err != nil {
+		log.Println("DB error loading network rules:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return check, false
+	}
+	defer rows.Close()
+
+	// as regras são gravadas normalizadas; o app pode mandar outro formato
+	if v, ok := normalizeBSSID(bssid); ok {
+		bssid = v
+	} else {
+		bssid = ""
+	}
+
+	addr, addrErr := netip.ParseAddr(ip)
+	hasRules, hasIPRules := false, false
+	ipAllowed, bssidAllowed := false, false
+	for rows.Next() {
+		var kind, value string
+		if err := rows.Scan(&kind, &value); err != nil {
+			log.Println("DB error scanning network rule:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return check, false
+		}
+		hasRules = true
+
+		switch kind {
+		case "ip":
+			hasIPRules = true
+			if p, err := parsePrefix(value); err == nil && addrErr == nil && p.Contains(addr.Unmap()) {
+				ipAllowed = true
+			}
+		case "bssid":
+			if bssid != "" && bssid == value {
+				bssidAllowed = true
+			}
+		}
+	}
+
+	if policy == NetworkPolicyReject {
+		// sem regra de IP não há o que exigir (a API não deixa chegar aqui)
+		if !hasIPRules || ipAllowed {
+			return check, true
+		}
+		http.Error(w, "punch not allowed from this network", http.StatusForbidden)
+		return check, false
+	}
+
+	// setor com política mas sem regras cadastradas não sinaliza ninguém
+	if !hasRules || ipAllowed || bssidAllowed {
+		return check, true
+	}
+
+	check.Violation = true
+	return check, true
+}
+
+// requireIPRule confere se o setor tem ao menos uma regra de IP, o que a
+// política reject exige: o BSSID sozinho não basta para recusar batidas.
+func requireIPRule(ctx context.Context, q rowQuerier, errs *fieldErrors, setorID string) error {
+	var exists bool
+	err := q.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM setor_network_rules WHERE setor_id = $1 AND kind = 'ip')
+	`, setorID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		errs.add("network_policy", "reject requires at least one ip rule")
+	}
+	return nil
+}
+
+func flagNetwork(ctx context.Context, database *db.Database, pointID int, punch, ip, bssid string) {
+	_, err := database.Pool().Exec(ctx, `
+		INSERT INTO point_network_flags (point_id, punch, ip, bssid)
+		VALUES ($1, $2, $3, $4)
+	`, pointID, punch, nullIfEmpty(ip), nullIfEmpty(bssid))
+	if err != nil {
+		log.Println("DB error flagging network:", err)
+	}
+}
+
+// =====================
+// REGRAS DE REDE DO SETOR
+// =====================
+
+type NetworkRule struct {
+	ID          int64     `json:"id"`
+	SetorID     string    `json:"setor_id"`
+	Kind        string    `json:"kind"` // ip|bssid
+	Value       string    `json:"value"`
+	Description *string   `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GET /api/setor/{id}/network-rules
+func ListNetworkRules(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setorID := r.PathValue("id")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT id, setor_id, kind, value, description, created_at
+			FROM setor_network_rules
+			WHERE setor_id = $1
+			ORDER BY kind, value
+		`, setorID)
+		if err != nil {
+			log.Println("DB error fetching network rules:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		rules := []NetworkRule{}
+		for rows.Next() {
+			var n NetworkRule
+			if err := rows.Scan(&n.ID, &n.SetorID, &n.Kind, &n.Value, &n.Description, &n.CreatedAt); err != nil {
+				log.Println("DB error scanning network rule:", err)
+				http.Error(w, "scan error", http.StatusInternalServerError)
+				return
+			}
+			rules = append(rules, n)
+		}
+
+		writeJSON(w, http.StatusOK, rules)
+	}
+}
+
+// POST /api/setor/{id}/network-rules {"kind": "ip", "value": "200.1.2.0/24"}
+func CreateNetworkRule(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setorID := r.PathValue("id")
+
+		var input struct {
+			Kind        string  `json:"kind"`
+			Value       string  `json:"value"`
+			Description *string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		switch input.Kind {
+		case "ip":
+			p, err := parsePrefix(strings.TrimSpace(input.Value))
+			if err != nil {
+				http.Error(w, "invalid ip range (use CIDR, e.g. 200.1.2.0/24)", http.StatusBadRequest)
+				return
+			}
+			input.Value = p.String()
+		case "bssid":
+			v, ok := normalizeBSSID(input.Value)
+			if !ok {
+				http.Error(w, "invalid bssid", http.StatusBadRequest)
+				return
+			}
+			input.Value = v
+		default:
+			http.Error(w, "invalid kind (ip|bssid)", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var exists bool
+		if err := database.Pool().QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM setores WHERE setor_id = $1)
+		`, setorID).Scan(&exists); err != nil {
+			log.Println("DB error fetching setor:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "setor not found", http.StatusNotFound)
+			return
+		}
+
+		n := NetworkRule{SetorID: setorID, Kind: input.Kind, Value: input.Value, Description: input.Description}
+		err := database.Pool().QueryRow(ctx, `
+			INSERT INTO setor_network_rules (setor_id, kind, value, description)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at
+		`, setorID, input.Kind, input.Value, input.Description).Scan(&n.ID, &n.CreatedAt)
+		if isUniqueViolation(err) {
+			http.Error(w, "rule already exists", http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Println("DB error creating network rule:", err)
+			http.Error(w, "could not create rule", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, n)
+	}
+}
+
+// DELETE /api/setor/{id}/network-rules/{rule}
+func DeleteNetworkRule(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ruleID, err := strconv.ParseInt(r.PathValue("rule"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid rule id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		// a última regra de IP de um setor com reject não sai: a política
+		// deixaria de barrar qualquer batida sem ninguém perceber
+		cmd, err := database.Pool().Exec(ctx, `
+			DELETE FROM setor_network_rules r
+			WHERE r.id = $1 AND r.setor_id = $2
+			  AND NOT (
+				r.kind = 'ip'
+				AND EXISTS (SELECT 1 FROM setores s WHERE s.setor_id = r.setor_id AND s.network_policy = 'reject')
+				AND NOT EXISTS (
+					SELECT 1 FROM setor_network_rules o
+					WHERE o.setor_id = r.setor_id AND o.kind = 'ip' AND o.id <> r.id
+				)
+			  )
+		`, ruleID, r.PathValue("id"))
+		if err != nil {
+			log.Println("DB error deleting network rule:", err)
+			http.Error(w, "error deleting rule", http.StatusInternalServerError)
+			return
+		}
+
+		if cmd.RowsAffected() == 0 {
+			var exists bool
+			err := database.Pool().QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM setor_network_rules WHERE id = $1 AND setor_id = $2)
+			`, ruleID, r.PathValue("id")).Scan(&exists)
+			if err != nil {
+				log.Println("DB error fetching network rule:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if exists {
+				http.Error(w, "last ip rule of a setor with reject policy (change network_policy first)", http.StatusConflict)
+				return
+			}
+			http.Error(w, "rule not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// sem TRUSTED_PROXIES só o loopback é proxy confiável
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"direct", "203.0.113.5:1234", nil, "", "203.0.113.5"},
+		{"spoofed xff from untrusted peer", "203.0.113.5:1234", []string{"198.51.100.7"}, "", "203.0.113.5"},
+		{"spoofed x-real-ip from untrusted peer", "203.0.113.5:1234", nil, "198.51.100.7", "203.0.113.5"},
+		{"trusted proxy", "127.0.0.1:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"chain of trusted proxies", "127.0.0.1:1234", []string{"198.51.100.7, 127.0.0.2, 127.0.0.3"}, "", "198.51.100.7"},
+		{"forged hops left of the client", "127.0.0.1:1234", []string{"6.6.6.6, 198.51.100.7"}, "", "198.51.100.7"},
+		{"multiple headers", "127.0.0.1:1234", []string{"6.6.6.6", "198.51.100.7, 127.0.0.2"}, "", "198.51.100.7"},
+		{"only trusted hops", "127.0.0.1:1234", []string{"127.0.0.2"}, "", "127.0.0.2"},
+		{"x-real-ip from trusted proxy", "127.0.0.1:1234", nil, "198.51.100.7", "198.51.100.7"},
+		{"ipv4-mapped peer", "[::ffff:203.0.113.5]:1234", nil, "", "203.0.113.5"},
+		{"ipv4-mapped trusted peer", "[::ffff:127.0.0.1]:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"ipv4-mapped hop", "127.0.0.1:1234", []string{"::ffff:198.51.100.7"}, "", "198.51.100.7"},
+		{"ipv6 peer", "[2001:db8::1]:1234", []string{"198.51.100.7"}, "", "2001:db8::1"},
+		{"invalid hop stops the walk", "127.0.0.1:1234", []string{"198.51.100.7, garbage"}, "", "127.0.0.1"},
+		{"invalid hop behind the client", "127.0.0.1:1234", []string{"garbage, 198.51.100.7"}, "", "198.51.100.7"},
+		{"remote without port", "203.0.113.5", nil, "", "203.0.113.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"200.1.2.0/24", "200.1.2.0/24", false},
+		{"200.1.2.3/24", "200.1.2.0/24", false},
+		{"200.1.2.3", "200.1.2.3/32", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"200.1.2.0/33", "", true},
+		{"200.1.2", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		p, err := parsePrefix(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePrefix(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && p.String() != tt.want {
+			t.Errorf("parsePrefix(%q) = %s, want %s", tt.in, p, tt.want)
+		}
+	}
+}
+
+func TestNormalizeBSSID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"aa:bb:cc:dd:ee:ff", "aa:bb:cc:dd:ee:ff", true},
+		{"AA:BB:CC:DD:EE:FF", "aa:bb:cc:dd:ee:ff", true},
+		{"AA-BB-CC-DD-EE-FF", "aa:bb:cc:dd:ee:ff", true},
+		{"aabb.ccdd.eeff", "aa:bb:cc:dd:ee:ff", true},
+		{" aa:bb:cc:dd:ee:ff ", "aa:bb:cc:dd:ee:ff", true},
+		{"aa:bb:cc:dd:ee:ff:00:11", "", false},
+		{"aa:bb:cc:dd:ee", "", false},
+		{"zz:bb:cc:dd:ee:ff", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := normalizeBSSID(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeBSSID(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// sortField é um campo ordenável: a expressão SQL (sem NULL, para a
+// comparação de tupla do keyset funcionar) e o tipo para o cast do cursor.
+type sortField struct {
+	Expr string
+	Cast string // text|int|timestamptz
+}
+
+// pageSpec descreve a listagem: campos ordenáveis e o desempate (id único).
+type pageSpec struct {
+	ID          string
+	Fields      map[string]sortField
+	DefaultSort string
+	DefaultDesc bool
+}
+
+// pageCursor é opaco para o cliente: o valor de ordenação e o id da última
+// linha devolvida, mais a ordenação em que foi gerado.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+type pageParams struct {
+	spec  pageSpec
+	Limit int
+	Sort  string
+	Desc  bool
+	After *pageCursor
+	Total bool
+}
+
+// parsePage lê limit, sort (campo ou -campo para decrescente), cursor e
+// include_total da query string.
+func parsePage(r *http.Request, spec pageSpec) (pageParams, error) {
+	q := r.URL.Query()
+	p := pageParams{spec: spec, Limit: defaultPageSize, Sort: spec.DefaultSort, Desc: spec.DefaultDesc}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageSize {
+			return p, fmt.Errorf("invalid limit (1-%d)", maxPageSize)
+		}
+		p.Limit = n
+	}
+
+	if v := q.Get("sort"); v != "" {
+		p.Desc = strings.HasPrefix(v, "-")
+		p.Sort = strings.TrimPrefix(v, "-")
+		if _, ok := spec.Fields[p.Sort]; !ok {
+			return p, errors.New("invalid sort field")
+		}
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return p, errors.New("invalid cursor")
+		}
+		var c pageCursor
+		if err := json.Unmarshal(raw, &c); err != nil || c.Sort != p.sortKey() {
+			return p, errors.New("invalid cursor")
+		}
+		p.After = &c
+	}
+
+	p.Total, _ = strconv.ParseBool(q.Get("include_total"))
+	return p, nil
+}
+
+func (p pageParams) sortKey() string {
+	if p.Desc {
+		return "-" + p.Sort
+	}
+	return p.Sort
+}
+
+// SortExpr é a expressão do campo de ordenação, para selecionar junto e
+// montar o próximo cursor.
+func (p pageParams) SortExpr() string {
+	return p.spec.Fields[p.Sort].Expr + "::text"
+}
+
+// Where devolve a condição do keyset (ou "" na primeira página) usando os
+// placeholders a partir de argN.
+func (p pageParams) Where(argN int) (string, []any) {
+	if p.After == nil {
+		return "", nil
+	}
+	f := p.spec.Fields[p.Sort]
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d)", f.Expr, p.spec.ID, op, argN, f.Cast, argN+1),
+		[]any{p.After.Value, p.After.ID}
+}
+
+func (p pageParams) OrderBy() string {
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("%s %s, %s %s", p.spec.Fields[p.Sort].Expr, dir, p.spec.ID, dir)
+}
+
+// Next monta o cursor da próxima página a partir da última linha.
+func (p pageParams) Next(value string, id int64) string {
+	raw, _ := json.Marshal(pageCursor{Sort: p.sortKey(), Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// escapeLike protege %, _ e \ digitados na busca.
+func escapeLike(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(v)
+}
+
+// page é o envelope das listagens paginadas.
+type page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      *int64  `json:"total,omitempty"`
+}
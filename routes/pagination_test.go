@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+var testPageSpec = pageSpec{
+	ID: "u.id",
+	Fields: map[string]sortField{
+		"name":       {Expr: "u.name", Cast: "text"},
+		"created_at": {Expr: "u.created_at", Cast: "timestamptz"},
+	},
+	DefaultSort: "name",
+}
+
+func parseTestPage(t *testing.T, query url.Values) (pageParams, error) {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/api/users?"+query.Encode(), nil)
+	return parsePage(r, testPageSpec)
+}
+
+// O cursor gerado por Next volta igual em parsePage e vira a condição do
+// keyset na mesma ordenação.
+func TestPageCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		sort      string
+		value     string
+		id        int64
+		wantWhere string
+	}{
+		{"name", "Ana", 7, "(u.name, u.id) > ($3::text, $4)"},
+		{"-name", "Zé, \"o\" <ç>", 1, "(u.name, u.id) < ($3::text, $4)"},
+		{"-created_at", "2026-01-02 03:04:05+00", 42, "(u.created_at, u.id) < ($3::timestamptz, $4)"},
+		{"", "", 0, "(u.name, u.id) > ($3::text, $4)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			first, err := parseTestPage(t, url.Values{"sort": {tt.sort}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if where, args := first.Where(3); where != "" || args != nil {
+				t.Errorf("first page Where = %q, %v", where, args)
+			}
+
+			cursor := first.Next(tt.value, tt.id)
+			next, err := parseTestPage(t, url.Values{"sort": {tt.sort}, "cursor": {cursor}})
+			if err != nil {
+				t.Fatalf("parse cursor %q: %v", cursor, err)
+			}
+			if next.After == nil || next.After.Value != tt.value || next.After.ID != tt.id {
+				t.Fatalf("After = %+v, want value %q id %d", next.After, tt.value, tt.id)
+			}
+
+			where, args := next.Where(3)
+			if where != tt.wantWhere {
+				t.Errorf("Where = %q, want %q", where, tt.wantWhere)
+			}
+			if want := []any{tt.value, tt.id}; !reflect.DeepEqual(args, want) {
+				t.Errorf("args = %v, want %v", args, want)
+			}
+		})
+	}
+}
+
+func TestParsePageErrors(t *testing.T) {
+	asc, _ := parseTestPage(t, url.Values{})
+	ascCursor := asc.Next("Ana", 7)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  string
+	}{
+		{"limit zero", url.Values{"limit": {"0"}}, "invalid limit (1-200)"},
+		{"limit too big", url.Values{"limit": {"201"}}, "invalid limit (1-200)"},
+		{"limit not a number", url.Values{"limit": {"ten"}}, "invalid limit (1-200)"},
+		{"unknown sort", url.Values{"sort": {"senha"}}, "invalid sort field"},
+		{"cursor not base64", url.Values{"cursor": {"%%%"}}, "invalid cursor"},
+		{"cursor not json", url.Values{"cursor": {base64.RawURLEncoding.EncodeToString([]byte("nope"))}}, "invalid cursor"},
+		// cursor de outra ordenação não pode ser reaproveitado
+		{"cursor from another sort", url.Values{"sort": {"-name"}, "cursor": {ascCursor}}, "invalid cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestPage(t, tt.query)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("parsePage(%v) error = %v, want %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePageDefaults(t *testing.T) {
+	p, err := parseTestPage(t, url.Values{"limit": {"10"}, "include_total": {"true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Limit != 10 || p.Sort != "name" || p.Desc || !p.Total || p.After != nil {
+		t.Errorf("parsePage = %+v", p)
+	}
+	if got, want := p.OrderBy(), "u.name ASC, u.id ASC"; got != want {
+		t.Errorf("OrderBy = %q, want %q", got, want)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"ana", "ana"},
+		{"100%", `100\%`},
+		{"a_b", `a\_b`},
+		{`c:\x`, `c:\\x`},
+	}
+	for _, tt := range tests {
+		if got := escapeLike(tt.in); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
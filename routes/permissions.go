@@ -0,0 +1,253 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+func hasRole(roles []string, allowed ...string) bool {
+	for _, r := range roles {
+		for _, a := range allowed {
+			if r == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPrivileged diz se o chamador enxerga dados de todos os funcionários.
+func isPrivileged(roles []string) bool {
+	return hasRole(roles, models.RoleSuperAdmin, models.RoleAdmin, models.RoleRH)
+}
+
+// canViewUser: o próprio funcionário, admin/RH ou o líder do setor dele (ou
+// de um setor acima). Ver não depende da hierarquia de papéis.
+func canViewUser(ctx context.Context, database *db.Database, callerID string, roles []string, ownerID string) (bool, error) {
+	if callerID == ownerID || isPrivileged(roles) {
+		return true, nil
+	}
+	return canManageUser(ctx, database, callerID, roles, ownerID)
+}
+
+// roleRank ordena os papéis: super-admin > admin > RH > demais funcionários.
+func roleRank(role string) int {
+	switch role {
+	case models.RoleSuperAdmin:
+		return 4
+	case models.RoleAdmin:
+		return 3
+	case models.RoleRH:
+		return 2
+	}
+	return 1
+}
+
+// callerRank é o maior nível entre os papéis do chamador.
+func callerRank(roles []string) int {
+	rank := 1
+	for _, r := range roles {
+		rank = max(rank, roleRank(r))
+	}
+	return rank
+}
+
+// canManageRole diz se o chamador mexe num funcionário com o papel targetRole:
+// admin/RH só em quem está abaixo dele na hierarquia e o líder (leads: o
+// funcionário está num setor que ele lidera) só em funcionários comuns.
+func canManageRole(callerRoles []string, leads bool, targetRole string) bool {
+	if isPrivileged(callerRoles) {
+		return roleRank(targetRole) < callerRank(callerRoles)
+	}
+	return leads && roleRank(targetRole) == 1
+}
+
+// canManageUser: admin/RH ou o líder do setor do funcionário ou de um setor
+// acima dele na hierarquia (não ele mesmo), sempre abaixo do chamador na
+// hierarquia de papéis (canManageRole).
+func canManageUser(ctx context.Context, database *db.Database, callerID string, roles []string, ownerID string) (bool, error) {
+	if callerID == ownerID {
+		return false, nil
+	}
+
+	var role string
+	var leads bool
+	err := database.Pool().QueryRow(ctx, `
+		SELECT COALESCE(u.role, ''), COALESCE(u.setor_id IN (`+ledSetoresSQL(2)+`), false)
+		FROM users u
+		WHERE u.user_id = $1
+	`, ownerID, callerID).Scan(&role, &leads)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return canManageRole(roles, leads, role), nil
+}
+
+// pointScopeSQL restringe as batidas ao que o chamador enxerga, como no
+// painel de presença (attendanceScope): admin/RH veem todas, o líder as dos
+// setores que lidera (com os sub-setores) e todo mundo as próprias. expr é a
+// coluna do funcionário e $arg o id do chamador; "" quando não há restrição.
+func pointScopeSQL(roles []string, expr string, arg int) string {
+	if isPrivileged(roles) {
+		return ""
+	}
+	return fmt.Sprintf(`(%[1]s = $%[2]d OR %[1]s IN (
+		SELECT user_id FROM users WHERE setor_id IN (%[3]s)))`, expr, arg, ledSetoresSQL(arg))
+}
+
+// callerPointScope aplica pointScopeSQL com o usuário do JWT e devolve a
+// condição e seus argumentos, como pageParams.Where.
+func callerPointScope(r *http.Request, expr string, arg int) (string, []any) {
+	roles, _ := mid.RoleFromContext(r.Context())
+	cond := pointScopeSQL(roles, expr, arg)
+	if cond == "" {
+		return "", nil
+	}
+	callerID, _ := mid.UserIDFromContext(r.Context())
+	return cond, []any{callerID}
+}
+
+// callerCanViewUser aplica canViewUser com o usuário do JWT e já responde 403/500.
+func callerCanViewUser(w http.ResponseWriter, r *http.Request, ctx context.Context, database *db.Database, ownerID string) bool {
+	callerID, _ := mid.UserIDFromContext(r.Context())
+	roles, _ := mid.RoleFromContext(r.Context())
+
+	allowed, err := canViewUser(ctx, database, callerID, roles, ownerID)
+	if err != nil {
+		log.Println("DB error checking permission:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// callerCanManageUser faz o mesmo com canManageUser.
+func callerCanManageUser(w http.ResponseWriter, r *http.Request, ctx context.Context, database *db.Database, ownerID string) bool {
+	callerID, _ := mid.UserIDFromContext(r.Context())
+	roles, _ := mid.RoleFromContext(r.Context())
+
+	allowed, err := canManageUser(ctx, database, callerID, roles, ownerID)
+	if err != nil {
+		log.Println("DB error checking permission:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// pathUser resolve o {id} da rota (id ou user_id) para um usuário fora
+// da lixeira e confere a permissão: manage exige admin/RH ou o líder do setor;
+// sem manage o próprio funcionário também passa.
+func pathUser(w http.ResponseWriter, r *http.Request, ctx context.Context, database *db.Database, manage bool) (int, string, bool) {
+	ref := r.PathValue("id")
+
+	var id int
+	var userID string
+	err := database.Pool().QueryRow(ctx, `
+		SELECT id, user_id FROM users
+		WHERE (id::text = $1 OR user_id = $1) AND deleted_at IS NULL
+		LIMIT 1
+	`, ref).Scan(&id, &userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return 0, "", false
+	} else if err != nil {
+		log.Println("DB error fetching user:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return 0, "", false
+	}
+
+	callerID, _ := mid.UserIDFromContext(r.Context())
+	roles, _ := mid.RoleFromContext(r.Context())
+
+	var allowed bool
+	if manage {
+		allowed, err = canManageUser(ctx, database, callerID, roles, userID)
+	} else {
+		allowed, err = canViewUser(ctx, database, callerID, roles, userID)
+	}
+	if err != nil {
+		log.Println("DB error checking permission:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return 0, "", false
+	}
+	if !allowed {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return 0, "", false
+	}
+
+	return id, userID, true
+}
+
+// roleGrantError diz por que o chamador não pode dar o papel role ("" se
+// pode): papéis acima de funcionário comum só são dados por quem está acima
+// deles na hierarquia, e super-admin só por super-admin.
+func roleGrantError(callerRoles []string, role string) string {
+	rank := roleRank(role)
+	switch {
+	case rank == 1:
+	case role == models.RoleSuperAdmin:
+		if !hasRole(callerRoles, models.RoleSuperAdmin) {
+			return "only a superadmin can grant this role"
+		}
+	case rank >= callerRank(callerRoles):
+		return "cannot grant a role equal to or above your own"
+	}
+	return ""
+}
+
+// checkRoleGrant aplica roleGrantError com os papéis do JWT.
+func checkRoleGrant(r *http.Request, errs *fieldErrors, role string) {
+	roles, _ := mid.RoleFromContext(r.Context())
+	if msg := roleGrantError(roles, role); msg != "" {
+		errs.add("role", msg)
+	}
+}
+
+// privilegedFields são os campos do funcionário que só admin/RH alteram: o
+// líder do setor edita o resto do cadastro da equipe.
+var privilegedFields = []string{"role", "status", "senha", "setor_id", "cpf", "pis", "matricula"}
+
+// checkPrivilegedFields recusa, para quem não é admin/RH, os campos de
+// privilegedFields presentes em cols.
+func checkPrivilegedFields(r *http.Request, errs *fieldErrors, cols []column) {
+	roles, _ := mid.RoleFromContext(r.Context())
+	if isPrivileged(roles) {
+		return
+	}
+	for _, c := range cols {
+		if slices.Contains(privilegedFields, c.Name) {
+			errs.add(c.Name, "can only be changed by admin or rh")
+		}
+	}
+}
+
+// requirePrivileged responde 403 se o chamador não é admin/RH.
+func requirePrivileged(w http.ResponseWriter, r *http.Request) bool {
+	roles, _ := mid.RoleFromContext(r.Context())
+	if !isPrivileged(roles) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// papéis de chamador/alvo; "user" é o funcionário comum e "leader" um
+// funcionário comum que lidera o setor do alvo
+const (
+	roleUser   = "user"
+	roleLeader = "leader"
+)
+
+var matrixRoles = []string{models.RoleSuperAdmin, models.RoleAdmin, models.RoleRH, roleLeader, roleUser}
+
+func callerRoles(role string) []string {
+	if role == roleLeader {
+		return []string{roleUser}
+	}
+	return []string{role}
+}
+
+func TestCanManageRole(t *testing.T) {
+	// want[caller][target]
+	want := map[string]map[string]bool{
+		models.RoleSuperAdmin: {models.RoleSuperAdmin: false, models.RoleAdmin: true, models.RoleRH: true, roleUser: true},
+		models.RoleAdmin:      {models.RoleSuperAdmin: false, models.RoleAdmin: false, models.RoleRH: true, roleUser: true},
+		models.RoleRH:         {models.RoleSuperAdmin: false, models.RoleAdmin: false, models.RoleRH: false, roleUser: true},
+		roleLeader:            {models.RoleSuperAdmin: false, models.RoleAdmin: false, models.RoleRH: false, roleUser: true},
+		roleUser:              {models.RoleSuperAdmin: false, models.RoleAdmin: false, models.RoleRH: false, roleUser: false},
+	}
+
+	for _, caller := range matrixRoles {
+		for _, target := range []string{models.RoleSuperAdmin, models.RoleAdmin, models.RoleRH, roleUser} {
+			t.Run(caller+"/"+target, func(t *testing.T) {
+				leads := caller == roleLeader
+				if got := canManageRole(callerRoles(caller), leads, target); got != want[caller][target] {
+					t.Errorf("canManageRole(%s, %s) = %v, want %v", caller, target, got, want[caller][target])
+				}
+			})
+		}
+	}
+}
+
+func TestCanManageRoleEmptyRole(t *testing.T) {
+	if !canManageRole([]string{models.RoleRH}, false, "") {
+		t.Error("rh should manage a user without role")
+	}
+	if !canManageRole(nil, true, "") {
+		t.Error("leader should manage a user without role in the setor")
+	}
+}
+
+func TestRoleGrantError(t *testing.T) {
+	// want[caller][role]: pode dar o papel?
+	want := map[string]map[string]bool{
+		models.RoleSuperAdmin: {models.RoleSuperAdmin: true, models.RoleAdmin: true, models.RoleRH: true, roleUser: true},
+		models.RoleAdmin:      {models.RoleSuperAdmin: false, models.RoleAdmin: false, models.RoleRH: true, roleUser: true},
+		models.RoleRH:         {models.RoleSuperAdmin: false, models.RoleAdmin: false, models.RoleRH: false, roleUser: true},
+		roleUser:              {models.RoleSuperAdmin: false, models.RoleAdmin: false, models.RoleRH: false, roleUser: true},
+	}
+
+	for caller, grants := range want {
+		for role, ok := range grants {
+			t.Run(caller+"/"+role, func(t *testing.T) {
+				msg := roleGrantError([]string{caller}, role)
+				if (msg == "") != ok {
+					t.Errorf("roleGrantError(%s, %s) = %q, want allowed=%v", caller, role, msg, ok)
+				}
+			})
+		}
+	}
+}
+
+func TestCheckPrivilegedFields(t *testing.T) {
+	cols := []column{
+		{Name: "name", Value: "Ana"},
+		{Name: "senha", Value: "segredo123"},
+		{Name: "setor_id", Value: "s1"},
+		{Name: "role", Value: "rh"},
+	}
+
+	for _, caller := range matrixRoles {
+		t.Run(caller, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/api/users/1", nil)
+			r = r.WithContext(mid.WithClaims(r.Context(), &mid.Claims{UserID: "caller", TenantID: "t1", Role: callerRoles(caller)}))
+
+			var errs fieldErrors
+			checkPrivilegedFields(r, &errs, cols)
+
+			if isPrivileged(callerRoles(caller)) {
+				if len(errs) != 0 {
+					t.Errorf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			got := map[string]bool{}
+			for _, e := range errs {
+				got[e.Field] = true
+			}
+			for _, f := range []string{"senha", "setor_id", "role"} {
+				if !got[f] {
+					t.Errorf("%s not refused: %v", f, errs)
+				}
+			}
+			if got["name"] {
+				t.Errorf("name refused: %v", errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"context"
+	"image"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/imaging"
+)
+
+// distância de Hamming máxima para considerar duas selfies "parecidas"
+const defaultPhotoSimilarity = 6
+
+func photoSimilarityThreshold() int {
+	if v, err := strconv.Atoi(os.Getenv("PHOTO_SIMILARITY_THRESHOLD")); err == nil && v >= 0 {
+		return v
+	}
+	return defaultPhotoSimilarity
+}
+
+// dias de batidas anteriores comparados pelo hash perceptual
+const defaultPhotoMatchWindowDays = 90
+
+func photoMatchWindow() time.Duration {
+	days := defaultPhotoMatchWindowDays
+	if v, err := strconv.Atoi(os.Getenv("PHOTO_MATCH_WINDOW_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+type photoHashes struct {
+	SHA256 string
+	PHash  *int64 // nil quando a imagem não decodifica
+}
+
+func hashPhoto(data []byte, img image.Image) photoHashes {
+	h := photoHashes{SHA256: imaging.SHA256(data)}
+	if img != nil {
+		v := int64(imaging.DHash(img))
+		h.PHash = &v
+	}
+	return h
+}
+
+type photoMatch struct {
+	PointID      int    `json:"matched_point_id"`
+	MatchedPhoto string `json:"matched_photo"`
+	Reason       string `json:"reason"` // exact|similar
+	Distance     int    `json:"distance"`
+}
+
+// findPhotoMatches compara a foto com as anteriores do mesmo usuário
+// (incluindo a foto de entrada da própria batida, quando for a de saída).
+// Cópias exatas são buscadas em todo o histórico pelo índice do SHA-256; as
+// parecidas, só nas batidas da janela de PHOTO_MATCH_WINDOW_DAYS.
+func findPhotoMatches(ctx context.Context, database *db.Database, userID string, pointID int, photo string, h photoHashes) ([]photoMatch, error) {
+	rows, err := database.Pool().Query(ctx, `
+		SELECT id, photo_in_sha256, photo_in_phash, photo_out_sha256, photo_out_phash
+		FROM points
+		WHERE user_id = $1
+		  AND deleted_at IS NULL
+		  AND (id <> $2 OR $3 = 'out')
+		  AND (photo_in_sha256 = $4 OR photo_out_sha256 = $4
+		       OR (clock_in >= $5 AND (photo_in_phash IS NOT NULL OR photo_out_phash IS NOT NULL)))
+	`, userID, pointID, photo, h.SHA256, time.Now().Add(-photoMatchWindow()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threshold := photoSimilarityThreshold()
+	matches := []photoMatch{}
+
+	for rows.Next() {
+		var (
+			id              int
+			inSHA, outSHA   *string
+			inHash, outHash *int64
+		)
+		if err := rows.Scan(&id, &inSHA, &inHash, &outSHA, &outHash); err != nil {
+			return nil, err
+		}
+
+		candidates := []struct {
+			photo string
+			sha   *string
+			hash  *int64
+		}{
+			{"in", inSHA, inHash},
+			{"out", outSHA, outHash},
+		}
+
+		for _, c := range candidates {
+			// a própria foto que acabou de ser gravada
+			if id == pointID && c.photo == photo {
+				continue
+			}
+			if c.sha != nil && *c.sha == h.SHA256 {
+				matches = append(matches, photoMatch{PointID: id, MatchedPhoto: c.photo, Reason: "exact"})
+				continue
+			}
+			if c.hash != nil && h.PHash != nil {
+				d := imaging.Distance(uint64(*c.hash), uint64(*h.PHash))
+				if d <= threshold {
+					matches = append(matches, photoMatch{PointID: id, MatchedPhoto: c.photo, Reason: "similar", Distance: d})
+				}
+			}
+		}
+	}
+
+	return matches, rows.Err()
+}
+
+// flagPhoto procura fotos repetidas e registra em point_photo_flags.
+func flagPhoto(ctx context.Context, database *db.Database, userID string, pointID int, photo string, h photoHashes) ([]photoMatch, error) {
+	matches, err := findPhotoMatches(ctx, database, userID, pointID, photo, h)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range matches {
+		_, err := database.Pool().Exec(ctx, `
+			INSERT INTO point_photo_flags (point_id, photo, reason, matched_point_id, matched_photo, distance)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, pointID, photo, m.Reason, m.PointID, m.MatchedPhoto, m.Distance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
@@ -2,26 +2,31 @@ package routes
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 
 	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/imaging"
+	"github.com/Rafhael-Viana/m/live"
+	mid "github.com/Rafhael-Viana/m/middlewares"
 	"github.com/Rafhael-Viana/m/models" // ajuste conforme o seu path real
+	"github.com/Rafhael-Viana/m/presence"
+	"github.com/Rafhael-Viana/m/storage"
+	"github.com/Rafhael-Viana/m/webhooks"
 )
 
 // --- CREATE ---
-func CreatePoint(database *db.Database) http.HandlerFunc {
+func CreatePoint(database *db.Database, store storage.Storage, receiptKey ed25519.PrivateKey, presenceSigner *presence.Signer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		if err := r.ParseMultipartForm(10 << 20); err != nil {
@@ -37,8 +42,11 @@ func CreatePoint(database *db.Database) http.HandlerFunc {
 		}
 
 		var input struct {
-			UserID   string `json:"user_id"`
-			Location string `json:"location"`
+			UserID       string `json:"user_id"` // padrão: o usuário do JWT
+			Location     string `json:"location"`
+			PresenceCode string `json:"presence_code"` // QR rotativo do local, se o setor exigir
+			BSSID        string `json:"bssid"`         // Wi-Fi conectado, informado pelo app
+			deviceProof
 		}
 
 		if err := json.Unmarshal([]byte(data), &input); err != nil {
@@ -46,144 +54,477 @@ func CreatePoint(database *db.Database) http.HandlerFunc {
 			return
 		}
 
+		// a batida é de quem está logado; outro user_id só para quem gerencia
+		// o funcionário (conferido abaixo)
+		callerID, _ := mid.UserIDFromContext(r.Context())
 		if input.UserID == "" {
-			http.Error(w, "user_id is required", http.StatusBadRequest)
-			return
+			input.UserID = callerID
+		}
+
+		if input.BSSID != "" {
+			bssid, ok := normalizeBSSID(input.BSSID)
+			if !ok {
+				http.Error(w, "invalid bssid", http.StatusBadRequest)
+				return
+			}
+			input.BSSID = bssid
 		}
 
 		// -------- FILE --------
-		file, handler, err := r.FormFile("file")
+		file, _, err := r.FormFile("file")
 		if err != nil {
 			http.Error(w, "photo file is required", http.StatusBadRequest)
 			return
 		}
 		defer file.Close()
 
-		allowed := map[string]bool{
-			"image/jpeg": true,
-			"image/png":  true,
+		photo, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "could not read photo", http.StatusBadRequest)
+			return
 		}
 
-		contentType := handler.Header.Get("Content-Type")
-		if !allowed[contentType] {
-			http.Error(w, "invalid image type", http.StatusForbidden)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if input.UserID != callerID && !callerCanManageUser(w, r, ctx, database, input.UserID) {
 			return
 		}
 
-		now := time.Now()
+		qr, ok := checkPresenceCode(w, ctx, database, presenceSigner, input.UserID, input.PresenceCode)
+		if !ok {
+			return
+		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		// aparelho confiável: assinatura da batida com a chave do aparelho
+		device, ok := checkDevice(w, ctx, database, input.UserID, input.deviceProof, imaging.SHA256(photo))
+		if !ok {
+			return
+		}
 
-		// -------- CHECK OPEN POINT --------
-		var pointID int
-		err = database.Pool().QueryRow(ctx, `
-			SELECT id FROM points
-			WHERE user_id = $1 AND status = 'open'
-		`, input.UserID).Scan(&pointID)
+		registerPunch(w, r, database, store, receiptKey, punchInput{
+			UserID:   input.UserID,
+			Location: input.Location,
+			Photo:    photo,
+			Source:   models.PointSourceApp,
+			ClientIP: clientIP(r),
+			BSSID:    input.BSSID,
+			Device:   device,
+			Presence: qr,
+		})
+	}
+}
 
-		// -------- CLOCK-IN --------
-		if errors.Is(err, pgx.ErrNoRows) {
+// punchInput é uma batida já identificada: pelo app do funcionário ou por
+// um quiosque.
+type punchInput struct {
+	UserID   string
+	Location string
+	Photo    []byte
+	Source   models.PointSource
+	KioskID  *string
+	ClientIP string
+	BSSID    string
+	Device   deviceCheck
+	Presence *presenceUse // QR do local, gravado na transação da batida
+}
+
+// registerPunch abre ou fecha o ponto do funcionário e escreve a resposta.
+func registerPunch(w http.ResponseWriter, r *http.Request, database *db.Database, store storage.Storage, receiptKey ed25519.PrivateKey, input punchInput) {
+	photo := input.Photo
+
+	// o tipo vem dos bytes, não do Content-Type enviado pelo cliente. Decodifica
+	// uma vez só: hash perceptual e pipeline usam a mesma imagem.
+	decoded, err := imaging.Decode(photo)
+	if err != nil {
+		imageError(w, err)
+		return
+	}
+	hashes := hashPhoto(photo, decoded.Image)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// horário da batida no fuso do funcionário (ou do setor dele)
+	loc, err := userLocation(ctx, database, input.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
 
-			dir := filepath.Join("uploads", "points", input.UserID, "in")
-			os.MkdirAll(dir, 0755)
+	now := time.Now().In(loc)
 
-			filename := uuid.New().String() + filepath.Ext(handler.Filename)
-			fullPath := filepath.Join(dir, filename)
+	// política de rede do setor (IP / Wi-Fi)
+	network, ok := checkNetwork(w, ctx, database, input.UserID, input.ClientIP, input.BSSID)
+	if !ok {
+		return
+	}
 
-			dst, _ := os.Create(fullPath)
-			defer dst.Close()
-			io.Copy(dst, file)
+	// re-encoda (remove EXIF/GPS), redimensiona e gera a miniatura
+	opts := imaging.DefaultOptions
+	opts.Location = loc
+	processed, err := decoded.Process(opts)
+	if err != nil {
+		imageError(w, err)
+		return
+	}
 
-			photoIn := fmt.Sprintf("/uploads/points/%s/in/%s", input.UserID, filename)
+	// regras do contrato: quanto já foi trabalhado no dia (com a batida aberta)
+	contract, err := loadContractDay(ctx, database, input.UserID, now)
+	if err != nil {
+		log.Println("DB error loading contract:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
 
-			err = database.Pool().QueryRow(ctx, `
-				INSERT INTO points (
-					user_id, clock_in, status,
-					location_in, photo_in
-				)
-				VALUES ($1,$2,'open',$3,$4)
-				RETURNING id
-			`, input.UserID, now, input.Location, photoIn).Scan(&pointID)
+	// -------- CHECK OPEN POINT --------
+	var pointID int
+	err = database.Pool().QueryRow(ctx, `
+		SELECT id FROM points
+		WHERE user_id = $1 AND status = 'open' AND deleted_at IS NULL
+	`, input.UserID).Scan(&pointID)
 
-			if err != nil {
-				http.Error(w, "error creating point", http.StatusInternalServerError)
-				return
-			}
+	// -------- CLOCK-IN --------
+	if errors.Is(err, pgx.ErrNoRows) {
+		if !checkContractLimit(w, contract) {
+			return
+		}
+
+		saved, err := saveImage(ctx, store, processed, "points", input.UserID, "in")
+		if err != nil {
+			http.Error(w, "could not save photo", http.StatusInternalServerError)
+			return
+		}
+		photoIn := saved.URL
+
+		// a marcação e o comprovante são gravados juntos
+		tx, err := database.Pool().Begin(ctx)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO points (
+				user_id, clock_in, status,
+				location_in, photo_in,
+				photo_in_sha256, photo_in_phash,
+				photo_in_thumb, photo_in_taken_at,
+				source, kiosk_id, ip_in, bssid_in, device_in
+			)
+			VALUES ($1,$2,'open',$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+			RETURNING id
+		`, input.UserID, now, input.Location, photoIn, hashes.SHA256, hashes.PHash,
+			saved.ThumbURL, processed.TakenAt, input.Source, input.KioskID,
+			nullIfEmpty(input.ClientIP), nullIfEmpty(input.BSSID), input.Device.DeviceID).Scan(&pointID)
+
+		if err != nil {
+			http.Error(w, "error creating point", http.StatusInternalServerError)
+			return
+		}
+		if !recordPresenceUse(w, ctx, tx, input.UserID, input.Presence) {
+			return
+		}
 
-			json.NewEncoder(w).Encode(map[string]any{
-				"id":          pointID,
-				"status":      "open",
-				"clock_in":    now,
-				"location_in": input.Location,
-				"photo_in":    photoIn,
-			})
+		// comprovante da marcação (Portaria 671)
+		rc, err := issueReceipt(ctx, tx, receiptKey, pointID, "in", input.UserID, now)
+		if err != nil {
+			log.Println("DB error issuing receipt:", err)
+			http.Error(w, "could not issue receipt", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			log.Println("DB error committing point:", err)
+			http.Error(w, "error creating point", http.StatusInternalServerError)
 			return
 		}
 
-		// -------- CLOCK-OUT --------
-		if err == nil {
+		// a batida é aceita; fotos repetidas só ficam sinalizadas para auditoria
+		if network.Violation {
+			flagNetwork(ctx, database, pointID, "in", input.ClientIP, input.BSSID)
+		}
+		if input.Device.Reason != "" {
+			flagDevice(ctx, database, pointID, "in", input.Device)
+		}
 
-			dir := filepath.Join("uploads", "points", input.UserID, "out")
-			os.MkdirAll(dir, 0755)
+		matches, err := flagPhoto(ctx, database, input.UserID, pointID, "in", hashes)
+		if err != nil {
+			log.Println("DB error checking photo:", err)
+		}
 
-			filename := uuid.New().String() + filepath.Ext(handler.Filename)
-			fullPath := filepath.Join(dir, filename)
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":              pointID,
+			"status":          "open",
+			"clock_in":        now,
+			"location_in":     input.Location,
+			"photo_in":        photoIn,
+			"thumb_in":        saved.ThumbURL,
+			"suspicious":      len(matches) > 0,
+			"network_flagged": network.Violation,
+			"device_flagged":  input.Device.Reason != "",
+			"receipt":         receiptSummary(rc),
+		})
+
+		emitWebhook(ctx, database, webhooks.EventPointClockIn, map[string]any{
+			"point_id": pointID,
+			"user_id":  input.UserID,
+			"clock_in": now,
+			"location": input.Location,
+			"source":   input.Source,
+		})
+
+		publishAttendance(ctx, database, live.Event{
+			Type:    live.EventPunch,
+			Action:  "clock_in",
+			UserID:  input.UserID,
+			PointID: pointID,
+			Status:  live.StatusClockedIn,
+			At:      now,
+		})
+		return
+	}
 
-			dst, _ := os.Create(fullPath)
-			defer dst.Close()
-			io.Copy(dst, file)
+	// -------- CLOCK-OUT --------
+	if err == nil {
 
-			photoOut := fmt.Sprintf("/uploads/points/%s/out/%s", input.UserID, filename)
+		saved, err := saveImage(ctx, store, processed, "points", input.UserID, "out")
+		if err != nil {
+			http.Error(w, "could not save photo", http.StatusInternalServerError)
+			return
+		}
+		photoOut := saved.URL
 
-			_, err := database.Pool().Exec(ctx, `
-				UPDATE points
-				SET clock_out = $1,
-				    status = 'close',
-				    location_out = $2,
-				    photo_out = $3,
-				    updated_at = now()
-				WHERE id = $4
-			`, now, input.Location, photoOut, pointID)
+		tx, err := database.Pool().Begin(ctx)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
 
-			if err != nil {
-				http.Error(w, "error closing point", http.StatusInternalServerError)
-				return
-			}
+		_, err = tx.Exec(ctx, `
+			UPDATE points
+			SET clock_out = $1,
+			    status = 'close',
+			    location_out = $2,
+			    photo_out = $3,
+			    photo_out_sha256 = $4,
+			    photo_out_phash = $5,
+			    photo_out_thumb = $6,
+			    photo_out_taken_at = $7,
+			    source_out = $8,
+			    kiosk_out_id = $9,
+			    ip_out = $10,
+			    bssid_out = $11,
+			    device_out = $12,
+			    updated_at = now()
+			WHERE id = $13
+		`, now, input.Location, photoOut, hashes.SHA256, hashes.PHash,
+			saved.ThumbURL, processed.TakenAt, input.Source, input.KioskID,
+			nullIfEmpty(input.ClientIP), nullIfEmpty(input.BSSID), input.Device.DeviceID, pointID)
 
-			json.NewEncoder(w).Encode(map[string]any{
-				"id":           pointID,
-				"status":       "close",
-				"clock_out":    now,
-				"location_out": input.Location,
-				"photo_out":    photoOut,
-			})
+		if err != nil {
+			http.Error(w, "error closing point", http.StatusInternalServerError)
+			return
+		}
+		if !recordPresenceUse(w, ctx, tx, input.UserID, input.Presence) {
 			return
 		}
 
-		http.Error(w, "unexpected error", http.StatusInternalServerError)
+		rc, err := issueReceipt(ctx, tx, receiptKey, pointID, "out", input.UserID, now)
+		if err != nil {
+			log.Println("DB error issuing receipt:", err)
+			http.Error(w, "could not issue receipt", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			log.Println("DB error committing point:", err)
+			http.Error(w, "error closing point", http.StatusInternalServerError)
+			return
+		}
+
+		if network.Violation {
+			flagNetwork(ctx, database, pointID, "out", input.ClientIP, input.BSSID)
+		}
+		if input.Device.Reason != "" {
+			flagDevice(ctx, database, pointID, "out", input.Device)
+		}
+
+		matches, err := flagPhoto(ctx, database, input.UserID, pointID, "out", hashes)
+		if err != nil {
+			log.Println("DB error checking photo:", err)
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":               pointID,
+			"status":           "close",
+			"clock_out":        now,
+			"location_out":     input.Location,
+			"photo_out":        photoOut,
+			"thumb_out":        saved.ThumbURL,
+			"suspicious":       len(matches) > 0,
+			"network_flagged":  network.Violation,
+			"device_flagged":   input.Device.Reason != "",
+			"receipt":          receiptSummary(rc),
+			"worked_minutes":   contract.WorkedMinutes,
+			"over_daily_limit": contract.WorkedMinutes > contract.Contract.DailyLimit(),
+		})
+
+		emitWebhook(ctx, database, webhooks.EventPointClockOut, map[string]any{
+			"point_id":  pointID,
+			"user_id":   input.UserID,
+			"clock_out": now,
+			"location":  input.Location,
+			"source":    input.Source,
+		})
+
+		publishAttendance(ctx, database, live.Event{
+			Type:    live.EventPunch,
+			Action:  "clock_out",
+			UserID:  input.UserID,
+			PointID: pointID,
+			Status:  live.StatusOnBreak,
+			At:      now,
+		})
+		return
 	}
+
+	http.Error(w, "unexpected error", http.StatusInternalServerError)
 }
 
 // --- LIST ALL ---
+var pointPageSpec = pageSpec{
+	ID: "p.id",
+	Fields: map[string]sortField{
+		"id":         {Expr: "p.id", Cast: "int"},
+		"clock_in":   {Expr: "COALESCE(p.clock_in, '-infinity')", Cast: "timestamptz"},
+		"clock_out":  {Expr: "COALESCE(p.clock_out, '-infinity')", Cast: "timestamptz"},
+		"created_at": {Expr: "COALESCE(p.created_at, '-infinity')", Cast: "timestamptz"},
+	},
+	DefaultSort: "created_at",
+	DefaultDesc: true,
+}
+
+// GET /api/points?user_id=&setor_id=&include_subsetores=true&status=&from=YYYY-MM-DD&to=YYYY-MM-DD&sort=-clock_in&limit=&cursor=&include_total=true
+//
+// from/to são dias locais do funcionário, como nos relatórios.
 func ListPoints(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		pg, err := parsePage(r, pointPageSpec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		where := []string{"p.deleted_at IS NULL"}
+		args := []any{defaultTimezone()}
+		argN := 2
+		tzExpr := effectiveTimezoneSQL(1)
+
+		// só as batidas que o chamador enxerga
+		if cond, sargs := callerPointScope(r, "p.user_id", argN); cond != "" {
+			where = append(where, cond)
+			args = append(args, sargs...)
+			argN += len(sargs)
+		}
+
+		if v := strings.TrimSpace(q.Get("user_id")); v != "" {
+			where = append(where, fmt.Sprintf("p.user_id = $%d", argN))
+			args = append(args, v)
+			argN++
+		}
+		if v := strings.TrimSpace(q.Get("setor_id")); v != "" {
+			where = append(where, setorFilterSQL(q, "u.setor_id", argN))
+			args = append(args, v)
+			argN++
+		}
+		if v := strings.TrimSpace(q.Get("status")); v != "" {
+			if v != string(models.StatusOpen) && v != string(models.StatusClosed) {
+				http.Error(w, "invalid status", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("p.status = $%d", argN))
+			args = append(args, v)
+			argN++
+		}
+		if v := q.Get("from"); v != "" {
+			d, err := parseDateOnly(v)
+			if err != nil {
+				http.Error(w, "invalid from (use YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) >= $%d::timestamp", tzExpr, argN))
+			args = append(args, d)
+			argN++
+		}
+		if v := q.Get("to"); v != "" {
+			d, err := parseDateOnly(v)
+			if err != nil {
+				http.Error(w, "invalid to (use YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) < $%d::timestamp", tzExpr, argN))
+			args = append(args, d.AddDate(0, 0, 1))
+			argN++
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		from := `
+			FROM points p
+			LEFT JOIN users u ON u.user_id = p.user_id
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
+		`
 
-		rows, err := database.Pool().Query(context.Background(), `
-			SELECT id, user_id, clock_in, clock_out, status, created_at, updated_at
-			FROM points
-			ORDER BY created_at DESC
-		`)
+		var total *int64
+		if pg.Total {
+			var n int64
+			err := database.Pool().QueryRow(ctx, `SELECT COUNT(*) `+from+` WHERE `+strings.Join(where, " AND "), args...).Scan(&n)
+			if err != nil {
+				log.Println("DB error counting points:", err)
+				http.Error(w, "error fetching points", http.StatusInternalServerError)
+				return
+			}
+			total = &n
+		}
+
+		if cond, cargs := pg.Where(argN); cond != "" {
+			where = append(where, cond)
+			args = append(args, cargs...)
+			argN += len(cargs)
+		}
+		args = append(args, pg.Limit+1)
+
+		query := fmt.Sprintf(`
+			SELECT p.id, p.user_id, p.clock_in, p.clock_out, p.status, p.created_at, p.updated_at,
+				p.photo_in_thumb, p.photo_out_thumb,
+				%s,
+				%s
+			%s
+			WHERE %s
+			ORDER BY %s
+			LIMIT $%d
+		`, tzExpr, pg.SortExpr(), from, strings.Join(where, " AND "), pg.OrderBy(), argN)
+
+		rows, err := database.Pool().Query(ctx, query, args...)
 		if err != nil {
+			log.Println("DB error fetching points:", err)
 			http.Error(w, "error fetching points", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var points []models.Point
+		points := []models.Point{}
+		sortValues := []string{}
 		for rows.Next() {
 			var p models.Point
-			rows.Scan(
+			var sortValue string
+			if err := rows.Scan(
 				&p.ID,
 				&p.User_ID,
 				&p.Clock_In,
@@ -191,11 +532,32 @@ func ListPoints(database *db.Database) http.HandlerFunc {
 				&p.Status,
 				&p.CreatedAt,
 				&p.UpdatedAt,
-			)
+				&p.ThumbIn,
+				&p.ThumbOut,
+				&p.Timezone,
+				&sortValue,
+			); err != nil {
+				log.Println("DB error scanning points:", err)
+				http.Error(w, "error fetching points", http.StatusInternalServerError)
+				return
+			}
+			loc := loadLocation(p.Timezone)
+			p.Clock_In = inZone(p.Clock_In, loc)
+			p.Clock_Out = inZone(p.Clock_Out, loc)
 			points = append(points, p)
+			sortValues = append(sortValues, sortValue)
 		}
 
-		json.NewEncoder(w).Encode(points)
+		// buscamos uma linha a mais só para saber se existe próxima página
+		res := page[models.Point]{Items: points, Total: total}
+		if len(points) > pg.Limit {
+			res.Items = points[:pg.Limit]
+			last := pg.Limit - 1
+			next := pg.Next(sortValues[last], int64(points[last].ID))
+			res.NextCursor = &next
+		}
+
+		json.NewEncoder(w).Encode(res)
 	}
 }
 
@@ -213,16 +575,27 @@ func GetPoint(database *db.Database) http.HandlerFunc {
 
 		var p models.Point
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
 		query := `
-			SELECT id, user_id, clock_in, clock_out, status, created_at, updated_at
-			FROM points
-			WHERE id = $1
+			SELECT p.id, p.user_id, p.clock_in, p.clock_out, p.status, p.created_at, p.updated_at,
+				p.photo_in_thumb, p.photo_out_thumb,
+				` + effectiveTimezoneSQL(2) + `
+			FROM points p
+			LEFT JOIN users u ON u.user_id = p.user_id
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
+			WHERE p.id = $1 AND p.deleted_at IS NULL
 		`
+		args := []any{id, defaultTimezone()}
 
-		err = database.Pool().QueryRow(ctx, query, id).Scan(
+		// fora do escopo do chamador a batida "não existe"
+		if cond, sargs := callerPointScope(r, "p.user_id", 3); cond != "" {
+			query += " AND " + cond
+			args = append(args, sargs...)
+		}
+
+		err = database.Pool().QueryRow(ctx, query, args...).Scan(
 			&p.ID,
 			&p.User_ID,
 			&p.Clock_In,
@@ -230,6 +603,9 @@ func GetPoint(database *db.Database) http.HandlerFunc {
 			&p.Status,
 			&p.CreatedAt,
 			&p.UpdatedAt,
+			&p.ThumbIn,
+			&p.ThumbOut,
+			&p.Timezone,
 		)
 
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -237,11 +613,78 @@ func GetPoint(database *db.Database) http.HandlerFunc {
 			return
 		}
 
+		loc := loadLocation(p.Timezone)
+		p.Clock_In = inZone(p.Clock_In, loc)
+		p.Clock_Out = inZone(p.Clock_Out, loc)
+
 		json.NewEncoder(w).Encode(p)
 	}
 }
 
 // --- UPDATE (PATCH) ---
+// pointPatch é o corpo da correção de batida; horários em RFC3339.
+type pointPatch struct {
+	ClockIn  optional[string] `json:"clock_in"`
+	ClockOut optional[string] `json:"clock_out"`
+	Status   optional[string] `json:"status"`
+}
+
+func (req pointPatch) validate() (cols []column, errs fieldErrors) {
+	var clockIn, clockOut *time.Time
+
+	if req.ClockIn.ok(&errs, "clock_in", false) {
+		if t, err := time.Parse(time.RFC3339, req.ClockIn.Value); err != nil {
+			errs.add("clock_in", "must be an RFC3339 timestamp")
+		} else {
+			clockIn = &t
+			cols = append(cols, column{"clock_in", clockIn})
+		}
+	}
+
+	// clock_out null reabre a batida
+	if req.ClockOut.ok(&errs, "clock_out", true) {
+		if req.ClockOut.Null {
+			cols = append(cols, column{"clock_out", nil})
+		} else if t, err := time.Parse(time.RFC3339, req.ClockOut.Value); err != nil {
+			errs.add("clock_out", "must be an RFC3339 timestamp")
+		} else {
+			clockOut = &t
+			cols = append(cols, column{"clock_out", clockOut})
+		}
+	}
+
+	if req.Status.ok(&errs, "status", false) {
+		status := models.StatusPoint(req.Status.Value)
+		if status != models.StatusOpen && status != models.StatusClosed {
+			errs.add("status", "must be open or close")
+		}
+		cols = append(cols, column{"status", status})
+	}
+
+	// Validação lógica
+	if clockIn != nil && clockOut != nil && clockOut.Before(*clockIn) {
+		errs.add("clock_out", "cannot be before clock_in")
+	}
+	return cols, errs
+}
+
+// managedPoint confere que a batida existe fora da lixeira e que o chamador
+// pode corrigi-la (admin/RH ou o líder do setor do dono). Já responde
+// 404/403/500.
+func managedPoint(w http.ResponseWriter, r *http.Request, ctx context.Context, database *db.Database, id int) bool {
+	var ownerID string
+	err := database.Pool().QueryRow(ctx, `SELECT user_id FROM points WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "point not found", http.StatusNotFound)
+		return false
+	} else if err != nil {
+		log.Println("DB error fetching point:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return false
+	}
+	return callerCanManageUser(w, r, ctx, database, ownerID)
+}
+
 func UpdatePoint(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -252,55 +695,22 @@ func UpdatePoint(database *db.Database) http.HandlerFunc {
 			return
 		}
 
+		var req pointPatch
 		var input map[string]any
-		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		if !decodeBody(w, r, &req, &input) {
+			return
+		}
+
+		cols, errs := req.validate()
+		if errs.write(w) {
 			return
 		}
-		defer r.Body.Close()
 
 		fields := []string{}
 		values := []any{}
-		i := 1
-
-		var clockIn, clockOut *time.Time
-		var status models.StatusPoint
-
-		for key, value := range input {
-			switch key {
-
-			case "clock_in":
-				t, err := time.Parse(time.RFC3339, value.(string))
-				if err != nil {
-					http.Error(w, "invalid clock_in", http.StatusBadRequest)
-					return
-				}
-				clockIn = &t
-				fields = append(fields, fmt.Sprintf("clock_in = $%d", i))
-				values = append(values, clockIn)
-				i++
-
-			case "clock_out":
-				t, err := time.Parse(time.RFC3339, value.(string))
-				if err != nil {
-					http.Error(w, "invalid clock_out", http.StatusBadRequest)
-					return
-				}
-				clockOut = &t
-				fields = append(fields, fmt.Sprintf("clock_out = $%d", i))
-				values = append(values, clockOut)
-				i++
-
-			case "status":
-				status = models.StatusPoint(value.(string))
-				if status != models.StatusOpen && status != models.StatusClosed {
-					http.Error(w, "invalid status", http.StatusBadRequest)
-					return
-				}
-				fields = append(fields, fmt.Sprintf("status = $%d", i))
-				values = append(values, status)
-				i++
-			}
+		for _, c := range cols {
+			values = append(values, c.Value)
+			fields = append(fields, fmt.Sprintf("%s = $%d", c.Name, len(values)))
 		}
 
 		if len(fields) == 0 {
@@ -308,30 +718,45 @@ func UpdatePoint(database *db.Database) http.HandlerFunc {
 			return
 		}
 
-		// Validação lógica
-		if clockIn != nil && clockOut != nil && clockOut.Before(*clockIn) {
-			http.Error(w, "clock_out cannot be before clock_in", http.StatusBadRequest)
-			return
-		}
-
 		values = append(values, id)
 
 		query := fmt.Sprintf(`
 			UPDATE points
 			SET %s, updated_at = now()
-			WHERE id = $%d
+			WHERE id = $%d AND deleted_at IS NULL
 		`, strings.Join(fields, ", "), len(values))
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		cmd, err := database.Pool().Exec(ctx, query, values...)
-		if err != nil || cmd.RowsAffected() == 0 {
+		if !managedPoint(w, r, ctx, database, id) {
+			return
+		}
+
+		var userID string
+		err = database.Pool().QueryRow(ctx, query+` RETURNING user_id`, values...).Scan(&userID)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
 			http.Error(w, "point not found or not updated", http.StatusNotFound)
 			return
 		}
 
 		json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+
+		emitWebhook(ctx, database, webhooks.EventPointUpdated, map[string]any{
+			"point_id": id,
+			"user_id":  userID,
+			"changes":  input,
+		})
+
+		publishAttendance(ctx, database, live.Event{
+			Type:    live.EventCorrection,
+			Action:  "updated",
+			UserID:  userID,
+			PointID: id,
+		})
 	}
 }
 
@@ -346,23 +771,44 @@ func DeletePoint(database *db.Database) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		cmd, err := database.Pool().Exec(ctx, `
-			DELETE FROM points WHERE id = $1
-		`, id)
-
-		if err != nil {
-			http.Error(w, "error deleting point", http.StatusInternalServerError)
+		if !managedPoint(w, r, ctx, database, id) {
 			return
 		}
 
-		if cmd.RowsAffected() == 0 {
+		var userID string
+		// Exclusão lógica: a batida vai para a lixeira e pode ser restaurada.
+		err = database.Pool().QueryRow(ctx, `
+			UPDATE points
+			SET deleted_at = now(), deleted_by = $2, delete_reason = $3
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING user_id
+		`, id, deletedBy(r), deleteReason(r)).Scan(&userID)
+
+		if errors.Is(err, pgx.ErrNoRows) {
 			http.Error(w, "point not found", http.StatusNotFound)
 			return
 		}
 
+		if err != nil {
+			http.Error(w, "error deleting point", http.StatusInternalServerError)
+			return
+		}
+
 		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+
+		emitWebhook(ctx, database, webhooks.EventPointDeleted, map[string]any{
+			"point_id": id,
+			"user_id":  userID,
+		})
+
+		publishAttendance(ctx, database, live.Event{
+			Type:    live.EventCorrection,
+			Action:  "deleted",
+			UserID:  userID,
+			PointID: id,
+		})
 	}
 }
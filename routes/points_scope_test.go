@@ -0,0 +1,270 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+func TestPointScopeSQL(t *testing.T) {
+	for _, role := range []string{models.RoleSuperAdmin, models.RoleAdmin, models.RoleRH} {
+		if got := pointScopeSQL([]string{role}, "p.user_id", 4); got != "" {
+			t.Errorf("%s: pointScopeSQL = %q, want no restriction", role, got)
+		}
+	}
+
+	got := pointScopeSQL([]string{"user"}, "p.user_id", 4)
+	for _, want := range []string{"p.user_id = $4", "lider_id = $4", "parent_id"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("pointScopeSQL = %q, missing %q", got, want)
+		}
+	}
+	if strings.Contains(got, "$5") {
+		t.Errorf("pointScopeSQL = %q uses more than one argument", got)
+	}
+}
+
+// scopeFixture é uma empresa descartável com:
+//
+//	S1 (liderado por leader) > S1a      S2
+//	leader, emp1 em S1a, emp2 em S2, admin e rh em S2
+//
+// e uma batida aberta de cada funcionário.
+type scopeFixture struct {
+	database *db.Database
+	tenant   string
+	users    map[string]string // nome do papel no teste -> user_id
+	points   map[string]int    // nome do papel no teste -> id da batida
+}
+
+// newScopeFixture precisa de um banco migrado e descartável em TEST_DB_*
+// (mesmas variáveis de DB_*).
+func newScopeFixture(t *testing.T) *scopeFixture {
+	t.Helper()
+	if os.Getenv("TEST_DB_NAME") == "" {
+		t.Skip("TEST_DB_NAME not set")
+	}
+	for _, v := range []string{"USER", "PASS", "NAME", "HOST", "PORT"} {
+		t.Setenv("DB_"+v, os.Getenv("TEST_DB_"+v))
+	}
+
+	database, err := db.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+
+	f := &scopeFixture{
+		database: database,
+		tenant:   "scope-" + uuid.NewString()[:8],
+		users:    map[string]string{},
+		points:   map[string]int{},
+	}
+
+	ctx := db.AllTenants(context.Background())
+	pool := database.Pool()
+	exec := func(sql string, args ...any) {
+		t.Helper()
+		if _, err := pool.Exec(ctx, sql, args...); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	exec(`INSERT INTO tenants (tenant_id, slug, name) VALUES ($1, $1, $1)`, f.tenant)
+	t.Cleanup(func() {
+		for _, table := range []string{"points", "users", "setores"} {
+			pool.Exec(ctx, `DELETE FROM `+table+` WHERE tenant_id = $1`, f.tenant)
+		}
+		pool.Exec(ctx, `DELETE FROM tenants WHERE tenant_id = $1`, f.tenant)
+	})
+
+	for _, name := range []string{"leader", "emp1", "emp2", "admin", "rh"} {
+		f.users[name] = uuid.NewString()
+	}
+	s1, s1a, s2 := uuid.NewString(), uuid.NewString(), uuid.NewString()
+
+	exec(`INSERT INTO setores (setor_id, nome, lider_id, parent_id, tenant_id) VALUES ($1, 'S1', $2, NULL, $3)`, s1, f.users["leader"], f.tenant)
+	exec(`INSERT INTO setores (setor_id, nome, parent_id, tenant_id) VALUES ($1, 'S1a', $2, $3)`, s1a, s1, f.tenant)
+	exec(`INSERT INTO setores (setor_id, nome, tenant_id) VALUES ($1, 'S2', $2)`, s2, f.tenant)
+
+	users := []struct{ name, role, setor string }{
+		{"leader", "user", s1},
+		{"emp1", "user", s1a},
+		{"emp2", "user", s2},
+		{"admin", models.RoleAdmin, s2},
+		{"rh", models.RoleRH, s2},
+	}
+	for _, u := range users {
+		exec(`
+			INSERT INTO users (name, senha, email, username, user_id, status, role, setor_id, tenant_id)
+			VALUES ($1, 'x', $1 || '@example.com', $1, $2, 'active', $3, $4, $5)
+		`, u.name, f.users[u.name], u.role, u.setor, f.tenant)
+
+		var id int
+		err := pool.QueryRow(ctx, `
+			INSERT INTO points (user_id, clock_in, status, tenant_id)
+			VALUES ($1, now() - interval '1 hour', 'open', $2)
+			RETURNING id
+		`, f.users[u.name], f.tenant).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.points[u.name] = id
+	}
+	return f
+}
+
+// serve chama o handler como o usuário name, com as claims que o AuthJWT
+// colocaria no contexto.
+func (f *scopeFixture) serve(h http.Handler, name, role, target string, pathValues ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	claims := &mid.Claims{UserID: f.users[name], TenantID: f.tenant, Role: []string{role}}
+	r = r.WithContext(mid.WithClaims(r.Context(), claims))
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		r.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// scopeCases: quem cada chamador enxerga
+var scopeCases = []struct {
+	caller, role string
+	sees         []string
+}{
+	{"admin", models.RoleAdmin, []string{"admin", "emp1", "emp2", "leader", "rh"}},
+	{"rh", models.RoleRH, []string{"admin", "emp1", "emp2", "leader", "rh"}},
+	{"leader", "user", []string{"emp1", "leader"}},
+	{"emp2", "user", []string{"emp2"}},
+}
+
+// names traduz user_ids de volta para os nomes do fixture, ordenados.
+func (f *scopeFixture) names(userIDs []string) []string {
+	out := []string{}
+	for _, id := range userIDs {
+		for name, uid := range f.users {
+			if uid == id {
+				out = append(out, name)
+			}
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+func TestListPointsScope(t *testing.T) {
+	f := newScopeFixture(t)
+
+	for _, tc := range scopeCases {
+		t.Run(tc.caller, func(t *testing.T) {
+			w := f.serve(ListPoints(f.database), tc.caller, tc.role, "/api/points?limit=100")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var res page[models.Point]
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, p := range res.Items {
+				ids = append(ids, p.User_ID)
+			}
+			if got := f.names(ids); !slices.Equal(got, tc.sees) {
+				t.Errorf("sees %v, want %v", got, tc.sees)
+			}
+		})
+	}
+}
+
+func TestGetPointScope(t *testing.T) {
+	f := newScopeFixture(t)
+
+	for _, tc := range scopeCases {
+		for name, id := range f.points {
+			t.Run(tc.caller+"/"+name, func(t *testing.T) {
+				w := f.serve(GetPoint(f.database), tc.caller, tc.role, fmt.Sprintf("/api/points/%d", id), "id", fmt.Sprint(id))
+				want := http.StatusNotFound
+				if slices.Contains(tc.sees, name) {
+					want = http.StatusOK
+				}
+				if w.Code != want {
+					t.Errorf("status = %d, want %d", w.Code, want)
+				}
+			})
+		}
+	}
+}
+
+func TestReportPointsScope(t *testing.T) {
+	f := newScopeFixture(t)
+
+	for _, tc := range scopeCases {
+		t.Run(tc.caller, func(t *testing.T) {
+			w := f.serve(ReportPoints(f.database), tc.caller, tc.role, "/api/reports/points?limit=200")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var res struct {
+				Items []struct {
+					UserID string `json:"user_id"`
+				} `json:"items"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, it := range res.Items {
+				ids = append(ids, it.UserID)
+			}
+			if got := f.names(ids); !slices.Equal(got, tc.sees) {
+				t.Errorf("sees %v, want %v", got, tc.sees)
+			}
+		})
+	}
+}
+
+func TestReportFrequencyScope(t *testing.T) {
+	f := newScopeFixture(t)
+
+	day := time.Now().UTC()
+	target := fmt.Sprintf("/api/reports/frequency?group_by=user&from=%s&to=%s",
+		day.AddDate(0, 0, -1).Format("2006-01-02"), day.AddDate(0, 0, 1).Format("2006-01-02"))
+
+	for _, tc := range scopeCases {
+		t.Run(tc.caller, func(t *testing.T) {
+			w := f.serve(ReportFrequency(f.database), tc.caller, tc.role, target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var res struct {
+				Items []struct {
+					Key string `json:"key"`
+				} `json:"items"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, it := range res.Items {
+				ids = append(ids, it.Key)
+			}
+			if got := f.names(ids); !slices.Equal(got, tc.sees) {
+				t.Errorf("sees %v, want %v", got, tc.sees)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/presence"
+)
+
+type presenceQR struct {
+	SetorID      string    `json:"setor_id"`
+	Code         string    `json:"code"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RotatesEvery int       `json:"rotates_every"` // segundos
+}
+
+func newPresenceQR(signer *presence.Signer, setorID string) presenceQR {
+	code, expires := signer.Code(setorID, time.Now())
+	return presenceQR{
+		SetorID:      setorID,
+		Code:         code,
+		ExpiresAt:    expires,
+		RotatesEvery: int(signer.Period().Seconds()),
+	}
+}
+
+// GET /api/setor/{id}/presence-qr — para a tela do local (admin/RH ou líder)
+func SetorPresenceQR(database *db.Database, signer *presence.Signer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setorID := r.PathValue("id")
+		callerID, _ := mid.UserIDFromContext(r.Context())
+		roles, _ := mid.RoleFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		// líder do setor ou de um setor acima dele
+		var leads bool
+		err := database.Pool().QueryRow(ctx, `
+			SELECT setor_id IN (`+ledSetoresSQL(2)+`) FROM setores WHERE setor_id = $1
+		`, setorID, callerID).Scan(&leads)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "setor not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching setor:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if !isPrivileged(roles) && !leads {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, newPresenceQR(signer, setorID))
+	}
+}
+
+// GET /api/kiosk/presence-qr?setor_id= — quiosque fazendo as vezes de tela.
+// Sem setor_id, vale o primeiro setor do quiosque.
+func KioskPresenceQR(signer *presence.Signer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, ok := kioskFromContext(r.Context())
+		if !ok || len(k.Setores) == 0 {
+			http.Error(w, "kiosk has no setores", http.StatusForbidden)
+			return
+		}
+
+		setorID := r.URL.Query().Get("setor_id")
+		if setorID == "" {
+			setorID = k.Setores[0]
+		}
+		if !slices.Contains(k.Setores, setorID) {
+			http.Error(w, "setor not assigned to this kiosk", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, newPresenceQR(signer, setorID))
+	}
+}
+
+// presenceUse é o código do QR aceito para a batida: o uso é gravado na
+// transação da batida (recordPresenceUse).
+type presenceUse struct {
+	SetorID string
+	Window  int64
+}
+
+// checkPresenceCode barra a batida pelo app quando o setor do funcionário
+// exige o QR do local e o código não é válido, está vencido ou já foi usado
+// por ele. Escreve a resposta de erro e devolve false nesses casos; o uso
+// devolvido (nil se o setor não exige o QR) vai para punchInput.Presence.
+func checkPresenceCode(w http.ResponseWriter, ctx context.Context, database *db.Database, signer *presence.Signer, userID, code string) (*presenceUse, bool) {
+	var (
+		setorID  *string
+		required bool
+	)
+	err := database.Pool().QueryRow(ctx, `
+		SELECT u.setor_id, COALESCE(s.require_presence_qr, false)
+		FROM users u
+		LEFT JOIN setores s ON s.setor_id = u.setor_id
+		WHERE u.user_id = $1 AND u.deleted_at IS NULL
+	`, userID).Scan(&setorID, &required)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		log.Println("DB error checking presence requirement:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !required || setorID == nil {
+		return nil, true
+	}
+
+	if code == "" {
+		http.Error(w, "presence_code is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	window, err := signer.Verify(code, *setorID, time.Now())
+	if errors.Is(err, presence.ErrExpired) {
+		http.Error(w, "expired presence code", http.StatusForbidden)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, "invalid presence code", http.StatusForbidden)
+		return nil, false
+	}
+
+	// recusa logo o código repetido, antes de salvar a foto; quem garante o uso
+	// único é o INSERT dentro da transação da batida
+	var used bool
+	err = database.Pool().QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM presence_qr_uses
+			WHERE setor_id = $1 AND window_n = $2 AND user_id = $3
+		)
+	`, *setorID, window, userID).Scan(&used)
+	if err != nil {
+		log.Println("DB error checking presence code use:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if used {
+		http.Error(w, "presence code already used", http.StatusConflict)
+		return nil, false
+	}
+
+	return &presenceUse{SetorID: *setorID, Window: window}, true
+}
+
+// recordPresenceUse grava o uso do código na transação da batida, que só é
+// confirmada junto com ele. Responde 409 se o código já foi usado.
+func recordPresenceUse(w http.ResponseWriter, ctx context.Context, tx pgx.Tx, userID string, use *presenceUse) bool {
+	if use == nil {
+		return true
+	}
+
+	cmd, err := tx.Exec(ctx, `
+		INSERT INTO presence_qr_uses (setor_id, window_n, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, use.SetorID, use.Window, userID)
+	if err != nil {
+		log.Println("DB error recording presence code use:", err)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return false
+	}
+	if cmd.RowsAffected() == 0 {
+		http.Error(w, "presence code already used", http.StatusConflict)
+		return false
+	}
+	return true
+}
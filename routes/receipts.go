@@ -0,0 +1,292 @@
+package routes
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/receipt"
+)
+
+const receiptColumns = `
+	code, nsr, point_id, kind, user_id, employee_name,
+	company_name, company_cnpj, company_address,
+	punched_at, timezone, hash, signature
+`
+
+func scanReceipt(row pgx.Row) (*receipt.Receipt, error) {
+	var rc receipt.Receipt
+	err := row.Scan(
+		&rc.Code, &rc.NSR, &rc.PointID, &rc.Kind, &rc.UserID, &rc.EmployeeName,
+		&rc.CompanyName, &rc.CompanyCNPJ, &rc.CompanyAddress,
+		&rc.PunchedAt, &rc.Timezone, &rc.Hash, &rc.Signature,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rc.PunchedAt = rc.PunchedAt.In(loadLocation(rc.Timezone))
+	return &rc, nil
+}
+
+// receiptVerifyURL é o link público impresso no comprovante.
+func receiptVerifyURL(code string) string {
+	return strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/") + "/api/receipts/" + code + "/verify"
+}
+
+// issueReceipt gera, assina e grava o comprovante de uma marcação, dentro da
+// transação da própria marcação: sem comprovante a batida não é gravada. O
+// NSR é sequencial por empresa (tenants.last_nsr) e a linha da empresa fica
+// travada até o commit. O empregador é a empresa do funcionário; empresas sem
+// CNPJ cadastrado usam COMPANY_* do ambiente.
+func issueReceipt(ctx context.Context, tx pgx.Tx, key ed25519.PrivateKey, pointID int, kind, userID string, punchedAt time.Time) (*receipt.Receipt, error) {
+	company := receipt.CompanyFromEnv()
+
+	rc := &receipt.Receipt{
+		Code:      uuid.NewString(),
+		PointID:   pointID,
+		Kind:      kind,
+		UserID:    userID,
+		PunchedAt: punchedAt.Truncate(time.Second),
+		Timezone:  punchedAt.Location().String(),
+	}
+
+	var tenantID string
+	err := tx.QueryRow(ctx, `
+		UPDATE tenants t SET last_nsr = t.last_nsr + 1
+		FROM users u
+		WHERE u.user_id = $1 AND t.tenant_id = u.tenant_id
+		RETURNING t.tenant_id, t.last_nsr, u.name,
+			CASE WHEN t.cnpj IS NULL THEN $2 ELSE t.name END,
+			COALESCE(t.cnpj, $3),
+			CASE WHEN t.cnpj IS NULL THEN $4 ELSE COALESCE(t.address, '') END
+	`, userID, company.Name, company.CNPJ, company.Address).Scan(
+		&tenantID, &rc.NSR, &rc.EmployeeName, &rc.CompanyName, &rc.CompanyCNPJ, &rc.CompanyAddress,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rc.Sign(key)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO point_receipts (tenant_id, `+receiptColumns+`)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)
+	`, tenantID, rc.Code, rc.NSR, rc.PointID, rc.Kind, rc.UserID, rc.EmployeeName,
+		rc.CompanyName, rc.CompanyCNPJ, rc.CompanyAddress,
+		rc.PunchedAt, rc.Timezone, rc.Hash, rc.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return rc, nil
+}
+
+// resumo devolvido junto com a batida
+func receiptSummary(rc *receipt.Receipt) map[string]any {
+	if rc == nil {
+		return nil
+	}
+	return map[string]any{
+		"code":       rc.Code,
+		"nsr":        rc.NSR,
+		"hash":       rc.Hash,
+		"url":        "/api/receipts/" + rc.Code,
+		"verify_url": receiptVerifyURL(rc.Code),
+	}
+}
+
+// GET /api/points/{id}/receipts
+func ListPointReceipts(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var ownerID string
+		err = database.Pool().QueryRow(ctx, `SELECT user_id FROM points WHERE id = $1`, id).Scan(&ownerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "point not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if !callerCanViewUser(w, r, ctx, database, ownerID) {
+			return
+		}
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+receiptColumns+` FROM point_receipts WHERE point_id = $1 ORDER BY nsr
+		`, id)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		out := []*receipt.Receipt{}
+		for rows.Next() {
+			rc, err := scanReceipt(rows)
+			if err != nil {
+				log.Println("DB error reading receipts:", err)
+				http.Error(w, "error reading rows", http.StatusInternalServerError)
+				return
+			}
+			out = append(out, rc)
+		}
+
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// GET /api/receipts/{code}?format=json|pdf
+func GetReceipt(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := r.PathValue("code")
+		if uuid.Validate(code) != nil {
+			http.Error(w, "invalid code", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rc, err := scanReceipt(database.Pool().QueryRow(ctx, `
+			SELECT `+receiptColumns+` FROM point_receipts WHERE code = $1
+		`, code))
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "receipt not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		if !callerCanViewUser(w, r, ctx, database, rc.UserID) {
+			return
+		}
+
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="comprovante-%09d.json"`, rc.NSR))
+			writeJSON(w, http.StatusOK, rc)
+		case "pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="comprovante-%09d.pdf"`, rc.NSR))
+			w.Write(receipt.PDF(rc, receiptVerifyURL(rc.Code)))
+		default:
+			http.Error(w, "invalid format (json|pdf)", http.StatusBadRequest)
+		}
+	}
+}
+
+// GET /api/receipts/{code}/verify (público)
+func VerifyReceipt(database *db.Database, pub ed25519.PublicKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := r.PathValue("code")
+		if uuid.Validate(code) != nil {
+			http.Error(w, "invalid code", http.StatusBadRequest)
+			return
+		}
+
+		// público: o código é único entre todas as empresas
+		ctx, cancel := context.WithTimeout(db.AllTenants(r.Context()), 5*time.Second)
+		defer cancel()
+
+		rc, err := scanReceipt(database.Pool().QueryRow(ctx, `
+			SELECT `+receiptColumns+` FROM point_receipts WHERE code = $1
+		`, code))
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"valid": false, "error": "receipt not found"})
+			return
+		} else if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		// público: só o necessário para conferir, sem o nome completo
+		writeJSON(w, http.StatusOK, map[string]any{
+			"valid":         rc.Verify(pub),
+			"code":          rc.Code,
+			"nsr":           rc.NSR,
+			"kind":          rc.Kind,
+			"punched_at":    rc.PunchedAt,
+			"company_name":  rc.CompanyName,
+			"company_cnpj":  rc.CompanyCNPJ,
+			"employee_name": maskName(rc.EmployeeName),
+			"hash":          rc.Hash,
+		})
+	}
+}
+
+// POST /api/receipts/verify (público) — confere um comprovante JSON baixado
+func VerifyReceiptDocument(database *db.Database, pub ed25519.PublicKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rc receipt.Receipt
+		if err := json.NewDecoder(r.Body).Decode(&rc); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		ctx, cancel := context.WithTimeout(db.AllTenants(r.Context()), 5*time.Second)
+		defer cancel()
+
+		// além da assinatura, o comprovante precisa existir com o mesmo hash
+		var stored string
+		err := database.Pool().QueryRow(ctx, `SELECT hash FROM point_receipts WHERE code = $1`, rc.Code).Scan(&stored)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"valid":      rc.Verify(pub) && stored == rc.Hash,
+			"signature":  rc.Verify(pub),
+			"registered": stored != "" && stored == rc.Hash,
+		})
+	}
+}
+
+// GET /api/receipts/public-key — chave para verificação offline
+func ReceiptPublicKey(pub ed25519.PublicKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"algorithm":  "Ed25519",
+			"public_key": base64.StdEncoding.EncodeToString(pub),
+			"signed":     "hex(sha256(payload)) do comprovante",
+		})
+	}
+}
+
+// maskName: "Maria Silva Souza" -> "Maria S. S."
+func maskName(name string) string {
+	parts := strings.Fields(name)
+	if len(parts) == 0 {
+		return ""
+	}
+	out := []string{parts[0]}
+	for _, p := range parts[1:] {
+		out = append(out, string([]rune(p)[0])+".")
+	}
+	return strings.Join(out, " ")
+}
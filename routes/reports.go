@@ -3,6 +3,7 @@ package routes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,10 +11,16 @@ import (
 	"time"
 
 	"github.com/Rafhael-Viana/m/db"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 // helpers
+// maxZoneOffset é o maior desvio de um fuso em relação a UTC (UTC-12 a
+// UTC+14). Um dia local cai sempre dentro de [dia UTC - 14h, dia UTC + 1 dia +
+// 14h], o que deixa filtrar clock_in pelo índice antes de calcular o dia local.
+const maxZoneOffset = 14 * time.Hour
+
 func parseDateOnly(v string) (time.Time, error) {
 	// formato: YYYY-MM-DD
 	return time.Parse("2006-01-02", v)
@@ -73,18 +80,36 @@ func ReportPoints(database *db.Database) http.HandlerFunc {
 			}
 		}
 
-		where := []string{"1=1"}
-		args := []any{}
-		argN := 1
+		where := []string{"p.deleted_at IS NULL"}
+		args := []any{defaultTimezone()}
+		argN := 2
+
+		// fuso de cada batida: o do usuário, senão o do setor, senão o padrão ($1)
+		tzExpr := effectiveTimezoneSQL(1)
+
+		// só as batidas que o chamador enxerga
+		if cond, sargs := callerPointScope(r, "p.user_id", argN); cond != "" {
+			where = append(where, cond)
+			args = append(args, sargs...)
+			argN += len(sargs)
+		}
 
-		// OBS: aqui uso clock_in como referência de período
+		// OBS: aqui uso clock_in como referência de período, no horário local da batida
+		// o intervalo UTC alargado usa o índice de clock_in; o filtro pelo dia
+		// local vem depois
 		if from != nil {
 			where = append(where, fmt.Sprintf("p.clock_in >= $%d", argN))
+			args = append(args, from.Add(-maxZoneOffset))
+			argN++
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) >= $%d::timestamp", tzExpr, argN))
 			args = append(args, *from)
 			argN++
 		}
 		if to != nil {
 			where = append(where, fmt.Sprintf("p.clock_in < $%d", argN))
+			args = append(args, to.Add(maxZoneOffset))
+			argN++
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) < $%d::timestamp", tzExpr, argN))
 			args = append(args, *to)
 			argN++
 		}
@@ -109,11 +134,9 @@ func ReportPoints(database *db.Database) http.HandlerFunc {
 			argN++
 		}
 
-		// se você tiver users/departments, dá pra filtrar por dept aqui
-		joinDept := ""
+		// setor em vigor no dia da batida, não o atual
 		if deptID != "" {
-			joinDept = "JOIN users u ON u.id = p.user_id"
-			where = append(where, fmt.Sprintf("u.setor_id = $%d", argN))
+			where = append(where, setorFilterSQL(q, assignedSetorSQL, argN))
 			args = append(args, deptID)
 			argN++
 		}
@@ -130,15 +153,22 @@ func ReportPoints(database *db.Database) http.HandlerFunc {
 				p.status,
 				p.location_in, p.location_out,
 				p.photo_in, p.photo_out,
-				p.created_at, p.updated_at
+				p.photo_in_thumb, p.photo_out_thumb,
+				p.created_at, p.updated_at,
+				%s,
+				%s, %s
 			FROM points p
+			LEFT JOIN users u ON u.user_id = p.user_id
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
 			%s
 			WHERE %s
 			ORDER BY p.clock_in DESC NULLS LAST, p.created_at DESC
 			LIMIT $%d OFFSET $%d
-		`, joinDept, strings.Join(where, " AND "), limitPos, offsetPos)
+		`, tzExpr, assignedSetorSQL, assignedCargoSQL,
+			assignmentJoinSQL(fmt.Sprintf("(p.clock_in AT TIME ZONE %s)::date", tzExpr)),
+			strings.Join(where, " AND "), limitPos, offsetPos)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
 		defer cancel()
 
 		rows, err := database.Pool().Query(ctx, query, args...)
@@ -158,8 +188,13 @@ func ReportPoints(database *db.Database) http.HandlerFunc {
 			LocationOut *string    `json:"location_out,omitempty"`
 			PhotoIn     *string    `json:"photo_in"`
 			PhotoOut    *string    `json:"photo_out,omitempty"`
+			ThumbIn     *string    `json:"thumb_in"`
+			ThumbOut    *string    `json:"thumb_out,omitempty"`
 			CreatedAt   time.Time  `json:"created_at"`
 			UpdatedAt   time.Time  `json:"updated_at"`
+			Timezone    string     `json:"timezone"`
+			SetorID     *string    `json:"setor_id"` // setor e cargo em vigor no dia
+			Cargo       *string    `json:"cargo"`
 		}
 
 		out := []PointRow{}
@@ -173,7 +208,10 @@ func ReportPoints(database *db.Database) http.HandlerFunc {
 				&p.Status,
 				&locIn, &locOut,
 				&phIn, &phOut,
+				&p.ThumbIn, &p.ThumbOut,
 				&p.CreatedAt, &p.UpdatedAt,
+				&p.Timezone,
+				&p.SetorID, &p.Cargo,
 			); err != nil {
 				http.Error(w, "error reading rows", http.StatusInternalServerError)
 				fmt.Println(err)
@@ -193,6 +231,10 @@ func ReportPoints(database *db.Database) http.HandlerFunc {
 				p.PhotoOut = &phOut.String
 			}
 
+			loc := loadLocation(p.Timezone)
+			p.ClockIn = inZone(p.ClockIn, loc)
+			p.ClockOut = inZone(p.ClockOut, loc)
+
 			out = append(out, p)
 		}
 
@@ -204,7 +246,7 @@ func ReportPoints(database *db.Database) http.HandlerFunc {
 	}
 }
 
-// GET /reports/frequency?group_by=user|department|day
+// GET /reports/frequency?group_by=user|department|cargo|day
 func ReportFrequency(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
@@ -213,8 +255,8 @@ func ReportFrequency(database *db.Database) http.HandlerFunc {
 		if groupBy == "" {
 			groupBy = "user"
 		}
-		if groupBy != "user" && groupBy != "department" && groupBy != "day" {
-			http.Error(w, "invalid group_by (user|department|day)", http.StatusBadRequest)
+		if groupBy != "user" && groupBy != "department" && groupBy != "cargo" && groupBy != "day" {
+			http.Error(w, "invalid group_by (user|department|cargo|day)", http.StatusBadRequest)
 			return
 		}
 
@@ -241,61 +283,146 @@ func ReportFrequency(database *db.Database) http.HandlerFunc {
 		// Métricas úteis:
 		// - shifts_total: total de registros no período
 		// - shifts_closed: quantos estão status='close'
-		// - days_worked: quantos dias distintos (base clock_in::date)
+		// - days_worked: quantos dias distintos (base no dia local do clock_in)
 		// - hours_worked: soma de (clock_out - clock_in) somente quando fechado
 		//
 		// Ajuste se quiser contar apenas closed em tudo.
-		var query string
-		args := []any{from, to}
+		//
+		// O dia de cada batida é calculado no fuso do usuário (ou do setor dele),
+		// tanto para o filtro from/to quanto para o agrupamento por dia. O setor
+		// e o cargo do agrupamento são os que valiam naquele dia
+		// (user_assignments); o cargo sai do catálogo e, nos períodos de antes
+		// dele, do texto gravado. Antes do dia local, clock_in é restrito ao
+		// intervalo UTC alargado por maxZoneOffset ($4/$5), que usa o índice.
+		args := []any{from, to, defaultTimezone(), from.Add(-maxZoneOffset), to.Add(maxZoneOffset)}
+
+		// só as batidas que o chamador enxerga ($6)
+		scope := ""
+		if cond, sargs := callerPointScope(r, "p.user_id", len(args)+1); cond != "" {
+			scope = "AND " + cond
+			args = append(args, sargs...)
+		}
+
+		localDay := `(p.clock_in AT TIME ZONE ` + effectiveTimezoneSQL(3) + `)::date`
+		base := `
+			WITH pl AS (
+				SELECT
+					p.*,
+					hs.nome AS setor_nome,
+					COALESCE(hc.nome, ` + assignedCargoSQL + `) AS cargo_nome,
+					` + localDay + ` AS local_day,
+					ct.daily_minutes, ct.max_daily_minutes, ct.overtime_allowed, ct.hour_bank
+				FROM points p
+				LEFT JOIN users u ON u.user_id = p.user_id
+				LEFT JOIN setores s ON s.setor_id = u.setor_id
+				` + assignmentJoinSQL(localDay) + `
+				LEFT JOIN setores hs ON hs.setor_id = ` + assignedSetorSQL + `
+				LEFT JOIN cargos hc ON hc.cargo_id = ` + assignedCargoIDSQL + `
+				LEFT JOIN contract_types ct ON ct.code = ` + assignedContractSQL + `
+				WHERE p.deleted_at IS NULL
+				  AND p.clock_in >= $4 AND p.clock_in < $5
+				  ` + scope + `
+			)
+		`
+		var key, query, order string
 
 		switch groupBy {
 		case "user":
+			key = "p.user_id"
 			query = `
 				SELECT
 					p.user_id AS key,
 					COUNT(*) AS shifts_total,
 					COUNT(*) FILTER (WHERE p.status = 'close') AS shifts_closed,
-					COUNT(DISTINCT (p.clock_in::date)) AS days_worked,
+					COUNT(DISTINCT p.local_day) AS days_worked,
 					COALESCE(SUM(EXTRACT(EPOCH FROM (p.clock_out - p.clock_in))) FILTER (WHERE p.status='close'), 0) AS seconds_worked
-				FROM points p
-				WHERE p.clock_in >= $1 AND p.clock_in < $2
+				FROM pl p
+				WHERE p.local_day >= $1::date AND p.local_day < $2::date
 				GROUP BY p.user_id
-				ORDER BY days_worked DESC, shifts_closed DESC
 			`
+			order = "g.days_worked DESC, g.shifts_closed DESC"
 
 		case "department":
-			// precisa de users.department_id e departments.id/name (ajuste nomes)
+			key = "COALESCE(p.setor_nome, 'Sem setor')"
 			query = `
 				SELECT
-					COALESCE(s.nome, 'Sem setor') AS key,
+					COALESCE(p.setor_nome, 'Sem setor') AS key,
 					COUNT(*) AS shifts_total,
 					COUNT(*) FILTER (WHERE p.status = 'close') AS shifts_closed,
-					COUNT(DISTINCT (p.clock_in::date)) AS days_worked,
+					COUNT(DISTINCT p.local_day) AS days_worked,
 					COALESCE(SUM(EXTRACT(EPOCH FROM (p.clock_out - p.clock_in))) FILTER (WHERE p.status='close'), 0) AS seconds_worked
-				FROM points p
-				LEFT JOIN users u ON u.user_id = p.user_id
-				LEFT JOIN setores s ON s.setor_id = u.setor_id
-				WHERE p.clock_in >= $1 AND p.clock_in < $2
-				GROUP BY COALESCE(s.nome, 'Sem setor')
-				ORDER BY days_worked DESC, shifts_closed DESC
+				FROM pl p
+				WHERE p.local_day >= $1::date AND p.local_day < $2::date
+				GROUP BY COALESCE(p.setor_nome, 'Sem setor')
 			`
+			order = "g.days_worked DESC, g.shifts_closed DESC"
+
+		case "cargo":
+			key = "COALESCE(p.cargo_nome, 'Sem cargo')"
+			query = `
+				SELECT
+					COALESCE(p.cargo_nome, 'Sem cargo') AS key,
+					COUNT(*) AS shifts_total,
+					COUNT(*) FILTER (WHERE p.status = 'close') AS shifts_closed,
+					COUNT(DISTINCT p.local_day) AS days_worked,
+					COALESCE(SUM(EXTRACT(EPOCH FROM (p.clock_out - p.clock_in))) FILTER (WHERE p.status='close'), 0) AS seconds_worked
+				FROM pl p
+				WHERE p.local_day >= $1::date AND p.local_day < $2::date
+				GROUP BY COALESCE(p.cargo_nome, 'Sem cargo')
+			`
+			order = "g.days_worked DESC, g.shifts_closed DESC"
 
 		case "day":
+			key = "p.local_day::text"
 			query = `
 				SELECT
-					(p.clock_in::date)::text AS key,
+					p.local_day::text AS key,
 					COUNT(*) AS shifts_total,
 					COUNT(*) FILTER (WHERE p.status = 'close') AS shifts_closed,
 					COUNT(DISTINCT p.user_id) AS users_present,
 					COALESCE(SUM(EXTRACT(EPOCH FROM (p.clock_out - p.clock_in))) FILTER (WHERE p.status='close'), 0) AS seconds_worked
-				FROM points p
-				WHERE p.clock_in >= $1 AND p.clock_in < $2
-				GROUP BY (p.clock_in::date)
-				ORDER BY (p.clock_in::date) ASC
+				FROM pl p
+				WHERE p.local_day >= $1::date AND p.local_day < $2::date
+				GROUP BY p.local_day
 			`
+			order = "g.key ASC"
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		// Regras do contrato em vigor em cada dia, por funcionário: o que passa
+		// da jornada vira extra (ou banco de horas, se o contrato usa banco) e
+		// o dia que passa do máximo do contrato conta em over_limit_days.
+		query = base + `,
+			g AS (` + query + `),
+			ud AS (
+				SELECT
+					` + key + ` AS key,
+					COALESCE(SUM(EXTRACT(EPOCH FROM (p.clock_out - p.clock_in))) FILTER (WHERE p.status='close'), 0) AS seconds,
+					MIN(p.daily_minutes) * 60 AS daily,
+					MIN(CASE WHEN p.overtime_allowed THEN p.max_daily_minutes ELSE p.daily_minutes END) * 60 AS max_allowed,
+					bool_and(p.overtime_allowed) AS overtime_allowed,
+					bool_and(p.hour_bank) AS hour_bank
+				FROM pl p
+				WHERE p.local_day >= $1::date AND p.local_day < $2::date
+				GROUP BY ` + key + `, p.user_id, p.local_day
+			),
+			c AS (
+				SELECT
+					key,
+					SUM(GREATEST(seconds - daily, 0)) FILTER (WHERE overtime_allowed AND NOT hour_bank) AS overtime_seconds,
+					SUM(GREATEST(seconds - daily, 0)) FILTER (WHERE hour_bank) AS hour_bank_seconds,
+					COUNT(*) FILTER (WHERE seconds > max_allowed) AS over_limit_days
+				FROM ud
+				GROUP BY key
+			)
+			SELECT g.*,
+				COALESCE(c.overtime_seconds, 0),
+				COALESCE(c.hour_bank_seconds, 0),
+				COALESCE(c.over_limit_days, 0)
+			FROM g
+			LEFT JOIN c ON c.key = g.key
+			ORDER BY ` + order
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
 		rows, err := database.Pool().Query(ctx, query, args...)
@@ -313,29 +440,36 @@ func ReportFrequency(database *db.Database) http.HandlerFunc {
 			DaysWorked   int64   `json:"days_worked"`
 			UsersPresent *int64  `json:"users_present,omitempty"`
 			HoursWorked  float64 `json:"hours_worked"`
+			// regras do contrato de cada dia
+			OvertimeHours float64 `json:"overtime_hours"`
+			HourBankHours float64 `json:"hour_bank_hours"`
+			OverLimitDays int64   `json:"over_limit_days"`
 		}
 
 		out := []Row{}
 		for rows.Next() {
 			var (
-				key           string
-				shiftsTotal   int64
-				shiftsClosed  int64
-				daysWorked    int64
-				usersPresent  *int64
-				secondsWorked float64
+				key             string
+				shiftsTotal     int64
+				shiftsClosed    int64
+				daysWorked      int64
+				usersPresent    *int64
+				secondsWorked   float64
+				overtimeSeconds float64
+				hourBankSeconds float64
+				overLimitDays   int64
 			)
 
 			if groupBy == "day" {
 				var up int64
-				if err := rows.Scan(&key, &shiftsTotal, &shiftsClosed, &up, &secondsWorked); err != nil {
+				if err := rows.Scan(&key, &shiftsTotal, &shiftsClosed, &up, &secondsWorked, &overtimeSeconds, &hourBankSeconds, &overLimitDays); err != nil {
 					http.Error(w, "error reading rows", http.StatusInternalServerError)
 					fmt.Println(err)
 					return
 				}
 				usersPresent = &up
 			} else {
-				if err := rows.Scan(&key, &shiftsTotal, &shiftsClosed, &daysWorked, &secondsWorked); err != nil {
+				if err := rows.Scan(&key, &shiftsTotal, &shiftsClosed, &daysWorked, &secondsWorked, &overtimeSeconds, &hourBankSeconds, &overLimitDays); err != nil {
 					http.Error(w, "error reading rows", http.StatusInternalServerError)
 					fmt.Println(err)
 					return
@@ -350,6 +484,10 @@ func ReportFrequency(database *db.Database) http.HandlerFunc {
 				DaysWorked:   daysWorked,
 				UsersPresent: usersPresent,
 				HoursWorked:  hours,
+
+				OvertimeHours: overtimeSeconds / 3600.0,
+				HourBankHours: hourBankSeconds / 3600.0,
+				OverLimitDays: overLimitDays,
 			})
 		}
 
@@ -376,7 +514,7 @@ func ReportWork(database *db.Database) http.HandlerFunc {
 			total    string
 		)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
 		query := `
@@ -384,8 +522,9 @@ func ReportWork(database *db.Database) http.HandlerFunc {
 				SELECT COALESCE(SUM(EXTRACT(EPOCH FROM (p.clock_out - p.clock_in))), 0)::bigint AS total_segundos
 				FROM points p
 				WHERE p.user_id = $1
+					AND p.deleted_at IS NULL
 					AND p.clock_out IS NOT NULL
-					AND date(p.clock_in) = $2::date
+					AND (p.clock_in AT TIME ZONE $3)::date = $2::date
 			)
 			SELECT
 				$2::date AS dia,
@@ -400,7 +539,23 @@ func ReportWork(database *db.Database) http.HandlerFunc {
 			FROM t;
 			`
 
-		err := database.Pool().QueryRow(ctx, query, userId, dayStr).Scan(&dia, &totalSeg, &total)
+		// o próprio funcionário, admin/RH ou o líder do setor dele
+		if !callerCanViewUser(w, r, ctx, database, userId) {
+			return
+		}
+
+		// o "dia" é o dia local do funcionário
+		loc, err := userLocation(ctx, database, userId)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "error retrieving worked time", http.StatusInternalServerError)
+			fmt.Printf("Err: %s\n", err)
+			return
+		}
+
+		err = database.Pool().QueryRow(ctx, query, userId, dayStr, loc.String()).Scan(&dia, &totalSeg, &total)
 		if err != nil {
 			http.Error(w, "error retrieving worked time", http.StatusInternalServerError)
 			fmt.Printf("Err: %s\n", err)
@@ -411,7 +566,290 @@ func ReportWork(database *db.Database) http.HandlerFunc {
 			"day":           dia.Format("2006-01-02"),
 			"total_seconds": totalSeg,
 			"total":         total,
+			"timezone":      loc.String(),
 		})
 
 	}
 }
+
+// GET /reports/suspicious-photos?user_id=&from=&to=&reason=exact|similar
+// Lista batidas cuja foto repete (ou se parece muito com) uma foto anterior do mesmo usuário.
+func ReportSuspiciousPhotos(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		userID := strings.TrimSpace(q.Get("user_id"))
+		reason := strings.TrimSpace(q.Get("reason"))
+
+		limit := 50
+		offset := 0
+		if v := q.Get("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+				limit = n
+			}
+		}
+		if v := q.Get("offset"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				offset = n
+			}
+		}
+
+		where := []string{"p.deleted_at IS NULL"}
+		args := []any{defaultTimezone()}
+		argN := 2
+		tzExpr := effectiveTimezoneSQL(1)
+
+		// só as batidas que o chamador enxerga
+		if cond, sargs := callerPointScope(r, "p.user_id", argN); cond != "" {
+			where = append(where, cond)
+			args = append(args, sargs...)
+			argN += len(sargs)
+		}
+
+		if v := q.Get("from"); v != "" {
+			d, err := parseDateOnly(v)
+			if err != nil {
+				http.Error(w, "invalid from (use YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) >= $%d::timestamp", tzExpr, argN))
+			args = append(args, d)
+			argN++
+		}
+		if v := q.Get("to"); v != "" {
+			d, err := parseDateOnly(v)
+			if err != nil {
+				http.Error(w, "invalid to (use YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) < $%d::timestamp", tzExpr, argN))
+			args = append(args, d.AddDate(0, 0, 1))
+			argN++
+		}
+		if userID != "" {
+			where = append(where, fmt.Sprintf("p.user_id = $%d", argN))
+			args = append(args, userID)
+			argN++
+		}
+		if reason != "" {
+			if reason != "exact" && reason != "similar" {
+				http.Error(w, "invalid reason (exact|similar)", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("f.reason = $%d", argN))
+			args = append(args, reason)
+			argN++
+		}
+
+		args = append(args, limit, offset)
+
+		query := fmt.Sprintf(`
+			SELECT
+				f.point_id, p.user_id, u.name,
+				f.photo,
+				CASE WHEN f.photo = 'in' THEN p.photo_in ELSE p.photo_out END,
+				CASE WHEN f.photo = 'in' THEN p.clock_in ELSE p.clock_out END,
+				f.reason, f.distance,
+				f.matched_point_id, f.matched_photo,
+				CASE WHEN f.matched_photo = 'in' THEN mp.photo_in ELSE mp.photo_out END,
+				CASE WHEN f.matched_photo = 'in' THEN mp.clock_in ELSE mp.clock_out END,
+				%s
+			FROM point_photo_flags f
+			JOIN points p ON p.id = f.point_id
+			JOIN points mp ON mp.id = f.matched_point_id
+			LEFT JOIN users u ON u.user_id = p.user_id
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
+			WHERE %s
+			ORDER BY f.created_at DESC, f.id DESC
+			LIMIT $%d OFFSET $%d
+		`, tzExpr, strings.Join(where, " AND "), argN, argN+1)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, query, args...)
+		if err != nil {
+			http.Error(w, "error fetching suspicious photos", http.StatusInternalServerError)
+			fmt.Println(err)
+			return
+		}
+		defer rows.Close()
+
+		type Row struct {
+			PointID        int        `json:"point_id"`
+			UserID         string     `json:"user_id"`
+			UserName       *string    `json:"user_name"`
+			Photo          string     `json:"photo"`
+			PhotoURL       *string    `json:"photo_url"`
+			PunchedAt      *time.Time `json:"punched_at"`
+			Reason         string     `json:"reason"`
+			Distance       int        `json:"distance"`
+			MatchedPointID int        `json:"matched_point_id"`
+			MatchedPhoto   string     `json:"matched_photo"`
+			MatchedURL     *string    `json:"matched_photo_url"`
+			MatchedAt      *time.Time `json:"matched_punched_at"`
+			Timezone       string     `json:"timezone"`
+		}
+
+		out := []Row{}
+		for rows.Next() {
+			var row Row
+			if err := rows.Scan(
+				&row.PointID, &row.UserID, &row.UserName,
+				&row.Photo, &row.PhotoURL, &row.PunchedAt,
+				&row.Reason, &row.Distance,
+				&row.MatchedPointID, &row.MatchedPhoto, &row.MatchedURL, &row.MatchedAt,
+				&row.Timezone,
+			); err != nil {
+				http.Error(w, "error reading rows", http.StatusInternalServerError)
+				fmt.Println(err)
+				return
+			}
+
+			loc := loadLocation(row.Timezone)
+			row.PunchedAt = inZone(row.PunchedAt, loc)
+			row.MatchedAt = inZone(row.MatchedAt, loc)
+
+			out = append(out, row)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"limit":  limit,
+			"offset": offset,
+			"items":  out,
+		})
+	}
+}
+
+// GET /api/reports/network-flags?from=YYYY-MM-DD&to=YYYY-MM-DD&user_id=&setor_id=&include_subsetores=true
+//
+// Batidas aceitas fora da rede permitida do setor (política "flag").
+func ReportNetworkFlags(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		userID := strings.TrimSpace(q.Get("user_id"))
+		setorID := strings.TrimSpace(q.Get("setor_id"))
+
+		limit := 50
+		offset := 0
+		if v := q.Get("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+				limit = n
+			}
+		}
+		if v := q.Get("offset"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				offset = n
+			}
+		}
+
+		where := []string{"p.deleted_at IS NULL"}
+		args := []any{defaultTimezone()}
+		argN := 2
+		tzExpr := effectiveTimezoneSQL(1)
+
+		// só as batidas que o chamador enxerga
+		if cond, sargs := callerPointScope(r, "p.user_id", argN); cond != "" {
+			where = append(where, cond)
+			args = append(args, sargs...)
+			argN += len(sargs)
+		}
+
+		if v := q.Get("from"); v != "" {
+			d, err := parseDateOnly(v)
+			if err != nil {
+				http.Error(w, "invalid from (use YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) >= $%d::timestamp", tzExpr, argN))
+			args = append(args, d)
+			argN++
+		}
+		if v := q.Get("to"); v != "" {
+			d, err := parseDateOnly(v)
+			if err != nil {
+				http.Error(w, "invalid to (use YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			where = append(where, fmt.Sprintf("(p.clock_in AT TIME ZONE %s) < $%d::timestamp", tzExpr, argN))
+			args = append(args, d.AddDate(0, 0, 1))
+			argN++
+		}
+		if userID != "" {
+			where = append(where, fmt.Sprintf("p.user_id = $%d", argN))
+			args = append(args, userID)
+			argN++
+		}
+		if setorID != "" {
+			where = append(where, setorFilterSQL(q, "u.setor_id", argN))
+			args = append(args, setorID)
+			argN++
+		}
+
+		args = append(args, limit, offset)
+
+		query := fmt.Sprintf(`
+			SELECT
+				f.point_id, p.user_id, u.name, u.setor_id, s.nome,
+				f.punch,
+				CASE WHEN f.punch = 'in' THEN p.clock_in ELSE p.clock_out END,
+				f.ip, f.bssid,
+				%s
+			FROM point_network_flags f
+			JOIN points p ON p.id = f.point_id
+			LEFT JOIN users u ON u.user_id = p.user_id
+			LEFT JOIN setores s ON s.setor_id = u.setor_id
+			WHERE %s
+			ORDER BY f.created_at DESC, f.id DESC
+			LIMIT $%d OFFSET $%d
+		`, tzExpr, strings.Join(where, " AND "), argN, argN+1)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, query, args...)
+		if err != nil {
+			http.Error(w, "error fetching network flags", http.StatusInternalServerError)
+			fmt.Println(err)
+			return
+		}
+		defer rows.Close()
+
+		type Row struct {
+			PointID   int        `json:"point_id"`
+			UserID    string     `json:"user_id"`
+			UserName  *string    `json:"user_name"`
+			SetorID   *string    `json:"setor_id"`
+			Setor     *string    `json:"setor"`
+			Punch     string     `json:"punch"`
+			PunchedAt *time.Time `json:"punched_at"`
+			IP        *string    `json:"ip"`
+			BSSID     *string    `json:"bssid"`
+			Timezone  string     `json:"timezone"`
+		}
+
+		out := []Row{}
+		for rows.Next() {
+			var row Row
+			if err := rows.Scan(
+				&row.PointID, &row.UserID, &row.UserName, &row.SetorID, &row.Setor,
+				&row.Punch, &row.PunchedAt, &row.IP, &row.BSSID,
+				&row.Timezone,
+			); err != nil {
+				http.Error(w, "error reading rows", http.StatusInternalServerError)
+				fmt.Println(err)
+				return
+			}
+
+			row.PunchedAt = inZone(row.PunchedAt, loadLocation(row.Timezone))
+			out = append(out, row)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"limit":  limit,
+			"offset": offset,
+			"items":  out,
+		})
+	}
+}
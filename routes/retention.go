@@ -0,0 +1,217 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/retention"
+)
+
+// GET /api/retention/policies
+func ListRetentionPolicies(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		policies, err := retention.LoadPolicies(ctx, database)
+		if err != nil {
+			log.Println("DB error fetching retention policies:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, policies)
+	}
+}
+
+// PUT /api/retention/policies/{category}
+func UpdateRetentionPolicy(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		category := r.PathValue("category")
+		if !retention.ValidCategory(category) {
+			http.Error(w, "unknown category", http.StatusNotFound)
+			return
+		}
+
+		var input struct {
+			RetentionMonths int  `json:"retention_months"`
+			Enabled         bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		if input.RetentionMonths <= 0 {
+			http.Error(w, "retention_months must be greater than 0", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		p := retention.Policy{
+			Category:        category,
+			RetentionMonths: input.RetentionMonths,
+			Enabled:         input.Enabled,
+		}
+		if err := retention.SavePolicy(ctx, database, p); err != nil {
+			log.Println("DB error saving retention policy:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// POST /api/retention/run?dry_run=true
+func RunRetention(job *retention.Job) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		callerID, _ := mid.UserIDFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
+		defer cancel()
+
+		rep, err := job.Run(ctx, dryRun, "user:"+callerID)
+		if errors.Is(err, retention.ErrAlreadyRunning) {
+			http.Error(w, "retention job already running", http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Println("Retention job error:", err)
+			http.Error(w, "retention job failed", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, rep)
+	}
+}
+
+// GET /api/retention/runs
+func ListRetentionRuns(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT id, started_at, finished_at, dry_run, triggered_by, status, summary
+			FROM retention_runs
+			ORDER BY id DESC
+			LIMIT $1
+		`, limit)
+		if err != nil {
+			log.Println("DB error fetching retention runs:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		type Run struct {
+			ID          int64           `json:"id"`
+			StartedAt   time.Time       `json:"started_at"`
+			FinishedAt  *time.Time      `json:"finished_at"`
+			DryRun      bool            `json:"dry_run"`
+			TriggeredBy *string         `json:"triggered_by"`
+			Status      string          `json:"status"`
+			Summary     json.RawMessage `json:"summary"`
+		}
+
+		out := []Run{}
+		for rows.Next() {
+			var run Run
+			if err := rows.Scan(&run.ID, &run.StartedAt, &run.FinishedAt, &run.DryRun, &run.TriggeredBy, &run.Status, &run.Summary); err != nil {
+				log.Println("DB error reading retention runs:", err)
+				http.Error(w, "error reading rows", http.StatusInternalServerError)
+				return
+			}
+			out = append(out, run)
+		}
+
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// GET /api/retention/runs/{id} — resumo + itens apagados
+func GetRetentionRun(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		var (
+			status  string
+			summary json.RawMessage
+		)
+		err = database.Pool().QueryRow(ctx, `
+			SELECT status, summary FROM retention_runs WHERE id = $1
+		`, id).Scan(&status, &summary)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "run not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching retention run:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT category, point_id, object_key, ref, purged_at
+			FROM retention_run_items
+			WHERE run_id = $1
+			ORDER BY id
+		`, id)
+		if err != nil {
+			log.Println("DB error fetching retention items:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		type Item struct {
+			Category  string    `json:"category"`
+			PointID   *int      `json:"point_id,omitempty"`
+			ObjectKey *string   `json:"object_key,omitempty"`
+			Ref       *string   `json:"ref,omitempty"`
+			PurgedAt  time.Time `json:"purged_at"`
+		}
+
+		items := []Item{}
+		for rows.Next() {
+			var it Item
+			if err := rows.Scan(&it.Category, &it.PointID, &it.ObjectKey, &it.Ref, &it.PurgedAt); err != nil {
+				log.Println("DB error reading retention items:", err)
+				http.Error(w, "error reading rows", http.StatusInternalServerError)
+				return
+			}
+			items = append(items, it)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      id,
+			"status":  status,
+			"summary": summary,
+			"items":   items,
+		})
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/Rafhael-Viana/m/db"
 	"github.com/Rafhael-Viana/m/models"
+	"github.com/Rafhael-Viana/m/webhooks"
 	"github.com/google/uuid"
 )
 
@@ -17,26 +19,56 @@ import (
 func CreateSetor(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var s models.Setor
-
-		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		if !decodeBody(w, r, &s) {
 			return
 		}
-		defer r.Body.Close()
 
+		var errs fieldErrors
+		s.Nome = strings.TrimSpace(s.Nome)
 		if s.Nome == "" {
-			http.Error(w, "nome is required", http.StatusBadRequest)
-			return
+			errs.add("name", "is required")
+		}
+		if s.Quantidade < 0 {
+			errs.add("qtd_users", "cannot be negative")
+		}
+		if s.Timezone != nil && *s.Timezone != "" && !validTimezone(*s.Timezone) {
+			errs.add("timezone", "is not a valid IANA timezone")
+		}
+		if s.Network == "" {
+			s.Network = NetworkPolicyOff
+		}
+		if !validNetworkPolicy(s.Network) {
+			errs.add("network_policy", "must be off, flag or reject")
+		}
+		// setor novo não tem regras de IP (requireIPRule)
+		if s.Network == NetworkPolicyReject {
+			errs.add("network_policy", "reject requires at least one ip rule; create the setor with flag and add rules first")
+		}
+		if s.Devices == "" {
+			s.Devices = DevicePolicyOff
+		}
+		if !validDevicePolicy(s.Devices) {
+			errs.add("device_policy", "must be off, flag or reject")
 		}
 
 		s.Setor_ID = uuid.NewString()
+		s.Parent_ID = nullIfEmpty(strings.TrimSpace(emptyIfNull(s.Parent_ID)))
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
+		if err := checkSetorParent(ctx, database.Pool(), &errs, s.Setor_ID, s.Parent_ID); err != nil {
+			log.Println("DB error checking parent setor:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if errs.write(w) {
+			return
+		}
+
 		query := `
-			INSERT INTO setores (setor_id, nome, quantidade, lider, created_by, lider_id)
-			VALUES ($1,$2,$3,$4,$5, $6)
+			INSERT INTO setores (setor_id, nome, quantidade, lider, created_by, lider_id, timezone, require_presence_qr, network_policy, device_policy, parent_id)
+			VALUES ($1,$2,$3,$4,$5, $6, $7, $8, $9, $10, $11)
 		`
 
 		_, err := database.Pool().Exec(
@@ -48,26 +80,38 @@ func CreateSetor(database *db.Database) http.HandlerFunc {
 			s.Lider,      // $4 lider
 			s.CreatedBy,  // $5 created_by
 			s.Lider_ID,   // $6 lider_id
+			s.Timezone,   // $7 timezone
+			s.PresenceQR, // $8 require_presence_qr
+			s.Network,    // $9 network_policy
+			s.Devices,    // $10 device_policy
+			s.Parent_ID,  // $11 parent_id
 		)
 
+		if writeDBError(w, err) {
+			return
+		}
 		if err != nil {
+			log.Println("DB error creating setor:", err)
 			http.Error(w, "could not create setor", http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(s)
+
+		emitWebhook(ctx, database, webhooks.EventSetorCreated, s)
 	}
 }
 
 func ListSetores(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
 		rows, err := database.Pool().Query(ctx, `
-			SELECT id, setor_id, nome, quantidade, lider, created_by, created_at, lider_id
+			SELECT id, setor_id, nome, quantidade, lider, created_by, created_at, lider_id, timezone, require_presence_qr, network_policy, device_policy, parent_id
 			FROM setores
+			WHERE deleted_at IS NULL
 			ORDER BY nome
 		`)
 		if err != nil {
@@ -88,6 +132,11 @@ func ListSetores(database *db.Database) http.HandlerFunc {
 				&s.CreatedBy,
 				&s.CreatedAt,
 				&s.Lider_ID,
+				&s.Timezone,
+				&s.PresenceQR,
+				&s.Network,
+				&s.Devices,
+				&s.Parent_ID,
 			); err != nil {
 				http.Error(w, "scan error", http.StatusInternalServerError)
 				fmt.Printf("Error: %s", err)
@@ -109,7 +158,7 @@ func GetSetor(database *db.Database) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
 		query := `
@@ -125,8 +174,8 @@ func GetSetor(database *db.Database) http.HandlerFunc {
 				sf.atestado
 			FROM setores s
 			LEFT JOIN setor_funcionarios sf ON sf.setor_id = s.setor_id
-			LEFT JOIN users u ON u.user_id = sf.user_id
-			WHERE s.setor_id = $1
+			LEFT JOIN users u ON u.user_id = sf.user_id AND u.deleted_at IS NULL
+			WHERE s.setor_id = $1 AND s.deleted_at IS NULL
 			ORDER BY u.name
 		`
 
@@ -192,31 +241,91 @@ func GetSetor(database *db.Database) http.HandlerFunc {
 	}
 }
 
+// setorPatch é o corpo do PATCH de setor; só os campos presentes mudam.
+type setorPatch struct {
+	Nome       optional[string] `json:"nome"`
+	Lider      optional[string] `json:"lider"`
+	CreatedBy  optional[string] `json:"created_by"`
+	Quantidade optional[int32]  `json:"quantidade"`
+	PresenceQR optional[bool]   `json:"require_presence_qr"`
+	Network    optional[string] `json:"network_policy"`
+	Devices    optional[string] `json:"device_policy"`
+	Timezone   optional[string] `json:"timezone"`
+	Parent     optional[string] `json:"parent_id"`
+}
+
+func (req setorPatch) validate() (cols []column, errs fieldErrors) {
+	set := func(name string, value any) { cols = append(cols, column{name, value}) }
+
+	if req.Nome.ok(&errs, "nome", false) {
+		if v := strings.TrimSpace(req.Nome.Value); v == "" {
+			errs.add("nome", "is required")
+		} else {
+			set("nome", v)
+		}
+	}
+	if req.Lider.ok(&errs, "lider", true) {
+		set("lider", req.Lider.Ptr())
+	}
+	if req.CreatedBy.ok(&errs, "created_by", true) {
+		set("created_by", req.CreatedBy.Ptr())
+	}
+	if req.Quantidade.ok(&errs, "quantidade", false) {
+		if req.Quantidade.Value < 0 {
+			errs.add("quantidade", "cannot be negative")
+		}
+		set("quantidade", req.Quantidade.Value)
+	}
+	if req.PresenceQR.ok(&errs, "require_presence_qr", false) {
+		set("require_presence_qr", req.PresenceQR.Value)
+	}
+	if req.Network.ok(&errs, "network_policy", false) {
+		if !validNetworkPolicy(req.Network.Value) {
+			errs.add("network_policy", "must be off, flag or reject")
+		}
+		set("network_policy", req.Network.Value)
+	}
+	if req.Devices.ok(&errs, "device_policy", false) {
+		if !validDevicePolicy(req.Devices.Value) {
+			errs.add("device_policy", "must be off, flag or reject")
+		}
+		set("device_policy", req.Devices.Value)
+	}
+	// vazio/null remove o fuso próprio do setor
+	if req.Timezone.ok(&errs, "timezone", true) {
+		tz := emptyIfNull(req.Timezone.Ptr())
+		if tz != "" && !validTimezone(tz) {
+			errs.add("timezone", "is not a valid IANA timezone")
+		}
+		set("timezone", nullIfEmpty(tz))
+	}
+	// vazio/null tira o setor da hierarquia (vira raiz)
+	if req.Parent.ok(&errs, "parent_id", true) {
+		set("parent_id", nullIfEmpty(strings.TrimSpace(emptyIfNull(req.Parent.Ptr()))))
+	}
+	return cols, errs
+}
+
 func UpdateSetor(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setorID := r.PathValue("id")
 
+		var req setorPatch
 		var input map[string]any
-		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		if !decodeBody(w, r, &req, &input) {
+			return
+		}
+
+		cols, errs := req.validate()
+		if errs.write(w) {
 			return
 		}
 
 		fields := []string{}
 		values := []any{}
-		i := 1
-
-		for key, value := range input {
-			switch key {
-			case "nome", "lider", "created_by":
-				fields = append(fields, fmt.Sprintf("%s = $%d", key, i))
-				values = append(values, value)
-				i++
-			case "quantidade":
-				fields = append(fields, fmt.Sprintf("quantidade = $%d", i))
-				values = append(values, int(value.(float64)))
-				i++
-			}
+		for _, c := range cols {
+			values = append(values, c.Value)
+			fields = append(fields, fmt.Sprintf("%s = $%d", c.Name, len(values)))
 		}
 
 		if len(fields) == 0 {
@@ -227,21 +336,69 @@ func UpdateSetor(database *db.Database) http.HandlerFunc {
 		values = append(values, setorID)
 
 		query := fmt.Sprintf(
-			`UPDATE setores SET %s, updated_at = now() WHERE setor_id = $%d`,
+			`UPDATE setores SET %s, updated_at = now() WHERE setor_id = $%d AND deleted_at IS NULL`,
 			strings.Join(fields, ", "),
 			len(values),
 		)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		cmd, err := database.Pool().Exec(ctx, query, values...)
+		tx, err := database.Pool().Begin(ctx)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// com a tabela travada para escrita, duas mudanças de pai simultâneas
+		// não conseguem fechar um ciclo entre si
+		if req.Parent.Set {
+			if _, err := tx.Exec(ctx, `LOCK TABLE setores IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+				log.Println("DB error locking setores:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if err := checkSetorParent(ctx, tx, &errs, setorID, nullIfEmpty(strings.TrimSpace(emptyIfNull(req.Parent.Ptr())))); err != nil {
+				log.Println("DB error checking parent setor:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if errs.write(w) {
+				return
+			}
+		}
+
+		if req.Network.Set && req.Network.Value == NetworkPolicyReject {
+			if err := requireIPRule(ctx, tx, &errs, setorID); err != nil {
+				log.Println("DB error checking network rules:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if errs.write(w) {
+				return
+			}
+		}
+
+		cmd, err := tx.Exec(ctx, query, values...)
+		if writeDBError(w, err) {
+			return
+		}
 		if err != nil || cmd.RowsAffected() == 0 {
 			http.Error(w, "setor not found", http.StatusNotFound)
 			return
 		}
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
 
 		json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+
+		emitWebhook(ctx, database, webhooks.EventSetorUpdated, map[string]any{
+			"setor_id": setorID,
+			"changes":  input,
+		})
 	}
 }
 
@@ -249,13 +406,30 @@ func DeleteSetor(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setorID := r.PathValue("id")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
+		// sub-setores precisam ser movidos (ou excluídos) antes
+		var hasChildren bool
+		err := database.Pool().QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM setores WHERE parent_id = $1 AND deleted_at IS NULL)
+		`, setorID).Scan(&hasChildren)
+		if err != nil {
+			http.Error(w, "error deleting setor", http.StatusInternalServerError)
+			return
+		}
+		if hasChildren {
+			http.Error(w, "setor has sub-setores", http.StatusConflict)
+			return
+		}
+
+		// Exclusão lógica: o setor vai para a lixeira e pode ser restaurado.
 		cmd, err := database.Pool().Exec(
 			ctx,
-			`DELETE FROM setores WHERE setor_id = $1`,
+			`UPDATE setores SET deleted_at = now(), deleted_by = $2, delete_reason = $3 WHERE setor_id = $1 AND deleted_at IS NULL`,
 			setorID,
+			deletedBy(r),
+			deleteReason(r),
 		)
 
 		if err != nil {
@@ -269,5 +443,9 @@ func DeleteSetor(database *db.Database) http.HandlerFunc {
 		}
 
 		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+
+		emitWebhook(ctx, database, webhooks.EventSetorDeleted, map[string]any{
+			"setor_id": setorID,
+		})
 	}
 }
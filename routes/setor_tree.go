@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// setorSubtreeSQL lista o setor do argumento $arg e todos os que estão
+// abaixo dele. UNION (e não UNION ALL) para de descer se houver um ciclo
+// gravado antes da validação.
+func setorSubtreeSQL(arg int) string {
+	return fmt.Sprintf(`WITH RECURSIVE sub AS (
+		SELECT setor_id FROM setores WHERE setor_id = $%d
+		UNION
+		SELECT c.setor_id FROM setores c JOIN sub ON c.parent_id = sub.setor_id
+	) SELECT setor_id FROM sub`, arg)
+}
+
+// ledSetoresSQL lista os setores liderados pelo usuário $arg e todos os que
+// estão abaixo deles: o líder de uma diretoria enxerga os times dela.
+func ledSetoresSQL(arg int) string {
+	return fmt.Sprintf(`WITH RECURSIVE led AS (
+		SELECT setor_id FROM setores WHERE lider_id = $%d
+		UNION
+		SELECT c.setor_id FROM setores c JOIN led ON c.parent_id = led.setor_id
+	) SELECT setor_id FROM led`, arg)
+}
+
+// setorFilterSQL é a condição do filtro setor_id sobre expr, com o valor em
+// $arg. Com include_subsetores=true os setores abaixo também entram.
+func setorFilterSQL(q url.Values, expr string, arg int) string {
+	if q.Get("include_subsetores") == "true" {
+		return fmt.Sprintf("%s IN (%s)", expr, setorSubtreeSQL(arg))
+	}
+	return fmt.Sprintf("%s = $%d", expr, arg)
+}
+
+// rowQuerier é o pool ou uma transação aberta.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// checkSetorParent confere o setor pai: precisa existir fora da lixeira e não
+// pode ser o próprio setor nem um setor abaixo dele.
+func checkSetorParent(ctx context.Context, q rowQuerier, errs *fieldErrors, setorID string, parentID *string) error {
+	if parentID == nil {
+		return nil
+	}
+	var exists, cycle bool
+	err := q.QueryRow(ctx, `
+		SELECT
+			EXISTS (SELECT 1 FROM setores WHERE setor_id = $2 AND deleted_at IS NULL),
+			$2 IN (`+setorSubtreeSQL(1)+`)
+	`, setorID, *parentID).Scan(&exists, &cycle)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !exists:
+		errs.add("parent_id", "setor not found")
+	case cycle:
+		errs.add("parent_id", "cannot be the setor itself or one of its sub-setores")
+	}
+	return nil
+}
+
+// GET /api/setor/org-chart?root=<setor_id>
+//
+// Organograma: a árvore de setores com líder e quantidade de funcionários
+// ativos. Setores cujo pai não existe mais (ou está na lixeira) aparecem na
+// raiz. Com root, só a subárvore daquele setor.
+func SetorOrgChart(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT s.setor_id, s.nome, s.parent_id, s.lider_id, COALESCE(l.name, s.lider),
+				(SELECT COUNT(*) FROM users u
+				 WHERE u.setor_id = s.setor_id AND u.status = 'active' AND u.deleted_at IS NULL)
+			FROM setores s
+			LEFT JOIN users l ON l.user_id = s.lider_id
+			WHERE s.deleted_at IS NULL
+			ORDER BY s.nome
+		`)
+		if err != nil {
+			log.Println("DB error loading org chart:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		nodes := map[string]*models.OrgChartNode{}
+		order := []*models.OrgChartNode{}
+		for rows.Next() {
+			n := &models.OrgChartNode{Children: []*models.OrgChartNode{}}
+			if err := rows.Scan(&n.Setor_ID, &n.Nome, &n.Parent_ID, &n.Lider_ID, &n.Lider, &n.Headcount); err != nil {
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			nodes[n.Setor_ID] = n
+			order = append(order, n)
+		}
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		roots := []*models.OrgChartNode{}
+		for _, n := range order {
+			if n.Parent_ID != nil {
+				if p, ok := nodes[*n.Parent_ID]; ok {
+					p.Children = append(p.Children, n)
+					continue
+				}
+			}
+			roots = append(roots, n)
+		}
+
+		var total func(n *models.OrgChartNode) int64
+		total = func(n *models.OrgChartNode) int64 {
+			n.TotalHeadcount = n.Headcount
+			for _, c := range n.Children {
+				n.TotalHeadcount += total(c)
+			}
+			return n.TotalHeadcount
+		}
+		for _, n := range roots {
+			total(n)
+		}
+
+		if root := strings.TrimSpace(r.URL.Query().Get("root")); root != "" {
+			n, ok := nodes[root]
+			if !ok {
+				http.Error(w, "setor not found", http.StatusNotFound)
+				return
+			}
+			roots = []*models.OrgChartNode{n}
+		}
+
+		writeJSON(w, http.StatusOK, roots)
+	}
+}
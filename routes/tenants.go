@@ -0,0 +1,271 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+// Empresas clientes (somente super-admin). Para cadastrar os primeiros
+// usuários de uma empresa o super-admin usa POST /api/users com o header
+// X-Tenant-ID.
+
+// slug: minúsculas, dígitos e hífen, usado no login
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// tenantRequest é o corpo do POST e do PATCH de empresa.
+type tenantRequest struct {
+	Slug     optional[string] `json:"slug"`
+	Name     optional[string] `json:"name"`
+	CNPJ     optional[string] `json:"cnpj"`
+	Address  optional[string] `json:"address"`
+	Timezone optional[string] `json:"timezone"`
+	Status   optional[string] `json:"status"`
+}
+
+// validate aplica o corpo sobre t (a empresa atual no PATCH, zerada no POST).
+func (req tenantRequest) validate(t *models.Tenant, create bool) (cols []column, errs fieldErrors) {
+	set := func(name string, value any) { cols = append(cols, column{name, value}) }
+
+	if req.Slug.ok(&errs, "slug", false) {
+		t.Slug = strings.ToLower(strings.TrimSpace(req.Slug.Value))
+		if !tenantSlugPattern.MatchString(t.Slug) {
+			errs.add("slug", "must be 2-63 lowercase letters, digits or hyphens")
+		}
+		set("slug", t.Slug)
+	} else if create && !req.Slug.Set {
+		errs.add("slug", "is required")
+	}
+
+	if req.Name.ok(&errs, "name", false) {
+		t.Name = strings.Join(strings.Fields(req.Name.Value), " ")
+		switch {
+		case t.Name == "":
+			errs.add("name", "is required")
+		case len(t.Name) > 200:
+			errs.add("name", "is too long")
+		}
+		set("name", t.Name)
+	} else if create && !req.Name.Set {
+		errs.add("name", "is required")
+	}
+
+	// CNPJ com ou sem máscara; guarda só os dígitos
+	if req.CNPJ.ok(&errs, "cnpj", true) {
+		t.CNPJ = nullIfEmpty(strings.TrimSpace(emptyIfNull(req.CNPJ.Ptr())))
+		if t.CNPJ != nil {
+			d, ok := normalizeCNPJ(*t.CNPJ)
+			if !ok {
+				errs.add("cnpj", "invalid cnpj")
+			}
+			t.CNPJ = &d
+		}
+		set("cnpj", t.CNPJ)
+	}
+
+	if req.Address.ok(&errs, "address", true) {
+		t.Address = nullIfEmpty(strings.TrimSpace(emptyIfNull(req.Address.Ptr())))
+		if len(emptyIfNull(t.Address)) > 500 {
+			errs.add("address", "is too long")
+		}
+		set("address", t.Address)
+	}
+
+	if req.Timezone.ok(&errs, "timezone", true) {
+		t.Timezone = nullIfEmpty(strings.TrimSpace(emptyIfNull(req.Timezone.Ptr())))
+		if t.Timezone != nil && !validTimezone(*t.Timezone) {
+			errs.add("timezone", "invalid timezone")
+		}
+		set("timezone", t.Timezone)
+	}
+
+	if req.Status.ok(&errs, "status", false) {
+		t.Status = strings.TrimSpace(req.Status.Value)
+		if t.Status != "active" && t.Status != "suspended" {
+			errs.add("status", "must be active or suspended")
+		}
+		set("status", t.Status)
+	} else if create {
+		t.Status = "active"
+	}
+
+	return cols, errs
+}
+
+const tenantColumns = `tenant_id, slug, name, cnpj, address, timezone, status, created_at, updated_at`
+
+func scanTenant(row pgx.Row, t *models.Tenant, extra ...any) error {
+	return row.Scan(append([]any{&t.Tenant_ID, &t.Slug, &t.Name, &t.CNPJ, &t.Address,
+		&t.Timezone, &t.Status, &t.CreatedAt, &t.UpdatedAt}, extra...)...)
+}
+
+// quantidade de usuários de cada empresa (users tem row level security)
+const tenantUsersSQL = `(SELECT COUNT(*) FROM users u WHERE u.tenant_id = t.tenant_id AND u.deleted_at IS NULL)`
+
+// GET /api/tenants?q=&status=
+func ListTenants(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(db.AllTenants(r.Context()), 5*time.Second)
+		defer cancel()
+
+		q := r.URL.Query()
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+tenantColumns+`, `+tenantUsersSQL+`
+			FROM tenants t
+			WHERE ($1 = '' OR name ILIKE '%' || $1 || '%' OR slug ILIKE '%' || $1 || '%' OR cnpj = $2)
+			  AND ($3 = '' OR status = $3)
+			ORDER BY name
+		`, escapeLike(strings.TrimSpace(q.Get("q"))), onlyDigits(q.Get("q")), q.Get("status"))
+		if err != nil {
+			log.Println("DB error listing tenants:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		out := []models.Tenant{}
+		for rows.Next() {
+			var t models.Tenant
+			var users int64
+			if err := scanTenant(rows, &t, &users); err != nil {
+				log.Println("DB scan error:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			t.Users = &users
+			out = append(out, t)
+		}
+		if err := rows.Err(); err != nil {
+			log.Println("DB rows error:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// GET /api/tenants/{id}
+func GetTenant(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(db.AllTenants(r.Context()), 5*time.Second)
+		defer cancel()
+
+		var t models.Tenant
+		var users int64
+		err := scanTenant(database.Pool().QueryRow(ctx, `
+			SELECT `+tenantColumns+`, `+tenantUsersSQL+` FROM tenants t WHERE tenant_id = $1
+		`, r.PathValue("id")), &t, &users)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching tenant:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		t.Users = &users
+
+		writeJSON(w, http.StatusOK, t)
+	}
+}
+
+// POST /api/tenants
+func CreateTenant(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req tenantRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		var t models.Tenant
+		if _, errs := req.validate(&t, true); errs.write(w) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		err := scanTenant(database.Pool().QueryRow(ctx, `
+			INSERT INTO tenants (tenant_id, slug, name, cnpj, address, timezone, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+tenantColumns,
+			uuid.NewString(), t.Slug, t.Name, t.CNPJ, t.Address, t.Timezone, t.Status,
+		), &t)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error creating tenant:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, t)
+	}
+}
+
+// PATCH /api/tenants/{id}
+//
+// status=suspended bloqueia o login, os tokens já emitidos (em até 30s, o
+// cache do middleware) e os quiosques da empresa; os dados ficam intactos.
+func UpdateTenant(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req tenantRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+
+		var t models.Tenant
+		cols, errs := req.validate(&t, false)
+		if errs.write(w) {
+			return
+		}
+		if len(cols) == 0 {
+			http.Error(w, "no valid fields to update", http.StatusBadRequest)
+			return
+		}
+
+		fields := []string{}
+		values := []any{}
+		for _, col := range cols {
+			values = append(values, col.Value)
+			fields = append(fields, fmt.Sprintf("%s = $%d", col.Name, len(values)))
+		}
+		values = append(values, r.PathValue("id"))
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		err := scanTenant(database.Pool().QueryRow(ctx, fmt.Sprintf(`
+			UPDATE tenants SET %s, updated_at = now()
+			WHERE tenant_id = $%d
+			RETURNING `+tenantColumns,
+			strings.Join(fields, ", "), len(values)), values...), &t)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		}
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
+			log.Println("DB error updating tenant:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, t)
+	}
+}
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Rafhael-Viana/m/db"
+)
+
+// fuso usado quando nem o usuário, nem o setor, nem a empresa têm um configurado
+const fallbackTimezone = "America/Sao_Paulo"
+
+func defaultTimezone() string {
+	if tz := os.Getenv("APP_TIMEZONE"); tz != "" {
+		return tz
+	}
+	return fallbackTimezone
+}
+
+// effectiveTimezoneSQL resolve o fuso de uma batida em SQL. Espera os aliases
+// u (users) e s (setores) e recebe o fuso padrão como parâmetro posicional.
+// Depois do usuário e do setor vale o fuso da empresa.
+func effectiveTimezoneSQL(argPos int) string {
+	return fmt.Sprintf(`COALESCE(NULLIF(u.timezone, ''), NULLIF(s.timezone, ''),
+		(SELECT NULLIF(t.timezone, '') FROM tenants t WHERE t.tenant_id = u.tenant_id), $%d)`, argPos)
+}
+
+// validTimezone aceita nomes IANA, ex: America/Manaus
+func validTimezone(name string) bool {
+	if name == "" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// loadLocation devolve o fuso pelo nome, caindo no padrão se for inválido
+func loadLocation(name string) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil && name != "" {
+		return loc
+	}
+	loc, err := time.LoadLocation(defaultTimezone())
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// userLocation busca o fuso efetivo do usuário: o dele, senão o do setor, senão o
+// da empresa, senão o padrão.
+func userLocation(ctx context.Context, database *db.Database, userID string) (*time.Location, error) {
+	var tz string
+	err := database.Pool().QueryRow(ctx, `
+		SELECT `+effectiveTimezoneSQL(2)+`
+		FROM users u
+		LEFT JOIN setores s ON s.setor_id = u.setor_id
+		WHERE u.user_id = $1 AND u.deleted_at IS NULL
+	`, userID, defaultTimezone()).Scan(&tz)
+	if err != nil {
+		return nil, err
+	}
+	return loadLocation(tz), nil
+}
+
+// inZone converte um horário opcional para o fuso informado
+func inZone(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.In(loc)
+	return &v
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// emptyIfNull é o inverso de nullIfEmpty.
+func emptyIfNull(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
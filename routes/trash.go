@@ -0,0 +1,286 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/live"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/retention"
+	"github.com/Rafhael-Viana/m/storage"
+	"github.com/Rafhael-Viana/m/webhooks"
+)
+
+// Rótulo exibido na lixeira para cada tipo de item.
+var trashLabels = map[string]string{
+	"users":   "name",
+	"setores": "nome",
+	"points":  "user_id",
+}
+
+type TrashItem struct {
+	Kind       string    `json:"kind"`
+	ID         string    `json:"id"`
+	Label      *string   `json:"label"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	DeletedBy  *string   `json:"deleted_by"`
+	Reason     *string   `json:"reason"`
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// deleteReason lê o motivo da exclusão de ?reason= ou do corpo {"reason": "..."}.
+// O corpo é opcional.
+func deleteReason(r *http.Request) *string {
+	if v := strings.TrimSpace(r.URL.Query().Get("reason")); v != "" {
+		return &v
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	return nullIfEmpty(strings.TrimSpace(body.Reason))
+}
+
+// deletedBy é quem está excluindo, quando a rota é autenticada.
+func deletedBy(r *http.Request) *string {
+	userID, _ := mid.UserIDFromContext(r.Context())
+	return nullIfEmpty(userID)
+}
+
+// trashPolicy devolve quantos meses um item precisa ficar na lixeira antes de
+// poder ser removido de vez. Vale mesmo com a política desligada: desligada
+// significa só que o job não apaga sozinho.
+func trashPolicy(ctx context.Context, database *db.Database) (int, error) {
+	p, err := retention.LoadPolicy(ctx, database, retention.CategoryTrash)
+	if err != nil {
+		return 0, err
+	}
+	return p.RetentionMonths, nil
+}
+
+// GET /api/trash?kind=users|setores|points&limit=&offset=
+func ListTrash(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		kind := q.Get("kind")
+		column, ok := retention.TrashTables[kind]
+		if !ok {
+			http.Error(w, "kind must be users, setores or points", http.StatusBadRequest)
+			return
+		}
+
+		limit := 50
+		offset := 0
+		if v := q.Get("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+				limit = n
+			}
+		}
+		if v := q.Get("offset"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				offset = n
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		months, err := trashPolicy(ctx, database)
+		if err != nil {
+			log.Println("DB error fetching trash policy:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+column+`::text, `+trashLabels[kind]+`::text, deleted_at, deleted_by, delete_reason
+			FROM `+kind+`
+			WHERE deleted_at IS NOT NULL
+			ORDER BY deleted_at DESC
+			LIMIT $1 OFFSET $2
+		`, limit, offset)
+		if err != nil {
+			log.Println("DB error listing trash:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		items := []TrashItem{}
+		for rows.Next() {
+			it := TrashItem{Kind: kind}
+			if err := rows.Scan(&it.ID, &it.Label, &it.DeletedAt, &it.DeletedBy, &it.Reason); err != nil {
+				log.Println("DB error reading trash:", err)
+				http.Error(w, "error reading rows", http.StatusInternalServerError)
+				return
+			}
+			it.PurgeAfter = it.DeletedAt.AddDate(0, months, 0)
+			items = append(items, it)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"limit":  limit,
+			"offset": offset,
+			"items":  items,
+		})
+	}
+}
+
+// POST /api/trash/{kind}/{id}/restore
+func RestoreTrash(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind := r.PathValue("kind")
+		column, ok := retention.TrashTables[kind]
+		if !ok {
+			http.Error(w, "unknown kind", http.StatusNotFound)
+			return
+		}
+		key := r.PathValue("id")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		// Uma batida aberta só volta se o usuário não tiver aberto outra nesse meio tempo.
+		if kind == "points" {
+			var conflict bool
+			err := database.Pool().QueryRow(ctx, `
+				SELECT EXISTS (
+					SELECT 1 FROM points o
+					JOIN points p ON p.user_id = o.user_id
+					WHERE p.id::text = $1 AND p.status = 'open'
+					  AND o.id <> p.id AND o.status = 'open' AND o.deleted_at IS NULL
+				)
+			`, key).Scan(&conflict)
+			if err != nil {
+				log.Println("DB error checking open point:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if conflict {
+				http.Error(w, "user already has an open point", http.StatusConflict)
+				return
+			}
+		}
+
+		var userID *string
+		returning := "NULL::text"
+		if kind != "setores" {
+			returning = "user_id"
+		}
+		err := database.Pool().QueryRow(ctx, `
+			UPDATE `+kind+`
+			SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL
+			WHERE `+column+`::text = $1 AND deleted_at IS NOT NULL
+			RETURNING `+returning+`
+		`, key).Scan(&userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "item not found in trash", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("DB error restoring trash item:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "restored"})
+
+		switch kind {
+		case "users":
+			emitWebhook(ctx, database, webhooks.EventUserUpdated, map[string]any{
+				"user_id":  emptyIfNull(userID),
+				"restored": true,
+			})
+		case "setores":
+			emitWebhook(ctx, database, webhooks.EventSetorUpdated, map[string]any{
+				"setor_id": key,
+				"restored": true,
+			})
+		case "points":
+			id, _ := strconv.Atoi(key)
+			emitWebhook(ctx, database, webhooks.EventPointUpdated, map[string]any{
+				"point_id": id,
+				"user_id":  emptyIfNull(userID),
+				"restored": true,
+			})
+			publishAttendance(ctx, database, live.Event{
+				Type:    live.EventCorrection,
+				Action:  "restored",
+				UserID:  emptyIfNull(userID),
+				PointID: id,
+			})
+		}
+	}
+}
+
+// DELETE /api/trash/{kind}/{id}
+//
+// Remoção definitiva, só depois do prazo da política 'trash'.
+func PurgeTrash(database *db.Database, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind := r.PathValue("kind")
+		column, ok := retention.TrashTables[kind]
+		if !ok {
+			http.Error(w, "unknown kind", http.StatusNotFound)
+			return
+		}
+		key := r.PathValue("id")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var deletedAt time.Time
+		err := database.Pool().QueryRow(ctx, `
+			SELECT deleted_at FROM `+kind+`
+			WHERE `+column+`::text = $1 AND deleted_at IS NOT NULL
+		`, key).Scan(&deletedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "item not found in trash", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("DB error fetching trash item:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		months, err := trashPolicy(ctx, database)
+		if err != nil {
+			log.Println("DB error fetching trash policy:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if purgeAfter := deletedAt.AddDate(0, months, 0); time.Now().Before(purgeAfter) {
+			writeJSON(w, http.StatusConflict, map[string]any{
+				"error":       "retention period not reached",
+				"purge_after": purgeAfter,
+			})
+			return
+		}
+
+		err = retention.PurgeTrashed(ctx, database, store, kind, key)
+		if errors.Is(err, retention.ErrNotTrashed) {
+			http.Error(w, "item not found in trash", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("DB error purging trash item:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "purged"})
+	}
+}
@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/google/uuid"
+
+	"github.com/Rafhael-Viana/m/imaging"
+	"github.com/Rafhael-Viana/m/storage"
+)
+
+type savedImage struct {
+	URL      string
+	ThumbURL string
+}
+
+func uploadKey(name string, dir ...string) string {
+	return path.Join(append(dir, name)...)
+}
+
+// saveFile grava os bytes em {dir...}/{uuid}{ext} e devolve a chave gerada.
+func saveFile(ctx context.Context, store storage.Storage, data []byte, ext, contentType string, dir ...string) (string, error) {
+	key := uploadKey(uuid.New().String()+ext, dir...)
+	return key, store.Put(ctx, key, data, contentType)
+}
+
+// saveImage grava a imagem já processada e a miniatura ao lado ({uuid}_thumb{ext}).
+func saveImage(ctx context.Context, store storage.Storage, res *imaging.Result, dir ...string) (savedImage, error) {
+	base := uuid.New().String()
+
+	key := uploadKey(base+res.Ext, dir...)
+	if err := store.Put(ctx, key, res.Data, res.ContentType); err != nil {
+		return savedImage{}, err
+	}
+	out := savedImage{URL: storage.URL(key)}
+
+	if len(res.Thumb) > 0 {
+		thumb := uploadKey(base+"_thumb"+res.Ext, dir...)
+		if err := store.Put(ctx, thumb, res.Thumb, res.ContentType); err != nil {
+			return savedImage{}, err
+		}
+		out.ThumbURL = storage.URL(thumb)
+	}
+
+	return out, nil
+}
+
+// imageError traduz erros do pipeline de imagem em respostas HTTP.
+func imageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedType):
+		http.Error(w, "invalid image type", http.StatusForbidden)
+	case errors.Is(err, imaging.ErrNotImage):
+		http.Error(w, "file is not a valid image", http.StatusBadRequest)
+	case errors.Is(err, imaging.ErrTooLarge):
+		http.Error(w, "image dimensions too large", http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "could not process image", http.StatusInternalServerError)
+	}
+}
+
+// detectUploadType identifica o tipo pelo conteúdo. MP3 sem tag ID3 não é
+// reconhecido pelo http.DetectContentType, então checamos o frame sync.
+func detectUploadType(data []byte) string {
+	ct := imaging.DetectType(data)
+	if ct == "application/octet-stream" && len(data) > 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 {
+		return "audio/mpeg"
+	}
+	return ct
+}
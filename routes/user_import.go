@@ -0,0 +1,561 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+	"github.com/Rafhael-Viana/m/webhooks"
+)
+
+const (
+	importMaxBytes = 10 << 20 // 10MB
+	importMaxRows  = 5000
+)
+
+// Cabeçalhos aceitos na planilha (em minúsculas) e o campo correspondente.
+var importColumns = map[string]string{
+	"name":       "name",
+	"nome":       "name",
+	"username":   "username",
+	"usuario":    "username",
+	"usuário":    "username",
+	"email":      "email",
+	"e-mail":     "email",
+	"senha":      "senha",
+	"password":   "senha",
+	"cargo":      "cargo",
+	"cargo_id":   "cargo",
+	"setor":      "setor",
+	"setor_id":   "setor",
+	"nascimento": "nascimento",
+	"birth":      "nascimento",
+	"status":     "status",
+	"role":       "role",
+	"timezone":   "timezone",
+	"cpf":        "cpf",
+	"pis":        "pis",
+	"nis":        "pis",
+	"matricula":  "matricula",
+	"matrícula":  "matricula",
+	"admissao":   "admissao",
+	"admissão":   "admissao",
+	"admission":  "admissao",
+
+	"contract_type": "contrato",
+	"contrato":      "contrato",
+	"tipo_contrato": "contrato",
+}
+
+// Campos que não podem se repetir, nem na planilha nem no banco.
+var importUniqueFields = []string{"username", "email", "cpf", "pis", "matricula"}
+
+type ImportError struct {
+	Row     int    `json:"row"` // linha da planilha, contando o cabeçalho
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Rows    int           `json:"rows"`
+	Valid   int           `json:"valid"`
+	Created int           `json:"created"`
+	Errors  []ImportError `json:"errors"`
+}
+
+type importRow struct {
+	line int
+	user models.User
+}
+
+// readSheet lê o arquivo enviado como CSV (vírgula ou ponto e vírgula) ou
+// XLSX (primeira aba). O formato vem dos bytes, não da extensão.
+func readSheet(data []byte) ([][]string, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errors.New("workbook has no sheets")
+		}
+		return f.GetRows(sheets[0])
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return cr.ReadAll()
+}
+
+// parseImportDate aceita YYYY-MM-DD e DD/MM/YYYY.
+func parseImportDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse("02/01/2006", s)
+}
+
+// POST /api/users/import?dry_run=true
+//
+// Recebe o arquivo no campo "file" (multipart) ou direto no corpo. Todas as
+// linhas são validadas antes de gravar; se alguma tiver erro nada é inserido.
+func ImportUsers(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+		var (
+			data []byte
+			err  error
+		)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, header, ferr := r.FormFile("file")
+			if ferr != nil {
+				http.Error(w, "Invalid file", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			if ext := strings.ToLower(filepath.Ext(header.Filename)); ext != "" && ext != ".csv" && ext != ".xlsx" {
+				http.Error(w, "file must be .csv or .xlsx", http.StatusBadRequest)
+				return
+			}
+			data, err = io.ReadAll(file)
+		} else {
+			data, err = io.ReadAll(r.Body)
+		}
+		if err != nil {
+			http.Error(w, "Failed to read file", http.StatusBadRequest)
+			return
+		}
+
+		records, err := readSheet(data)
+		if err != nil {
+			http.Error(w, "could not parse file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(records) < 2 {
+			http.Error(w, "file has no rows", http.StatusBadRequest)
+			return
+		}
+		if len(records)-1 > importMaxRows {
+			http.Error(w, fmt.Sprintf("at most %d rows per import", importMaxRows), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// posição de cada campo conhecido no cabeçalho
+		cols := map[string]int{}
+		for i, h := range records[0] {
+			if field, ok := importColumns[strings.ToLower(strings.TrimSpace(h))]; ok {
+				cols[field] = i
+			}
+		}
+		for _, field := range []string{"name", "username", "email", "senha"} {
+			if _, ok := cols[field]; !ok {
+				http.Error(w, "missing column: "+field, http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+
+		roles, _ := mid.RoleFromContext(r.Context())
+
+		setoresByID, setoresByName, err := loadImportSetores(ctx, database)
+		if err != nil {
+			log.Println("DB error loading setores:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		contracts, err := loadImportContracts(ctx, database)
+		if err != nil {
+			log.Println("DB error loading contract types:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		cargos, err := loadCargos(ctx, database)
+		if err != nil {
+			log.Println("DB error loading cargos:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		rep := ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+		rows := []importRow{}
+		seen := map[string]map[string]int{}
+		for _, f := range importUniqueFields {
+			seen[f] = map[string]int{}
+		}
+
+		for n, rec := range records[1:] {
+			line := n + 2
+			get := func(field string) string {
+				i, ok := cols[field]
+				if !ok || i >= len(rec) {
+					return ""
+				}
+				return strings.TrimSpace(rec[i])
+			}
+
+			// linhas totalmente em branco são ignoradas
+			if strings.TrimSpace(strings.Join(rec, "")) == "" {
+				continue
+			}
+			rep.Rows++
+
+			fail := func(field, msg string) {
+				rep.Errors = append(rep.Errors, ImportError{Row: line, Field: field, Message: msg})
+			}
+			before := len(rep.Errors)
+
+			u := models.User{
+				Name:     get("name"),
+				Username: get("username"),
+				Email:    strings.ToLower(get("email")),
+				Senha:    get("senha"),
+				Status:   models.StatusUser(get("status")),
+				Role:     get("role"),
+				Timezone: nullIfEmpty(get("timezone")),
+			}
+
+			for _, field := range []string{"name", "username", "email", "senha"} {
+				if get(field) == "" {
+					fail(field, field+" is required")
+				}
+			}
+			if msg := roleGrantError(roles, u.Role); msg != "" {
+				fail("role", msg)
+			}
+
+			for _, field := range []string{"cpf", "pis", "matricula"} {
+				v := get(field)
+				if v == "" {
+					continue
+				}
+				d, ok := identifierNormalizers[field](v)
+				if !ok {
+					fail(field, "invalid "+field)
+					continue
+				}
+				switch field {
+				case "cpf":
+					u.CPF = &d
+				case "pis":
+					u.PIS = &d
+				case "matricula":
+					u.Matricula = &d
+				}
+			}
+
+			keys := importKeys(u)
+			for _, field := range importUniqueFields {
+				key, has := keys[field]
+				if !has {
+					continue
+				}
+				if first, dup := seen[field][key]; dup {
+					fail(field, fmt.Sprintf("duplicate %s (also on row %d)", field, first))
+				} else {
+					seen[field][key] = line
+				}
+			}
+
+			if u.Status == "" {
+				u.Status = models.StatusActive
+			}
+			if !u.IsValidStatus(u.Status) {
+				fail("status", "invalid status")
+			}
+
+			if u.Timezone != nil && !validTimezone(*u.Timezone) {
+				fail("timezone", "invalid timezone")
+			}
+
+			if v := get("nascimento"); v != "" {
+				t, err := parseImportDate(v)
+				if err != nil {
+					fail("nascimento", "invalid date (use YYYY-MM-DD or DD/MM/YYYY)")
+				} else {
+					u.Nascimento = &t
+				}
+			}
+			if v := get("admissao"); v != "" {
+				t, err := parseImportDate(v)
+				if err != nil {
+					fail("admissao", "invalid date (use YYYY-MM-DD or DD/MM/YYYY)")
+				} else {
+					u.Admissao = &t
+				}
+			}
+
+			// contrato pelo código ou pelo nome; vazio fica CLT
+			u.Contract = models.ContractCLT
+			if v := get("contrato"); v != "" {
+				if code, ok := contracts[strings.ToLower(v)]; ok {
+					u.Contract = code
+				} else {
+					fail("contrato", "contract type not found")
+				}
+			}
+
+			// cargo por ID ou pelo nome do catálogo; o contrato padrão do
+			// cargo vale quando a planilha não traz contrato
+			if v := get("cargo"); v != "" {
+				if c, ok := cargos.find(v); ok {
+					u.CargoID, u.Cargo = &c.Cargo_ID, &c.Nome
+					if c.Contract != nil && get("contrato") == "" {
+						u.Contract = *c.Contract
+					}
+				} else {
+					fail("cargo", "cargo not found in the positions catalog")
+				}
+			}
+
+			// setor por ID ou pelo nome (sem diferenciar maiúsculas)
+			if v := get("setor"); v != "" {
+				if s, ok := setoresByID[v]; ok {
+					u.Setor_ID, u.Setor = &s.Setor_ID, &s.Nome
+				} else if matches := setoresByName[strings.ToLower(v)]; len(matches) == 1 {
+					u.Setor_ID, u.Setor = &matches[0].Setor_ID, &matches[0].Nome
+				} else if len(matches) > 1 {
+					fail("setor", "ambiguous setor name, use the setor_id")
+				} else {
+					fail("setor", "setor not found")
+				}
+			}
+
+			if len(rep.Errors) == before {
+				rows = append(rows, importRow{line: line, user: u})
+			}
+		}
+
+		// valores que já existem (inclusive na lixeira, pois a restrição de
+		// unicidade continua valendo para eles)
+		taken, err := takenUserKeys(ctx, database, seen)
+		if err != nil {
+			log.Println("DB error checking existing users:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		valid := rows[:0]
+		for _, row := range rows {
+			ok := true
+			for _, field := range importUniqueFields {
+				if key, has := importKeys(row.user)[field]; has && taken[field+":"+key] {
+					rep.Errors = append(rep.Errors, ImportError{Row: row.line, Field: field, Message: field + " already exists"})
+					ok = false
+				}
+			}
+			if ok {
+				valid = append(valid, row)
+			}
+		}
+		rows = valid
+		rep.Valid = len(rows)
+
+		if dryRun {
+			writeJSON(w, http.StatusOK, rep)
+			return
+		}
+		if len(rep.Errors) > 0 {
+			writeJSON(w, http.StatusUnprocessableEntity, rep)
+			return
+		}
+
+		created, err := insertImportedUsers(ctx, database, rows, changedBy(r))
+		if err != nil {
+			log.Println("DB error importing users:", err)
+			if isUniqueViolation(err) {
+				http.Error(w, "username, email, cpf, pis or matricula already in use", http.StatusConflict)
+				return
+			}
+			http.Error(w, "could not import users", http.StatusInternalServerError)
+			return
+		}
+		rep.Created = len(created)
+
+		writeJSON(w, http.StatusCreated, rep)
+
+		for _, u := range created {
+			emitWebhook(ctx, database, webhooks.EventUserCreated, u)
+		}
+	}
+}
+
+func loadImportSetores(ctx context.Context, database *db.Database) (map[string]models.Setor, map[string][]models.Setor, error) {
+	rows, err := database.Pool().Query(ctx, `SELECT setor_id, nome FROM setores WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	byID := map[string]models.Setor{}
+	byName := map[string][]models.Setor{}
+	for rows.Next() {
+		var s models.Setor
+		if err := rows.Scan(&s.Setor_ID, &s.Nome); err != nil {
+			return nil, nil, err
+		}
+		byID[s.Setor_ID] = s
+		key := strings.ToLower(strings.TrimSpace(s.Nome))
+		byName[key] = append(byName[key], s)
+	}
+	return byID, byName, rows.Err()
+}
+
+// loadImportContracts indexa os contratos por código e por nome, em minúsculas.
+func loadImportContracts(ctx context.Context, database *db.Database) (map[string]string, error) {
+	rows, err := database.Pool().Query(ctx, `SELECT code, name FROM contract_types`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byKey := map[string]string{}
+	for rows.Next() {
+		var code, name string
+		if err := rows.Scan(&code, &name); err != nil {
+			return nil, err
+		}
+		byKey[strings.ToLower(name)] = code
+		byKey[strings.ToLower(code)] = code
+	}
+	return byKey, rows.Err()
+}
+
+// importKeys devolve os valores únicos preenchidos do usuário, já no formato
+// comparado (username e e-mail sem diferenciar maiúsculas).
+func importKeys(u models.User) map[string]string {
+	keys := map[string]string{}
+	if u.Username != "" {
+		keys["username"] = strings.ToLower(u.Username)
+	}
+	if u.Email != "" {
+		keys["email"] = strings.ToLower(u.Email)
+	}
+	for field, v := range map[string]*string{"cpf": u.CPF, "pis": u.PIS, "matricula": u.Matricula} {
+		if v != nil {
+			keys[field] = strings.ToLower(*v)
+		}
+	}
+	return keys
+}
+
+// takenUserKeys devolve "<campo>:<valor>" já usados no banco.
+func takenUserKeys(ctx context.Context, database *db.Database, seen map[string]map[string]int) (map[string]bool, error) {
+	parts := []string{}
+	args := []any{}
+	for _, field := range importUniqueFields {
+		values := make([]string, 0, len(seen[field]))
+		for k := range seen[field] {
+			values = append(values, k)
+		}
+		args = append(args, values)
+		parts = append(parts, fmt.Sprintf(`SELECT '%[1]s:' || lower(%[1]s) FROM users WHERE lower(%[1]s) = ANY($%[2]d)`, field, len(args)))
+	}
+
+	rows, err := database.Pool().Query(ctx, strings.Join(parts, " UNION "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	taken := map[string]bool{}
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		taken[k] = true
+	}
+	return taken, rows.Err()
+}
+
+// insertImportedUsers grava tudo numa transação: ou entram todos, ou nenhum.
+func insertImportedUsers(ctx context.Context, database *db.Database, rows []importRow, importedBy *string) ([]models.User, error) {
+	tx, err := database.Pool().Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	created := make([]models.User, 0, len(rows))
+	for _, row := range rows {
+		u := row.user
+
+		hashed, err := bcrypt.GenerateFromPassword([]byte(u.Senha), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		u.User_ID = uuid.NewString()
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO users (
+				name, senha, email, username, user_id,
+				setor, cargo, nascimento, status, role,
+				setor_id, timezone, cpf, pis, matricula, admissao,
+				contract_type, cargo_id
+			)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)
+			RETURNING id
+		`,
+			u.Name, string(hashed), u.Email, u.Username, u.User_ID,
+			u.Setor, u.Cargo, u.Nascimento, u.Status, u.Role,
+			u.Setor_ID, u.Timezone, u.CPF, u.PIS, u.Matricula,
+			u.Admissao, u.Contract, u.CargoID,
+		).Scan(&u.ID)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.line, err)
+		}
+
+		if u.Setor_ID != nil {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO setor_funcionarios (setor_id, user_id, total_semana, total_mes, total_extra_mes, faltas, atestado)
+				VALUES ($1, $2, 0, 0, 0, 0, 0)
+				ON CONFLICT (user_id)
+				DO UPDATE SET setor_id = EXCLUDED.setor_id
+			`, u.Setor_ID, u.User_ID)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row.line, err)
+			}
+		}
+
+		if err := recordAssignment(ctx, tx, u.User_ID, u.Admissao, importedBy, nil); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row.line, err)
+		}
+
+		u.Senha = ""
+		created = append(created, u)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestReadSheet(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{
+			"comma",
+			"nome,username,email\nAna,ana,ana@x.com\n",
+			[][]string{{"nome", "username", "email"}, {"Ana", "ana", "ana@x.com"}},
+		},
+		{
+			// Excel em português salva com ponto e vírgula
+			"semicolon with commas in values",
+			"nome;setor\nSouza, Ana;RH\n",
+			[][]string{{"nome", "setor"}, {"Souza, Ana", "RH"}},
+		},
+		{
+			"utf-8 bom and crlf",
+			"\xef\xbb\xbfnome,usuário\r\nJosé,jose\r\n",
+			[][]string{{"nome", "usuário"}, {"José", "jose"}},
+		},
+		{
+			"ragged rows and leading spaces",
+			"nome, email\nAna\nBia, bia@x.com,extra\n",
+			[][]string{{"nome", "email"}, {"Ana"}, {"Bia", "bia@x.com", "extra"}},
+		},
+		{
+			"quoted newline",
+			"nome,obs\n\"Ana\",\"linha 1\nlinha 2\"\n",
+			[][]string{{"nome", "obs"}, {"Ana", "linha 1\nlinha 2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSheet([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSheet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSheetXLSX(t *testing.T) {
+	f := excelize.NewFile()
+	defer f.Close()
+	rows := [][]any{{"nome", "username"}, {"Ana", "ana"}, {"Bia", "bia"}}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readSheet(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"nome", "username"}, {"Ana", "ana"}, {"Bia", "bia"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readSheet = %q, want %q", got, want)
+	}
+
+	// parece XLSX (zip) mas não é
+	if _, err := readSheet([]byte("PK\x03\x04garbage")); err == nil {
+		t.Error("readSheet accepted a broken workbook")
+	}
+}
+
+func TestParseImportDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"1990-05-31", time.Date(1990, 5, 31, 0, 0, 0, 0, time.UTC), true},
+		{"31/05/1990", time.Date(1990, 5, 31, 0, 0, 0, 0, time.UTC), true},
+		{"05/31/1990", time.Time{}, false}, // formato americano
+		{"1990-02-30", time.Time{}, false},
+		{"31-05-1990", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseImportDate(tt.in)
+		if (err == nil) != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseImportDate(%q) = %v, %v; want %v, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestImportColumns(t *testing.T) {
+	tests := map[string]string{
+		"nome":     "name",
+		"usuário":  "username",
+		"e-mail":   "email",
+		"password": "senha",
+		"setor_id": "setor",
+		"birth":    "nascimento",
+	}
+	for header, want := range tests {
+		if got := importColumns[header]; got != want {
+			t.Errorf("importColumns[%q] = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/Rafhael-Viana/m/db"
+	"github.com/Rafhael-Viana/m/models"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]{3,50}$`)
+
+const minPasswordLen = 6
+
+// userRequest é o corpo aceito na criação (POST) e na edição (PATCH) de
+// usuário. No PATCH só os campos presentes são alterados; username e email
+// não mudam por aqui.
+type userRequest struct {
+	Name      optional[string] `json:"name"`
+	Username  optional[string] `json:"username"`
+	Email     optional[string] `json:"email"`
+	Senha     optional[string] `json:"senha"`
+	Setor     optional[string] `json:"setor"`
+	SetorID   optional[string] `json:"setor_id"`
+	Cargo     optional[string] `json:"cargo"` // nome de um cargo do catálogo
+	CargoID   optional[string] `json:"cargo_id"`
+	Status    optional[string] `json:"status"`
+	Role      optional[string] `json:"role"`
+	Birth     optional[string] `json:"birth"`
+	Admission optional[string] `json:"admission_date"`
+	Timezone  optional[string] `json:"timezone"`
+	CPF       optional[string] `json:"cpf"`
+	PIS       optional[string] `json:"pis"`
+	Matricula optional[string] `json:"matricula"`
+	Contract  optional[string] `json:"contract_type"`
+
+	// só no PATCH: vigência e motivo da mudança de setor, cargo ou status
+	EffectiveDate optional[string] `json:"effective_date"`
+	ChangeReason  optional[string] `json:"change_reason"`
+}
+
+// column é uma coluna alterada pelo PATCH, na ordem em que foi validada.
+type column struct {
+	Name  string
+	Value any
+}
+
+// validate confere o corpo e devolve o usuário (para o POST) e as colunas
+// presentes (para o PATCH). Todos os problemas vêm juntos em errs. O cargo
+// depende do catálogo e fica para resolveUserCargo.
+func (req userRequest) validate(create bool) (u models.User, cols []column, errs fieldErrors) {
+	set := func(name string, value any) { cols = append(cols, column{name, value}) }
+
+	// texto obrigatório quando presente (e na criação)
+	required := func(field string, o optional[string], max int) (string, bool) {
+		if !o.Set {
+			if create {
+				errs.add(field, "is required")
+			}
+			return "", false
+		}
+		if !o.ok(&errs, field, false) {
+			return "", false
+		}
+		v := strings.TrimSpace(o.Value)
+		switch {
+		case v == "":
+			errs.add(field, "is required")
+			return "", false
+		case len(v) > max:
+			errs.add(field, "is too long")
+			return "", false
+		}
+		return v, true
+	}
+
+	// texto opcional: null ou "" limpam a coluna
+	nullable := func(field string, o optional[string], max int) (*string, bool) {
+		if !o.ok(&errs, field, true) {
+			return nil, false
+		}
+		v := nullIfEmpty(strings.TrimSpace(emptyIfNull(o.Ptr())))
+		if v != nil && len(*v) > max {
+			errs.add(field, "is too long")
+			return nil, false
+		}
+		return v, true
+	}
+
+	if v, ok := required("name", req.Name, 150); ok {
+		u.Name = v
+		set("name", v)
+	}
+
+	if create {
+		if v, ok := required("username", req.Username, 50); ok {
+			if !usernamePattern.MatchString(v) {
+				errs.add("username", "must have 3 to 50 letters, digits or . _ @ -")
+			}
+			u.Username = v
+		}
+		if v, ok := required("email", req.Email, 254); ok {
+			if !validEmail(v) {
+				errs.add("email", "is not a valid email address")
+			}
+			u.Email = v
+		}
+	}
+
+	if v, ok := required("senha", req.Senha, 72); ok {
+		if len(v) < minPasswordLen {
+			errs.add("senha", "must have at least 6 characters")
+		}
+		// senha vale como digitada, sem aparar espaços
+		u.Senha = req.Senha.Value
+		set("senha", u.Senha)
+	}
+
+	if v, ok := nullable("setor", req.Setor, 100); ok {
+		u.Setor = v
+		set("setor", v)
+	}
+	if v, ok := nullable("setor_id", req.SetorID, 100); ok {
+		u.Setor_ID = v
+		set("setor_id", v)
+	}
+
+	u.Status = models.StatusActive
+	if req.Status.ok(&errs, "status", false) && !(create && req.Status.Value == "") {
+		u.Status = models.StatusUser(req.Status.Value)
+		if !u.IsValidStatus(u.Status) {
+			errs.add("status", "must be active or inactive")
+		}
+		set("status", u.Status)
+	}
+
+	if req.Role.ok(&errs, "role", false) {
+		u.Role = strings.TrimSpace(req.Role.Value)
+		if len(u.Role) > 50 {
+			errs.add("role", "is too long")
+		}
+		set("role", u.Role)
+	}
+
+	if req.Birth.Set {
+		u.Nascimento = checkDate(&errs, "birth", req.Birth, true)
+		set("nascimento", u.Nascimento)
+	}
+	if req.Admission.Set {
+		u.Admissao = checkDate(&errs, "admission_date", req.Admission, false)
+		set("admissao", u.Admissao)
+	}
+
+	if v, ok := nullable("timezone", req.Timezone, 64); ok {
+		if v != nil && !validTimezone(*v) {
+			errs.add("timezone", "is not a valid IANA timezone")
+		}
+		u.Timezone = v
+		set("timezone", v)
+	}
+
+	// sem contrato informado na criação o padrão é CLT
+	u.Contract = models.ContractCLT
+	if req.Contract.Set {
+		if v, ok := required("contract_type", req.Contract, 30); ok {
+			u.Contract = strings.ToLower(v)
+			set("contract_type", u.Contract)
+		}
+	}
+
+	for _, f := range []struct {
+		name   string
+		o      optional[string]
+		target **string
+	}{{"cpf", req.CPF, &u.CPF}, {"pis", req.PIS, &u.PIS}, {"matricula", req.Matricula, &u.Matricula}} {
+		v, ok := nullable(f.name, f.o, 30)
+		if !ok {
+			continue
+		}
+		if v != nil {
+			d, valid := identifierNormalizers[f.name](*v)
+			if !valid {
+				errs.add(f.name, "is not a valid "+f.name)
+				continue
+			}
+			v = &d
+		}
+		*f.target = v
+		set(f.name, v)
+	}
+
+	return u, cols, errs
+}
+
+// checkSetorExists acrescenta o erro de campo se o setor informado não existe.
+func checkSetorExists(ctx context.Context, database *db.Database, errs *fieldErrors, setorID *string) error {
+	if setorID == nil {
+		return nil
+	}
+	var exists bool
+	err := database.Pool().QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM setores WHERE setor_id = $1 AND deleted_at IS NULL)
+	`, *setorID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		errs.add("setor_id", "setor not found")
+	}
+	return nil
+}
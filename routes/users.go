@@ -5,11 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -19,30 +17,46 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
 	"github.com/Rafhael-Viana/m/models" // ajuste conforme o seu path real
+	"github.com/Rafhael-Viana/m/storage"
+	"github.com/Rafhael-Viana/m/webhooks"
 )
 
 // --- CREATE ---
 func CreateUser(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var u models.User
-		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		if !requirePrivileged(w, r) {
 			return
 		}
-		defer r.Body.Close()
 
-		if u.Name == "" || u.Senha == "" || u.Username == "" || u.Email == "" {
-			http.Error(w, "name, senha, email, username are required", http.StatusBadRequest)
+		var req userRequest
+		if !decodeBody(w, r, &req) {
 			return
 		}
 
-		if u.Status == "" {
-			u.Status = models.StatusActive
-		}
+		u, cols, errs := req.validate(true)
+		checkRoleGrant(r, &errs, u.Role)
 
-		if !u.IsValidStatus(u.Status) {
-			http.Error(w, "invalid status", http.StatusBadRequest)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		if err := checkSetorExists(ctx, database, &errs, u.Setor_ID); err != nil {
+			log.Println("DB error checking setor:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if err := resolveUserCargo(ctx, database, req, &u, &cols, &errs); err != nil {
+			log.Println("DB error resolving cargo:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if err := checkContractType(ctx, database, &errs, u.Contract); err != nil {
+			log.Println("DB error checking contract type:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if errs.write(w) {
 			return
 		}
 
@@ -54,19 +68,25 @@ func CreateUser(database *db.Database) http.HandlerFunc {
 
 		u.User_ID = uuid.NewString()
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
 		query := `
 			INSERT INTO users (
 				name, senha, email, username, user_id,
-				setor, cargo, nascimento, status, role
+				setor, cargo, nascimento, status, role,
+				setor_id, timezone, cpf, pis, matricula, admissao,
+				contract_type, cargo_id
 			)
-			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)
 			RETURNING id
 		`
 
-		err = database.Pool().QueryRow(
+		tx, err := database.Pool().Begin(ctx)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		err = tx.QueryRow(
 			ctx,
 			query,
 			u.Name,
@@ -79,14 +99,37 @@ func CreateUser(database *db.Database) http.HandlerFunc {
 			u.Nascimento,
 			u.Status,
 			u.Role,
+			u.Setor_ID,
+			u.Timezone,
+			u.CPF,
+			u.PIS,
+			u.Matricula,
+			u.Admissao,
+			u.Contract,
+			u.CargoID,
 		).Scan(&u.ID)
 
+		if writeDBError(w, err) {
+			return
+		}
 		if err != nil {
 			log.Println("DB error:", err)
 			http.Error(w, "could not insert user", http.StatusInternalServerError)
 			return
 		}
 
+		// primeiro período do histórico: desde a admissão (ou hoje)
+		if err := recordAssignment(ctx, tx, u.User_ID, u.Admissao, changedBy(r), nil); err != nil {
+			log.Println("DB error recording user history:", err)
+			http.Error(w, "could not insert user", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			log.Println("DB error:", err)
+			http.Error(w, "could not insert user", http.StatusInternalServerError)
+			return
+		}
+
 		if u.Setor_ID != nil {
 			_, err = database.Pool().Exec(ctx, `
 			INSERT INTO setor_funcionarios (setor_id, user_id, total_semana, total_mes, total_extra_mes, faltas, atestado)
@@ -105,16 +148,94 @@ func CreateUser(database *db.Database) http.HandlerFunc {
 		u.Senha = ""
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(u)
+
+		emitWebhook(ctx, database, webhooks.EventUserCreated, u)
 	}
 }
 
 // --- LIST ALL ---
+var userPageSpec = pageSpec{
+	ID: "u.id",
+	Fields: map[string]sortField{
+		"id":       {Expr: "u.id", Cast: "int"},
+		"name":     {Expr: "COALESCE(u.name, '')", Cast: "text"},
+		"username": {Expr: "COALESCE(u.username, '')", Cast: "text"},
+		"email":    {Expr: "COALESCE(u.email, '')", Cast: "text"},
+		"cargo":    {Expr: "COALESCE(u.cargo, '')", Cast: "text"},
+		"status":   {Expr: "COALESCE(u.status, '')", Cast: "text"},
+	},
+	DefaultSort: "id",
+}
+
+// userFilters monta o WHERE dos filtros da listagem (também usado no export).
+func userFilters(q url.Values) (where []string, args []any, argN int) {
+	where = []string{"u.deleted_at IS NULL"}
+	argN = 1
+
+	if v := strings.TrimSpace(q.Get("setor_id")); v != "" {
+		where = append(where, setorFilterSQL(q, "u.setor_id", argN))
+		args = append(args, v)
+		argN++
+	}
+	for _, f := range []string{"status", "role", "cargo", "cargo_id", "contract_type"} {
+		if v := strings.TrimSpace(q.Get(f)); v != "" {
+			where = append(where, fmt.Sprintf("u.%s = $%d", f, argN))
+			args = append(args, v)
+			argN++
+		}
+	}
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		where = append(where, fmt.Sprintf("(u.name ILIKE $%[1]d OR u.username ILIKE $%[1]d OR u.email ILIKE $%[1]d)", argN))
+		args = append(args, "%"+escapeLike(v)+"%")
+		argN++
+	}
+	return where, args, argN
+}
+
+// GET /api/users?setor_id=&include_subsetores=true&status=&role=&cargo=&cargo_id=&contract_type=&q=&sort=-name&limit=&cursor=&include_total=true
 func ListUsers(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		pg, err := parsePage(r, userPageSpec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		where, args, argN := userFilters(r.URL.Query())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		rows, err := database.Pool().Query(ctx, `SELECT id, name, email, username, user_id, setor, cargo, nascimento, status, role, setor_id FROM users ORDER BY id`)
+		var total *int64
+		if pg.Total {
+			var n int64
+			err := database.Pool().QueryRow(ctx, `SELECT COUNT(*) FROM users u WHERE `+strings.Join(where, " AND "), args...).Scan(&n)
+			if err != nil {
+				log.Println("DB error counting users:", err)
+				http.Error(w, "error fetching users", http.StatusInternalServerError)
+				return
+			}
+			total = &n
+		}
+
+		if cond, cargs := pg.Where(argN); cond != "" {
+			where = append(where, cond)
+			args = append(args, cargs...)
+			argN += len(cargs)
+		}
+		args = append(args, pg.Limit+1)
+
+		query := fmt.Sprintf(`
+			SELECT id, name, email, username, user_id, setor, cargo, nascimento, status, role, setor_id, timezone,
+				cpf, pis, matricula, admissao, contract_type, cargo_id,
+				%s
+			FROM users u
+			WHERE %s
+			ORDER BY %s
+			LIMIT $%d
+		`, pg.SortExpr(), strings.Join(where, " AND "), pg.OrderBy(), argN)
+
+		rows, err := database.Pool().Query(ctx, query, args...)
 		if err != nil {
 			log.Println("DB error fetching users:", err) // log no servidor
 			http.Error(w, "error fetching users: ", http.StatusInternalServerError)
@@ -122,19 +243,35 @@ func ListUsers(database *db.Database) http.HandlerFunc {
 		}
 		defer rows.Close()
 
-		var users []models.User
+		roles, _ := mid.RoleFromContext(r.Context())
+
+		users := []models.User{}
+		sortValues := []string{}
 		for rows.Next() {
 			var u models.User
-			err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Username, &u.User_ID, &u.Setor, &u.Cargo, &u.Nascimento, &u.Status, &u.Role, &u.Setor_ID)
+			var sortValue string
+			err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Username, &u.User_ID, &u.Setor, &u.Cargo, &u.Nascimento, &u.Status, &u.Role, &u.Setor_ID, &u.Timezone, &u.CPF, &u.PIS, &u.Matricula, &u.Admissao, &u.Contract, &u.CargoID, &sortValue)
 			if err != nil {
 				http.Error(w, "error fetching users: ", http.StatusInternalServerError)
 				log.Println("DB error fetching users:", err) // log no servidor
+				return
 			}
+			maskUser(&u, roles)
 			users = append(users, u)
+			sortValues = append(sortValues, sortValue)
+		}
+
+		// buscamos uma linha a mais só para saber se existe próxima página
+		res := page[models.User]{Items: users, Total: total}
+		if len(users) > pg.Limit {
+			res.Items = users[:pg.Limit]
+			last := pg.Limit - 1
+			next := pg.Next(sortValues[last], int64(users[last].ID))
+			res.NextCursor = &next
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
+		json.NewEncoder(w).Encode(res)
 	}
 }
 
@@ -148,12 +285,12 @@ func GetUser(database *db.Database) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
 		var u models.User
-		query := `SELECT id, name, email, username, user_id, setor, cargo, nascimento, status, role, setor_id FROM users WHERE id = $1`
-		err = database.Pool().QueryRow(ctx, query, id).Scan(&u.ID, &u.Name, &u.Email, &u.Username, &u.User_ID, &u.Setor, &u.Cargo, &u.Nascimento, &u.Status, &u.Role, &u.Setor_ID)
+		query := `SELECT id, name, email, username, user_id, setor, cargo, nascimento, status, role, setor_id, timezone, cpf, pis, matricula, admissao, contract_type, cargo_id FROM users WHERE id = $1 AND deleted_at IS NULL`
+		err = database.Pool().QueryRow(ctx, query, id).Scan(&u.ID, &u.Name, &u.Email, &u.Username, &u.User_ID, &u.Setor, &u.Cargo, &u.Nascimento, &u.Status, &u.Role, &u.Setor_ID, &u.Timezone, &u.CPF, &u.PIS, &u.Matricula, &u.Admissao, &u.Contract, &u.CargoID)
 		if errors.Is(err, pgx.ErrNoRows) {
 			http.Error(w, "user not found", http.StatusNotFound)
 			return
@@ -163,6 +300,9 @@ func GetUser(database *db.Database) http.HandlerFunc {
 			return
 		}
 
+		roles, _ := mid.RoleFromContext(r.Context())
+		maskUser(&u, roles)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(u)
 	}
@@ -171,62 +311,77 @@ func GetUser(database *db.Database) http.HandlerFunc {
 // --- UPDATE (PATCH) ---
 func UpdateUser(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Extrair o ID da URL
-		idStr := r.PathValue("id")
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			http.Error(w, "invalid id", http.StatusBadRequest)
+		// Parse do corpo da requisição: o DTO valida e o map guarda o que veio,
+		// para o webhook
+		var req userRequest
+		var input map[string]any
+		if !decodeBody(w, r, &req, &input) {
 			return
 		}
 
-		// Parse do corpo da requisição
-		var input map[string]any
-		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		_, cols, errs := req.validate(false)
+		if req.Role.Set {
+			checkRoleGrant(r, &errs, strings.TrimSpace(req.Role.Value))
+		}
+		checkPrivilegedFields(r, &errs, cols)
+
+		// data a partir da qual a mudança de setor/cargo/status vale (padrão hoje)
+		effective := checkDate(&errs, "effective_date", req.EffectiveDate, true)
+		var reason *string
+		if req.ChangeReason.ok(&errs, "change_reason", true) {
+			reason = nullIfEmpty(strings.TrimSpace(emptyIfNull(req.ChangeReason.Ptr())))
+			if len(emptyIfNull(reason)) > 500 {
+				errs.add("change_reason", "is too long")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		// admin/RH ou o líder do setor do funcionário
+		id, _, ok := pathUser(w, r, ctx, database, true)
+		if !ok {
+			return
+		}
+
+		if req.SetorID.Set {
+			if err := checkSetorExists(ctx, database, &errs, nullIfEmpty(strings.TrimSpace(emptyIfNull(req.SetorID.Ptr())))); err != nil {
+				log.Println("DB error checking setor:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := resolveUserCargo(ctx, database, req, &models.User{}, &cols, &errs); err != nil {
+			log.Println("DB error resolving cargo:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if req.Contract.Set && len(errs) == 0 {
+			if err := checkContractType(ctx, database, &errs, strings.ToLower(strings.TrimSpace(req.Contract.Value))); err != nil {
+				log.Println("DB error checking contract type:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if errs.write(w) {
 			return
 		}
-		defer r.Body.Close()
 
 		// Campos e valores para o update
 		fields := []string{}
 		values := []any{}
-		i := 1
-
-		// Criar uma instância de User para validar o status
-		var u models.User
-
-		// Itera sobre os campos do input para verificar as mudanças
-		for key, value := range input {
-			fmt.Println("key: ", key)
-			switch key {
-			case "name", "setor", "setor_id", "cargo", "role":
-				fields = append(fields, fmt.Sprintf("%s = $%d", key, i))
-				values = append(values, value)
-				i++
-
-			case "senha":
-				hashed, _ := bcrypt.GenerateFromPassword([]byte(value.(string)), bcrypt.DefaultCost)
-				fields = append(fields, fmt.Sprintf("senha = $%d", i))
-				values = append(values, string(hashed))
-				i++
-
-			case "status":
-				// Valida o status com o método da struct
-				status := models.StatusUser(value.(string))
-				u.Status = status
-				if !u.IsValidStatus(status) {
-					http.Error(w, "invalid status", http.StatusBadRequest)
+		for _, c := range cols {
+			value := c.Value
+			if c.Name == "senha" {
+				hashed, err := bcrypt.GenerateFromPassword([]byte(c.Value.(string)), bcrypt.DefaultCost)
+				if err != nil {
+					http.Error(w, "error hashing password", http.StatusInternalServerError)
 					return
 				}
-				fields = append(fields, fmt.Sprintf("status = $%d", i))
-				values = append(values, status)
-				i++
-
-			case "birth":
-				fields = append(fields, fmt.Sprintf("nascimento = $%d", i))
-				values = append(values, value)
-				i++
+				value = string(hashed)
 			}
+			values = append(values, value)
+			fields = append(fields, fmt.Sprintf("%s = $%d", c.Name, len(values)))
 		}
 
 		if len(fields) == 0 {
@@ -237,9 +392,17 @@ func UpdateUser(database *db.Database) http.HandlerFunc {
 		// Adicionar o ID como valor para o WHERE
 		values = append(values, id)
 
-		// Criar a query dinâmica
-		query := fmt.Sprintf(
-			`UPDATE users SET %s WHERE id = $%d`,
+		// Criar a query dinâmica. O CTE guarda setor/cargo/status de antes do
+		// UPDATE para saber se o funcionário foi desativado, mudou de setor ou
+		// se o histórico de vínculo precisa de um período novo.
+		query := fmt.Sprintf(`
+			WITH old AS (SELECT id, setor_id, cargo, status, contract_type FROM users WHERE id = $%[2]d AND deleted_at IS NULL)
+			UPDATE users SET %[1]s
+			FROM old
+			WHERE users.id = old.id
+			RETURNING users.user_id, old.setor_id, users.setor_id, old.cargo, users.cargo, old.status, users.status,
+				old.contract_type, users.contract_type
+		`,
 			strings.Join(fields, ", "),
 			len(values),
 		)
@@ -248,19 +411,50 @@ func UpdateUser(database *db.Database) http.HandlerFunc {
 		// fmt.Printf("VALUES: %#v\n", values)
 
 		// Executar a query
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		var (
+			userID             string
+			oldSetor, newSetor *string
+			oldCargo, newCargo *string
+			oldStatus          models.StatusUser
+			newStatus          models.StatusUser
+			oldContract        string
+			newContract        string
+		)
 
-		cmd, err := database.Pool().Exec(ctx, query, values...)
-		if err != nil || cmd.RowsAffected() == 0 {
+		tx, err := database.Pool().Begin(ctx)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		err = tx.QueryRow(ctx, query, values...).Scan(&userID, &oldSetor, &newSetor, &oldCargo, &newCargo, &oldStatus, &newStatus, &oldContract, &newContract)
+		if writeDBError(w, err) {
+			return
+		}
+		if err != nil {
 			http.Error(w, "user not found or not updated", http.StatusNotFound)
 			fmt.Printf("Error: %s", err)
 			return
 		}
 
+		if emptyIfNull(oldSetor) != emptyIfNull(newSetor) || emptyIfNull(oldCargo) != emptyIfNull(newCargo) ||
+			oldStatus != newStatus || oldContract != newContract {
+			if err := recordAssignment(ctx, tx, userID, effective, changedBy(r), reason); err != nil {
+				log.Println("DB error recording user history:", err)
+				http.Error(w, "could not record user history", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
 		// depois do UPDATE, se input tinha "setor_id":
-		if newSetorIDRaw, ok := input["setor_id"]; ok {
-			newSetorID := fmt.Sprint(newSetorIDRaw)
+		if req.SetorID.Set {
+			newSetorID := strings.TrimSpace(emptyIfNull(req.SetorID.Ptr()))
 
 			var userUUID string
 			err := database.Pool().QueryRow(ctx, `SELECT user_id FROM users WHERE id = $1`, id).Scan(&userUUID)
@@ -288,12 +482,44 @@ func UpdateUser(database *db.Database) http.HandlerFunc {
 
 		// Retornar resposta de sucesso
 		json.NewEncoder(w).Encode(map[string]string{"status": "updated"})
+
+		// senha nunca vai para fora
+		changes := map[string]any{}
+		for key, value := range input {
+			if key != "senha" {
+				changes[key] = value
+			}
+		}
+
+		emitWebhook(ctx, database, webhooks.EventUserUpdated, map[string]any{
+			"user_id": userID,
+			"changes": changes,
+		})
+
+		if oldStatus != newStatus && newStatus == models.StatusInactive {
+			emitWebhook(ctx, database, webhooks.EventUserDeactivated, map[string]any{
+				"user_id":         userID,
+				"previous_status": oldStatus,
+			})
+		}
+
+		if emptyIfNull(oldSetor) != emptyIfNull(newSetor) {
+			emitWebhook(ctx, database, webhooks.EventUserMoved, map[string]any{
+				"user_id":       userID,
+				"from_setor_id": oldSetor,
+				"to_setor_id":   newSetor,
+			})
+		}
 	}
 }
 
 // --- DELETE ---
 func DeleteUser(database *db.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !requirePrivileged(w, r) {
+			return
+		}
+
 		idStr := r.PathValue("id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
@@ -301,87 +527,99 @@ func DeleteUser(database *db.Database) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		cmd, err := database.Pool().Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
-		if err != nil {
-			http.Error(w, "error deleting user", http.StatusInternalServerError)
+		var userID string
+		// Exclusão lógica: o usuário vai para a lixeira e pode ser restaurado.
+		err = database.Pool().QueryRow(ctx, `
+			UPDATE users
+			SET deleted_at = now(), deleted_by = $2, delete_reason = $3
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING user_id
+		`, id, deletedBy(r), deleteReason(r)).Scan(&userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "user not found", http.StatusNotFound)
 			return
 		}
 
-		if cmd.RowsAffected() == 0 {
-			http.Error(w, "user not found", http.StatusNotFound)
+		if err != nil {
+			http.Error(w, "error deleting user", http.StatusInternalServerError)
 			return
 		}
 
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+
+		emitWebhook(ctx, database, webhooks.EventUserDeleted, map[string]any{
+			"user_id": userID,
+		})
 	}
 }
 
 // --- UPLOAD FILE ---
-func UploadUserFile(w http.ResponseWriter, r *http.Request) {
-	userID := r.PathValue("id")
-	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
-		return
-	}
-
-	err := r.ParseMultipartForm(10 << 20) // 10MB
-	if err != nil {
-		http.Error(w, "File too large", http.StatusBadRequest)
-		return
-	}
+// POST /api/users/{id}/upload (multipart: file, category?, expires_on?)
+//
+// O {id} precisa ser de um usuário existente; cada arquivo enviado fica
+// registrado em user_documents.
+func UploadUserFile(database *db.Database, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
 
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "Invalid file", http.StatusBadRequest)
-		return
-	}
-	defer file.Close()
+		id, userID, ok := pathUser(w, r, ctx, database, false)
+		if !ok {
+			return
+		}
 
-	allowed := map[string]string{
-		"image/png":       "images",
-		"image/jpeg":      "images",
-		"audio/mpeg":      "audios",
-		"application/pdf": "docs",
-	}
+		upload, ok := readDocumentUpload(w, r)
+		if !ok {
+			return
+		}
+		category, expires, errs := upload.validate()
+		if errs.write(w) {
+			return
+		}
 
-	contentType := handler.Header.Get("Content-Type")
-	subDir, ok := allowed[contentType]
-	if !ok {
-		http.Error(w, "File type not allowed", http.StatusForbidden)
-		return
-	}
+		// uploads/{id}/{images|audios|docs}
+		doc, ok := upload.save(w, ctx, store, strconv.Itoa(id))
+		if !ok {
+			return
+		}
 
-	// uploads/{user_id}/{images|audios|docs}
-	userDir := filepath.Join("uploads", userID, subDir)
-	if err := os.MkdirAll(userDir, 0755); err != nil {
-		http.Error(w, "Failed to create user directory", http.StatusInternalServerError)
-		return
-	}
+		uploadedBy, _ := mid.UserIDFromContext(r.Context())
 
-	ext := filepath.Ext(handler.Filename)
-	filename := uuid.New().String() + ext
+		err := scanDocument(database.Pool().QueryRow(ctx, `
+			INSERT INTO user_documents AS d
+				(user_id, category, filename, object_key, thumb_key, content_type, size_bytes, sha256, expires_on, uploaded_by, taken_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING `+documentColumns,
+			userID, category, doc.Filename, doc.ObjectKey, doc.ThumbKey, doc.ContentType, doc.Size, doc.SHA256, expires, nullIfEmpty(uploadedBy), doc.TakenAt,
+		), &doc)
+		if err != nil {
+			deleteDocumentObjects(ctx, store, doc.ObjectKey, doc.ThumbKey)
+			if writeDBError(w, err) {
+				return
+			}
+			log.Println("DB error saving document:", err)
+			http.Error(w, "Failed to save file", http.StatusInternalServerError)
+			return
+		}
 
-	fullPath := filepath.Join(userDir, filename)
+		response := map[string]any{
+			"url":          storage.URL(doc.ObjectKey),
+			"content_type": upload.contentType,
+		}
+		if doc.ThumbKey != nil {
+			response["thumb_url"] = storage.URL(*doc.ThumbKey)
+		}
+		if upload.subDir == "images" {
+			response["taken_at"] = doc.TakenAt
+		}
 
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		http.Error(w, "Failed to save file", http.StatusInternalServerError)
-		return
-	}
-	defer dst.Close()
+		withDocumentURLs(&doc)
+		response["document"] = doc
 
-	if _, err := io.Copy(dst, file); err != nil {
-		http.Error(w, "Failed to write file", http.StatusInternalServerError)
-		return
+		writeJSON(w, http.StatusCreated, response)
 	}
-
-	fileURL := fmt.Sprintf("/uploads/%s/%s/%s", userID, subDir, filename)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintf(w, `{"url":"%s"}`, fileURL)
 }
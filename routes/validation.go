@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// limite do corpo JSON aceito pelos handlers que usam decodeBody
+const maxJSONBody = 1 << 20
+
+// FieldError aponta um campo inválido e o motivo.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+type fieldErrors []FieldError
+
+func (e *fieldErrors) add(field, reason string) {
+	*e = append(*e, FieldError{Field: field, Reason: reason})
+}
+
+// write responde {"error": ..., "fields": [...]} e devolve true se havia erros.
+func (e fieldErrors) write(w http.ResponseWriter) bool {
+	if len(e) == 0 {
+		return false
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]any{
+		"error":  "validation failed",
+		"fields": e,
+	})
+	return true
+}
+
+// optional guarda um campo do corpo JSON distinguindo ausente, null e valor.
+// Um valor do tipo errado não derruba o decode: fica marcado para a validação
+// listar junto com os outros campos.
+type optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+	wrong bool
+}
+
+func (o *optional[T]) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if string(b) == "null" {
+		o.Null = true
+		return nil
+	}
+	if err := json.Unmarshal(b, &o.Value); err != nil {
+		o.wrong = true
+	}
+	return nil
+}
+
+// ok diz se o campo veio com um valor utilizável; registra o erro de tipo
+// (e de null, quando o campo não aceita null).
+func (o optional[T]) ok(errs *fieldErrors, field string, nullable bool) bool {
+	switch {
+	case !o.Set:
+		return false
+	case o.wrong:
+		errs.add(field, "must be "+jsonTypeName(reflect.TypeFor[T]()))
+		return false
+	case o.Null && !nullable:
+		errs.add(field, "cannot be null")
+		return false
+	}
+	return true
+}
+
+// Ptr devolve nil para null e o valor caso contrário.
+func (o optional[T]) Ptr() *T {
+	if o.Null {
+		return nil
+	}
+	v := o.Value
+	return &v
+}
+
+// jsonTypeName descreve o tipo esperado na mensagem de erro ("an integer").
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a valid " + t.String()
+}
+
+// decodeBody lê o corpo JSON para cada destino (o DTO e, se preciso, um map
+// com os dados crus). Escreve o 400 e devolve false se o JSON for inválido.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst ...any) bool {
+	defer r.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBody))
+	if err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return false
+	}
+	for _, d := range dst {
+		err := json.Unmarshal(body, d)
+		// tipo errado num DTO comum (sem optional) vira erro de campo
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			fieldErrors{{Field: typeErr.Field, Reason: "must be " + jsonTypeName(typeErr.Type)}}.write(w)
+			return false
+		}
+		if err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// validEmail aceita só o endereço puro (sem nome, sem <>).
+func validEmail(s string) bool {
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+}
+
+// parseDate aceita YYYY-MM-DD ou RFC3339 (como o front manda hoje).
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// checkDate valida uma data opcional (null ou "" viram nil); past exige que
+// não seja futura.
+func checkDate(errs *fieldErrors, field string, o optional[string], past bool) *time.Time {
+	if !o.ok(errs, field, true) || o.Null || o.Value == "" {
+		return nil
+	}
+	t, err := parseDate(o.Value)
+	if err != nil {
+		errs.add(field, "must be a date (YYYY-MM-DD)")
+		return nil
+	}
+	if past && t.After(time.Now()) {
+		errs.add(field, "cannot be in the future")
+		return nil
+	}
+	return &t
+}
+
+// constraintField tenta descobrir o campo a partir do erro do Postgres: a
+// coluna, quando vem, ou o nome da constraint sem tabela e sufixo
+// (users_email_key -> email).
+func constraintField(pgErr *pgconn.PgError) string {
+	if pgErr.ColumnName != "" {
+		return pgErr.ColumnName
+	}
+	name := strings.TrimPrefix(pgErr.ConstraintName, pgErr.TableName+"_")
+	for _, suffix := range []string{"_key", "_idx", "_fkey", "_check", "_pkey"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return name
+}
+
+// writeDBError transforma violações de constraint em 409/400 com o campo
+// envolvido. Devolve false se o erro não for desse tipo (o chamador segue com
+// o tratamento dele).
+func writeDBError(w http.ResponseWriter, err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	status := http.StatusBadRequest
+	var reason string
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		status, reason = http.StatusConflict, "already in use"
+	case "23503": // foreign_key_violation
+		status, reason = http.StatusConflict, "references a record that does not exist or is in use"
+	case "23502": // not_null_violation
+		reason = "is required"
+	case "23514": // check_violation
+		reason = "is not an allowed value"
+	case "22001": // string_data_right_truncation
+		reason = "is too long"
+	case "22P02", "22007", "22008": // formato inválido de número/data
+		reason = "has an invalid format"
+	default:
+		return false
+	}
+
+	field := constraintField(pgErr)
+	if field == "" {
+		field = "input"
+	}
+	writeJSON(w, status, map[string]any{
+		"error":  http.StatusText(status),
+		"fields": fieldErrors{{Field: field, Reason: reason}},
+	})
+	return true
+}
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestOptionalDecode(t *testing.T) {
+	type body struct {
+		Name  optional[string] `json:"name"`
+		Count optional[int]    `json:"count"`
+	}
+
+	tests := []struct {
+		name     string
+		json     string
+		nullable bool
+		wantOK   bool
+		want     optional[string]
+		wantErrs fieldErrors
+	}{
+		{"absent", `{}`, false, false, optional[string]{}, nil},
+		{"value", `{"name":"Ana"}`, false, true, optional[string]{Set: true, Value: "Ana"}, nil},
+		{"empty string", `{"name":""}`, false, true, optional[string]{Set: true}, nil},
+		{"null allowed", `{"name":null}`, true, true, optional[string]{Set: true, Null: true}, nil},
+		{"null refused", `{"name":null}`, false, false, optional[string]{Set: true, Null: true},
+			fieldErrors{{Field: "name", Reason: "cannot be null"}}},
+		{"wrong type", `{"name":42}`, true, false, optional[string]{Set: true, wrong: true},
+			fieldErrors{{Field: "name", Reason: "must be a string"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b body
+			if err := json.Unmarshal([]byte(tt.json), &b); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if b.Name != tt.want {
+				t.Errorf("decoded = %+v, want %+v", b.Name, tt.want)
+			}
+
+			var errs fieldErrors
+			if got := b.Name.ok(&errs, "name", tt.nullable); got != tt.wantOK {
+				t.Errorf("ok = %v, want %v", got, tt.wantOK)
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("errors = %+v, want %+v", errs, tt.wantErrs)
+			}
+		})
+	}
+
+	// o tipo errado de um campo não impede o decode dos outros
+	var b body
+	if err := json.Unmarshal([]byte(`{"name":"Ana","count":"dez"}`), &b); err != nil {
+		t.Fatal(err)
+	}
+	var errs fieldErrors
+	b.Name.ok(&errs, "name", false)
+	b.Count.ok(&errs, "count", false)
+	if want := (fieldErrors{{Field: "count", Reason: "must be an integer"}}); !reflect.DeepEqual(errs, want) {
+		t.Errorf("errors = %+v, want %+v", errs, want)
+	}
+}
+
+func TestOptionalPtr(t *testing.T) {
+	if p := (optional[string]{Set: true, Null: true}).Ptr(); p != nil {
+		t.Errorf("Ptr of null = %v, want nil", *p)
+	}
+	if p := (optional[string]{Set: true, Value: "x"}).Ptr(); p == nil || *p != "x" {
+		t.Errorf("Ptr = %v, want x", p)
+	}
+}
+
+func TestWriteDBError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		handled    bool
+		wantStatus int
+		wantField  string
+		wantReason string
+	}{
+		{"unique", &pgconn.PgError{Code: "23505", TableName: "users", ConstraintName: "users_email_key"},
+			true, http.StatusConflict, "email", "already in use"},
+		{"unique wrapped", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505", TableName: "cargos", ConstraintName: "cargos_nome_key"}),
+			true, http.StatusConflict, "nome", "already in use"},
+		{"foreign key", &pgconn.PgError{Code: "23503", TableName: "users", ConstraintName: "users_setor_id_fkey"},
+			true, http.StatusConflict, "setor_id", "references a record that does not exist or is in use"},
+		{"not null with column", &pgconn.PgError{Code: "23502", TableName: "users", ColumnName: "name"},
+			true, http.StatusBadRequest, "name", "is required"},
+		{"check", &pgconn.PgError{Code: "23514", TableName: "tenants", ConstraintName: "tenants_status_check"},
+			true, http.StatusBadRequest, "status", "is not an allowed value"},
+		{"no constraint name", &pgconn.PgError{Code: "22001"},
+			true, http.StatusBadRequest, "input", "is too long"},
+		{"other postgres error", &pgconn.PgError{Code: "40001"}, false, 0, "", ""},
+		{"not a postgres error", errors.New("boom"), false, 0, "", ""},
+		{"nil", nil, false, 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if got := writeDBError(w, tt.err); got != tt.handled {
+				t.Fatalf("writeDBError = %v, want %v", got, tt.handled)
+			}
+			if !tt.handled {
+				if w.Body.Len() != 0 {
+					t.Errorf("wrote %q for an unhandled error", w.Body.String())
+				}
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			var resp struct {
+				Fields fieldErrors `json:"fields"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			want := fieldErrors{{Field: tt.wantField, Reason: tt.wantReason}}
+			if !reflect.DeepEqual(resp.Fields, want) {
+				t.Errorf("fields = %+v, want %+v", resp.Fields, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,541 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+	mid "github.com/Rafhael-Viana/m/middlewares"
+	"github.com/Rafhael-Viana/m/models"
+	"github.com/Rafhael-Viana/m/webhooks"
+)
+
+// emitWebhook enfileira o evento para os assinantes. Erro só é logado: a
+// operação principal já foi feita e não deve falhar por causa do webhook.
+func emitWebhook(ctx context.Context, database *db.Database, event string, data any) {
+	if err := webhooks.Emit(ctx, database, event, data); err != nil {
+		log.Println("DB error enqueueing webhook "+event+":", err)
+	}
+}
+
+// checkWebhookURL recusa URLs que não sejam http(s) ou que apontem para a
+// rede interna; a mensagem do erro vai na resposta.
+func checkWebhookURL(ctx context.Context, raw string) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	return webhooks.CheckURL(ctx, raw)
+}
+
+func validWebhookEvents(events []string) bool {
+	if len(events) == 0 {
+		return false
+	}
+	for _, e := range events {
+		if !webhooks.ValidEvent(e) {
+			return false
+		}
+	}
+	return true
+}
+
+const webhookColumns = `id, url, events, description, active, created_by, created_at, updated_at`
+
+func scanWebhook(row pgx.Row, s *models.WebhookSubscription) error {
+	return row.Scan(&s.ID, &s.URL, &s.Events, &s.Description, &s.Active, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// GET /api/webhooks/events
+func ListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, webhooks.Events)
+}
+
+// --- CREATE ---
+// POST /api/webhooks
+func CreateWebhook(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			URL         string   `json:"url"`
+			Events      []string `json:"events"`
+			Description *string  `json:"description"`
+			Secret      string   `json:"secret"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := checkWebhookURL(r.Context(), input.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !validWebhookEvents(input.Events) {
+			http.Error(w, "invalid events", http.StatusBadRequest)
+			return
+		}
+		if input.Secret == "" {
+			input.Secret = webhooks.NewSecret()
+		}
+
+		callerID, _ := mid.UserIDFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var s models.WebhookSubscription
+		err := scanWebhook(database.Pool().QueryRow(ctx, `
+			INSERT INTO webhook_subscriptions (id, url, secret, events, description, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+webhookColumns,
+			uuid.NewString(), input.URL, input.Secret, input.Events, input.Description, nullIfEmpty(callerID),
+		), &s)
+		if err != nil {
+			log.Println("DB error creating webhook:", err)
+			http.Error(w, "could not create webhook", http.StatusInternalServerError)
+			return
+		}
+
+		// o segredo só é mostrado aqui; depois só trocando
+		s.Secret = input.Secret
+		writeJSON(w, http.StatusCreated, s)
+	}
+}
+
+// GET /api/webhooks
+func ListWebhooks(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+webhookColumns+`
+			FROM webhook_subscriptions
+			ORDER BY created_at
+		`)
+		if err != nil {
+			log.Println("DB error fetching webhooks:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		subs := []models.WebhookSubscription{}
+		for rows.Next() {
+			var s models.WebhookSubscription
+			if err := scanWebhook(rows, &s); err != nil {
+				log.Println("DB error scanning webhook:", err)
+				http.Error(w, "scan error", http.StatusInternalServerError)
+				return
+			}
+			subs = append(subs, s)
+		}
+
+		writeJSON(w, http.StatusOK, subs)
+	}
+}
+
+// GET /api/webhooks/{id}
+func GetWebhook(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var s models.WebhookSubscription
+		err = scanWebhook(database.Pool().QueryRow(ctx, `
+			SELECT `+webhookColumns+` FROM webhook_subscriptions WHERE id = $1
+		`, id), &s)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching webhook:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// PATCH /api/webhooks/{id}
+//
+// "rotate_secret": true gera um segredo novo, devolvido na resposta.
+func UpdateWebhook(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var input map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		defer r.Body.Close()
+
+		fields := []string{}
+		values := []any{}
+		i := 1
+		newSecret := ""
+
+		for key, value := range input {
+			switch key {
+			case "url":
+				u, _ := value.(string)
+				if err := checkWebhookURL(r.Context(), u); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				fields = append(fields, fmt.Sprintf("url = $%d", i))
+				values = append(values, u)
+				i++
+
+			case "events":
+				raw, _ := value.([]any)
+				events := make([]string, 0, len(raw))
+				for _, e := range raw {
+					s, _ := e.(string)
+					events = append(events, s)
+				}
+				if !validWebhookEvents(events) {
+					http.Error(w, "invalid events", http.StatusBadRequest)
+					return
+				}
+				fields = append(fields, fmt.Sprintf("events = $%d", i))
+				values = append(values, events)
+				i++
+
+			case "description":
+				d, _ := value.(string)
+				fields = append(fields, fmt.Sprintf("description = $%d", i))
+				values = append(values, nullIfEmpty(d))
+				i++
+
+			case "active":
+				active, ok := value.(bool)
+				if !ok {
+					http.Error(w, "invalid active", http.StatusBadRequest)
+					return
+				}
+				fields = append(fields, fmt.Sprintf("active = $%d", i))
+				values = append(values, active)
+				i++
+
+			case "rotate_secret":
+				if rotate, _ := value.(bool); rotate {
+					newSecret = webhooks.NewSecret()
+					fields = append(fields, fmt.Sprintf("secret = $%d", i))
+					values = append(values, newSecret)
+					i++
+				}
+			}
+		}
+
+		if len(fields) == 0 {
+			http.Error(w, "no valid fields to update", http.StatusBadRequest)
+			return
+		}
+
+		values = append(values, id)
+
+		query := fmt.Sprintf(`
+			UPDATE webhook_subscriptions
+			SET %s, updated_at = now()
+			WHERE id = $%d
+			RETURNING %s
+		`, strings.Join(fields, ", "), len(values), webhookColumns)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		tx, err := database.Pool().Begin(ctx)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		var s models.WebhookSubscription
+		err = scanWebhook(tx.QueryRow(ctx, query, values...), &s)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error updating webhook:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		// desativada, as entregas pendentes são canceladas; dá para reenviar
+		// pelo redeliver depois de reativar
+		if !s.Active {
+			_, err := tx.Exec(ctx, `
+				UPDATE webhook_deliveries
+				SET status = 'failed', last_error = 'subscription disabled'
+				WHERE subscription_id = $1 AND status = 'pending'
+			`, id)
+			if err != nil {
+				log.Println("DB error canceling webhook deliveries:", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		s.Secret = newSecret
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// DELETE /api/webhooks/{id} — o log de entregas vai junto
+func DeleteWebhook(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		cmd, err := database.Pool().Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+		if err != nil {
+			log.Println("DB error deleting webhook:", err)
+			http.Error(w, "error deleting webhook", http.StatusInternalServerError)
+			return
+		}
+
+		if cmd.RowsAffected() == 0 {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	}
+}
+
+// POST /api/webhooks/{id}/ping — manda um evento de teste para a assinatura
+func PingWebhook(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		deliveryID, err := webhooks.EmitTo(ctx, database, id.String(), webhooks.EventPing, map[string]string{
+			"subscription_id": id.String(),
+		})
+		if err != nil {
+			// FK violada = assinatura não existe
+			log.Println("DB error enqueueing webhook ping:", err)
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, map[string]any{"delivery_id": deliveryID})
+	}
+}
+
+const deliveryColumns = `
+	id, subscription_id, event, event_id, status, attempts,
+	next_attempt_at, last_attempt_at, response_status, last_error,
+	redelivery_of, created_at, delivered_at
+`
+
+func scanDelivery(row pgx.Row, d *models.WebhookDelivery) error {
+	err := row.Scan(
+		&d.ID, &d.SubscriptionID, &d.Event, &d.EventID, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.LastError,
+		&d.RedeliveryOf, &d.CreatedAt, &d.DeliveredAt,
+	)
+	// next_attempt_at só faz sentido enquanto a entrega está pendente
+	if err == nil && d.Status != "pending" {
+		d.NextAttemptAt = nil
+	}
+	return err
+}
+
+// GET /api/webhooks/{id}/deliveries?status=failed&limit=50&before=<id>
+func ListWebhookDeliveries(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+
+		limit := 50
+		if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+
+		var before *int64
+		if v := q.Get("before"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid before", http.StatusBadRequest)
+				return
+			}
+			before = &n
+		}
+
+		status := q.Get("status")
+		if status != "" && status != "pending" && status != "succeeded" && status != "failed" {
+			http.Error(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT `+deliveryColumns+`
+			FROM webhook_deliveries
+			WHERE subscription_id = $1
+			  AND ($2 = '' OR status = $2)
+			  AND ($3::bigint IS NULL OR id < $3)
+			ORDER BY id DESC
+			LIMIT $4
+		`, id, status, before, limit)
+		if err != nil {
+			log.Println("DB error fetching webhook deliveries:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		deliveries := []models.WebhookDelivery{}
+		for rows.Next() {
+			var d models.WebhookDelivery
+			if err := scanDelivery(rows, &d); err != nil {
+				log.Println("DB error scanning webhook delivery:", err)
+				http.Error(w, "scan error", http.StatusInternalServerError)
+				return
+			}
+			deliveries = append(deliveries, d)
+		}
+
+		writeJSON(w, http.StatusOK, deliveries)
+	}
+}
+
+// GET /api/webhook-deliveries/{id} — payload e log de tentativas
+func GetWebhookDelivery(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		var d models.WebhookDelivery
+		err = scanDelivery(database.Pool().QueryRow(ctx, `
+			SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1
+		`, id), &d)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "delivery not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Println("DB error fetching webhook delivery:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		var payload string
+		if err := database.Pool().QueryRow(ctx, `
+			SELECT payload::text FROM webhook_deliveries WHERE id = $1
+		`, id).Scan(&payload); err != nil {
+			log.Println("DB error fetching webhook payload:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		d.Payload = json.RawMessage(payload)
+
+		rows, err := database.Pool().Query(ctx, `
+			SELECT attempt, attempted_at, response_status, response_body, error, duration_ms
+			FROM webhook_delivery_attempts
+			WHERE delivery_id = $1
+			ORDER BY attempt
+		`, id)
+		if err != nil {
+			log.Println("DB error fetching webhook attempts:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		d.AttemptLog = []models.WebhookAttempt{}
+		for rows.Next() {
+			var a models.WebhookAttempt
+			if err := rows.Scan(&a.Attempt, &a.AttemptedAt, &a.ResponseStatus, &a.ResponseBody, &a.Error, &a.DurationMS); err != nil {
+				log.Println("DB error scanning webhook attempt:", err)
+				http.Error(w, "scan error", http.StatusInternalServerError)
+				return
+			}
+			d.AttemptLog = append(d.AttemptLog, a)
+		}
+
+		writeJSON(w, http.StatusOK, d)
+	}
+}
+
+// POST /api/webhook-deliveries/{id}/redeliver
+func RedeliverWebhook(database *db.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		newID, err := webhooks.Redeliver(ctx, database, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "delivery not found", http.StatusNotFound)
+			return
+		} else if errors.Is(err, webhooks.ErrSubscriptionInactive) {
+			http.Error(w, "webhook is inactive", http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Println("DB error redelivering webhook:", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusAccepted, map[string]any{"delivery_id": newID, "redelivery_of": id})
+	}
+}
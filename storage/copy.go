@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// CopyStats resume uma execução de Copy.
+type CopyStats struct {
+	Copied  int
+	Skipped int
+	Failed  int
+	Bytes   int64
+}
+
+// Copy copia todos os objetos de src com o prefixo informado para dst.
+// Objetos que já existem no destino com o mesmo tamanho são pulados, a menos
+// que overwrite seja true. Erros por objeto são reportados em onError e não
+// interrompem a cópia.
+func Copy(ctx context.Context, src, dst Storage, prefix string, overwrite, dryRun bool, onError func(key string, err error)) (CopyStats, error) {
+	var stats CopyStats
+
+	err := src.List(ctx, prefix, func(info Info) error {
+		if !overwrite {
+			existing, err := dst.Stat(ctx, info.Key)
+			if err == nil && existing.Size == info.Size {
+				stats.Skipped++
+				return nil
+			}
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				stats.Failed++
+				onError(info.Key, err)
+				return nil
+			}
+		}
+
+		if dryRun {
+			stats.Copied++
+			stats.Bytes += info.Size
+			return nil
+		}
+
+		if err := copyObject(ctx, src, dst, info.Key); err != nil {
+			stats.Failed++
+			onError(info.Key, err)
+			return nil
+		}
+
+		stats.Copied++
+		stats.Bytes += info.Size
+		return nil
+	})
+
+	return stats, err
+}
+
+// copyObject passa o objeto de src para dst em stream, sem ler tudo para a
+// memória.
+func copyObject(ctx context.Context, src, dst Storage, key string) error {
+	rc, info, err := src.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return dst.PutStream(ctx, key, rc, info.Size, info.ContentType)
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	src, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, data := range map[string]string{
+		"points/1/in/a.jpg": "aaaa",
+		"points/1/in/b.jpg": "bbbb",
+		"7/docs/c.pdf":      "cccc",
+	} {
+		if err := src.Put(ctx, key, []byte(data), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// mesmo tamanho: pulado sem overwrite
+	if err := dst.Put(ctx, "points/1/in/b.jpg", []byte("xxxx"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		overwrite bool
+		dryRun    bool
+		want      CopyStats
+		wantB     string
+	}{
+		{"dry run", false, true, CopyStats{Copied: 1, Skipped: 1, Bytes: 4}, "xxxx"},
+		{"skip existing", false, false, CopyStats{Copied: 1, Skipped: 1, Bytes: 4}, "xxxx"},
+		{"overwrite", true, false, CopyStats{Copied: 2, Bytes: 8}, "bbbb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.dryRun {
+				dst.Delete(ctx, "points/1/in/a.jpg")
+			}
+			stats, err := Copy(ctx, src, dst, "points/", tt.overwrite, tt.dryRun, func(key string, err error) {
+				t.Errorf("copy %s: %v", key, err)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != tt.want {
+				t.Errorf("stats = %+v, want %+v", stats, tt.want)
+			}
+
+			rc, _, err := dst.Get(ctx, "points/1/in/b.jpg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(got) != tt.wantB {
+				t.Errorf("b.jpg = %q, want %q", got, tt.wantB)
+			}
+		})
+	}
+
+	if _, err := dst.Stat(ctx, "7/docs/c.pdf"); err != ErrNotFound {
+		t.Errorf("object outside the prefix was copied: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Info descreve um objeto armazenado.
+type Info struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage abstrai onde os uploads ficam (disco local ou bucket S3).
+// As chaves usam "/" como separador, ex: points/{user_id}/in/{uuid}.jpg
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// PutStream grava os size bytes lidos de r sem carregar o objeto inteiro
+	// na memória (cópia entre backends).
+	PutStream(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	Stat(ctx context.Context, key string) (Info, error)
+	Delete(ctx context.Context, key string) error
+	// List chama fn para cada objeto cujo nome começa com prefix.
+	List(ctx context.Context, prefix string, fn func(Info) error) error
+}
+
+// FromEnv monta o backend a partir de STORAGE_DRIVER (local|s3).
+//
+//	local: STORAGE_LOCAL_DIR (padrão ./uploads)
+//	s3:    S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY
+func FromEnv() (Storage, error) {
+	driver := strings.ToLower(os.Getenv("STORAGE_DRIVER"))
+
+	switch driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		return NewLocal(dir)
+
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	}
+
+	return nil, fmt.Errorf("unknown STORAGE_DRIVER %q (local|s3)", driver)
+}
+
+// validKey rejeita chaves vazias, absolutas ou com "..".
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// URLPrefix é o prefixo com que as chaves são gravadas no banco
+// (ex: /uploads/points/{user_id}/in/{uuid}.jpg).
+const URLPrefix = "/uploads/"
+
+// URL monta o caminho gravado no banco para uma chave.
+func URL(key string) string {
+	return URLPrefix + key
+}
+
+// KeyFromURL faz o caminho inverso de URL.
+func KeyFromURL(url string) (string, bool) {
+	if !strings.HasPrefix(url, URLPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, URLPrefix), true
+}
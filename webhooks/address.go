@@ -0,0 +1,98 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+)
+
+// Os receptores de webhook são URLs informadas pelo cliente: sem esta
+// checagem o dispatcher faria requisições para a rede interna (SSRF), por
+// exemplo para o serviço de metadados da nuvem em 169.254.169.254. A URL é
+// conferida ao cadastrar e de novo a cada conexão, porque o DNS pode mudar
+// depois do cadastro.
+
+var (
+	ErrInvalidURL       = errors.New("invalid url")
+	ErrUnresolvableHost = errors.New("url host does not resolve")
+	ErrNonPublicAddress = errors.New("url resolves to a non-public address")
+)
+
+// faixas reservadas que o net/netip não classifica
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "esta rede"
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmark
+	netip.MustParsePrefix("240.0.0.0/4"),   // reservada (inclui broadcast)
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64
+}
+
+// PublicAddr diz se o endereço pode receber webhooks: fora de loopback,
+// redes privadas, link-local, multicast e faixas reservadas.
+func PublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL valida uma URL de receptor: http(s), com host, e todos os
+// endereços do host públicos.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	_, err = resolvePublic(ctx, u.Hostname())
+	return err
+}
+
+// resolvePublic resolve host e recusa se algum endereço não for público.
+func resolvePublic(ctx context.Context, host string) ([]netip.Addr, error) {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return nil, ErrUnresolvableHost
+	}
+	for _, ip := range addrs {
+		if !PublicAddr(ip) {
+			return nil, ErrNonPublicAddress
+		}
+	}
+	return addrs, nil
+}
+
+// dialPublic é o DialContext do dispatcher: resolve o host, confere os
+// endereços e conecta direto no IP conferido (sem uma segunda resolução que
+// poderia devolver outro endereço).
+func dialPublic(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := resolvePublic(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+
+	var dialer net.Dialer
+	for _, ip := range addrs {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
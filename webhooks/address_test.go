@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://8.8.8.8/hook", nil},
+		{"http://[2606:4700:4700::1111]:8080/hook", nil},
+		{"ftp://8.8.8.8/hook", ErrInvalidURL},
+		{"https:///hook", ErrInvalidURL},
+		{"not a url", ErrInvalidURL},
+		{"http://127.0.0.1/hook", ErrNonPublicAddress},
+		{"http://[::1]/hook", ErrNonPublicAddress},
+		{"http://169.254.169.254/latest/meta-data/", ErrNonPublicAddress},
+		{"http://10.0.0.5/hook", ErrNonPublicAddress},
+		{"http://172.16.3.4/hook", ErrNonPublicAddress},
+		{"http://192.168.0.10/hook", ErrNonPublicAddress},
+		{"http://100.64.1.1/hook", ErrNonPublicAddress},
+		{"http://0.0.0.0/hook", ErrNonPublicAddress},
+		{"http://[fe80::1]/hook", ErrNonPublicAddress},
+		{"http://[fd00::1]/hook", ErrNonPublicAddress},
+		{"http://[::ffff:127.0.0.1]/hook", ErrNonPublicAddress},
+	}
+
+	for _, tt := range tests {
+		if err := CheckURL(context.Background(), tt.url); !errors.Is(err, tt.want) {
+			t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestDialPublicRefusesLoopback(t *testing.T) {
+	conn, err := dialPublic(context.Background(), "tcp", "127.0.0.1:80")
+	if conn != nil {
+		conn.Close()
+	}
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("dialPublic(127.0.0.1) = %v, want ErrNonPublicAddress", err)
+	}
+}
@@ -0,0 +1,250 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Rafhael-Viana/m/db"
+)
+
+const (
+	batchSize = 20
+
+	// tempo que uma entrega fica "reservada" para a instância que a pegou; se
+	// ela cair no meio do envio, outra instância tenta de novo depois disso
+	leaseDuration = 2 * time.Minute
+
+	// primeira espera entre tentativas; dobra a cada falha até maxBackoff
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 12 * time.Hour
+
+	// quanto da resposta do receptor guardamos no log
+	maxResponseBody = 2048
+)
+
+type Dispatcher struct {
+	database    *db.Database
+	client      *http.Client
+	maxAttempts int
+}
+
+// NewDispatcher lê WEBHOOK_MAX_ATTEMPTS (padrão 8) e WEBHOOK_TIMEOUT (padrão 10s).
+func NewDispatcher(database *db.Database) *Dispatcher {
+	maxAttempts := 8
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && n > 0 {
+		maxAttempts = n
+	}
+
+	timeout := 10 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+
+	// só conecta em endereços públicos (inclusive nos redirecionamentos) e
+	// não passa por proxy, que faria a conexão no lugar do dialer
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialPublic
+
+	return &Dispatcher{
+		database:    database,
+		client:      &http.Client{Timeout: timeout, Transport: transport},
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run envia as entregas pendentes a cada intervalo até o contexto acabar. A
+// fila é uma só para todas as empresas.
+func (d *Dispatcher) Run(ctx context.Context, every time.Duration) {
+	ctx = db.AllTenants(ctx)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		// esvazia a fila antes de esperar o próximo tick
+		for {
+			n, err := d.dispatchBatch(ctx)
+			if err != nil {
+				log.Println("Webhook dispatcher error:", err)
+			}
+			if err != nil || n < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type pending struct {
+	id       int64
+	event    string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+	tenantID string
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	// reserva o lote empurrando next_attempt_at; SKIP LOCKED deixa várias
+	// instâncias dividirem a fila sem pegar a mesma entrega. Assinaturas
+	// desativadas ficam de fora (as pendentes são canceladas ao desativar).
+	rows, err := d.database.Pool().Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $1 * interval '1 second'
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id
+		  AND s.active
+		  AND d.id IN (
+			SELECT wd.id
+			FROM webhook_deliveries wd
+			JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+			WHERE wd.status = 'pending' AND wd.next_attempt_at <= now() AND ws.active
+			ORDER BY wd.next_attempt_at
+			LIMIT $2
+			FOR UPDATE OF wd SKIP LOCKED
+		  )
+		RETURNING d.id, d.event, d.payload::text, d.attempts, s.url, s.secret, d.tenant_id
+	`, leaseDuration.Seconds(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := []pending{}
+	for rows.Next() {
+		var p pending
+		var payload string
+		if err := rows.Scan(&p.id, &p.event, &payload, &p.attempts, &p.url, &p.secret, &p.tenantID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.payload = []byte(payload)
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range batch {
+		d.deliver(ctx, p)
+	}
+	return len(batch), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, p pending) {
+	attempt := p.attempts + 1
+	start := time.Now()
+
+	statusCode, body, sendErr := d.send(ctx, p)
+	elapsed := time.Since(start)
+
+	var errMsg *string
+	if sendErr != nil {
+		msg := sendErr.Error()
+		errMsg = &msg
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	_, err := d.database.Pool().Exec(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, response_status, response_body, error, duration_ms, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, p.id, attempt, code, body, errMsg, elapsed.Milliseconds(), p.tenantID)
+	if err != nil {
+		log.Println("DB error logging webhook attempt:", err)
+	}
+
+	switch {
+	case sendErr == nil:
+		_, err = d.database.Pool().Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'succeeded', attempts = $2, last_attempt_at = now(),
+			    response_status = $3, last_error = NULL, delivered_at = now()
+			WHERE id = $1
+		`, p.id, attempt, code)
+
+	case attempt >= d.maxAttempts:
+		_, err = d.database.Pool().Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'failed', attempts = $2, last_attempt_at = now(),
+			    response_status = $3, last_error = $4
+			WHERE id = $1
+		`, p.id, attempt, code, errMsg)
+
+	default:
+		_, err = d.database.Pool().Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET attempts = $2, last_attempt_at = now(),
+			    response_status = $3, last_error = $4,
+			    next_attempt_at = now() + $5 * interval '1 second'
+			WHERE id = $1
+		`, p.id, attempt, code, errMsg, backoff(attempt).Seconds())
+	}
+	if err != nil {
+		log.Println("DB error updating webhook delivery:", err)
+	}
+}
+
+// send faz o POST. Qualquer resposta fora de 2xx conta como falha.
+func (d *Dispatcher) send(ctx context.Context, p pending) (int, *string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(p.payload))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "m-webhooks/1")
+	req.Header.Set("X-Webhook-Event", p.event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(p.id, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("X-Webhook-Signature", Sign(p.secret, now, p.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	body := string(raw)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &body, &statusError{code: resp.StatusCode}
+	}
+	return resp.StatusCode, &body, nil
+}
+
+type statusError struct{ code int }
+
+func (e *statusError) Error() string {
+	return "unexpected status " + strconv.Itoa(e.code)
+}
+
+// backoff exponencial com jitter de ±20% para não sincronizar retries.
+func backoff(attempt int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, maxBackoff)
+
+	jitter := time.Duration(float64(wait) * (rand.Float64()*0.4 - 0.2))
+	return wait + jitter
+}
@@ -0,0 +1,153 @@
+// Package webhooks entrega eventos de ponto e RH para sistemas externos
+// (folha, controle de acesso). Os eventos são gravados em webhook_deliveries
+// e enviados em segundo plano pelo Dispatcher, com assinatura HMAC e retries.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Rafhael-Viana/m/db"
+)
+
+const (
+	EventPointClockIn  = "point.clock_in"
+	EventPointClockOut = "point.clock_out"
+	EventPointUpdated  = "point.updated"
+	EventPointDeleted  = "point.deleted"
+
+	EventUserCreated     = "user.created"
+	EventUserUpdated     = "user.updated"
+	EventUserDeactivated = "user.deactivated"
+	EventUserMoved       = "user.moved" // troca de setor
+	EventUserDeleted     = "user.deleted"
+
+	EventSetorCreated = "setor.created"
+	EventSetorUpdated = "setor.updated"
+	EventSetorDeleted = "setor.deleted"
+
+	// documento do funcionário entrou na janela de vencimento
+	EventDocumentExpiring = "document.expiring"
+
+	// enviado só pelo endpoint de teste da assinatura
+	EventPing = "ping"
+
+	// assina todos os eventos
+	AllEvents = "*"
+)
+
+// Events é o catálogo de eventos que podem ser assinados.
+var Events = []string{
+	EventPointClockIn, EventPointClockOut, EventPointUpdated, EventPointDeleted,
+	EventUserCreated, EventUserUpdated, EventUserDeactivated, EventUserMoved, EventUserDeleted,
+	EventSetorCreated, EventSetorUpdated, EventSetorDeleted,
+	EventDocumentExpiring,
+}
+
+func ValidEvent(name string) bool {
+	return name == AllEvents || slices.Contains(Events, name)
+}
+
+// Envelope é o corpo enviado em todo webhook.
+type Envelope struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Emit enfileira o evento para todas as assinaturas ativas interessadas.
+// Só grava no banco; o envio é do Dispatcher.
+func Emit(ctx context.Context, database *db.Database, event string, data any) error {
+	env := Envelope{ID: uuid.NewString(), Event: event, CreatedAt: time.Now().UTC(), Data: data}
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Pool().Exec(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event, event_id, payload, tenant_id)
+		SELECT id, $1, $2, $3, tenant_id
+		FROM webhook_subscriptions
+		WHERE active AND ($1 = ANY(events) OR '*' = ANY(events))
+	`, event, env.ID, payload)
+	return err
+}
+
+// EmitTo enfileira o evento para uma assinatura específica, mesmo que ela não
+// o assine (usado pelo ping). Retorna o id da entrega.
+func EmitTo(ctx context.Context, database *db.Database, subscriptionID, event string, data any) (int64, error) {
+	env := Envelope{ID: uuid.NewString(), Event: event, CreatedAt: time.Now().UTC(), Data: data}
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = database.Pool().QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event, event_id, payload, tenant_id)
+		SELECT id, $2, $3, $4, tenant_id
+		FROM webhook_subscriptions
+		WHERE id = $1
+		RETURNING id
+	`, subscriptionID, event, env.ID, payload).Scan(&id)
+	return id, err
+}
+
+// ErrSubscriptionInactive: a assinatura da entrega está desativada.
+var ErrSubscriptionInactive = errors.New("webhook subscription is inactive")
+
+// Redeliver cria uma nova entrega com o mesmo payload de uma anterior. A
+// original fica intacta no log. Assinatura desativada não recebe reenvio.
+func Redeliver(ctx context.Context, database *db.Database, deliveryID int64) (int64, error) {
+	var id int64
+	err := database.Pool().QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event, event_id, payload, redelivery_of, tenant_id)
+		SELECT d.subscription_id, d.event, d.event_id, d.payload, d.id, d.tenant_id
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.id = $1 AND s.active
+		RETURNING id
+	`, deliveryID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := database.Pool().QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE id = $1)`, deliveryID,
+		).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if exists {
+			return 0, ErrSubscriptionInactive
+		}
+	}
+	return id, err
+}
+
+// NewSecret gera o segredo usado para assinar os payloads.
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// Sign calcula o header X-Webhook-Signature: HMAC-SHA256 de
+// "<timestamp>.<corpo>" com o segredo da assinatura. O timestamp entra na
+// conta para o receptor poder recusar replays antigos.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}